@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ajvError mirrors the error objects reported by the AJV validator, see
+// https://ajv.js.org/api.html#error-objects
+type ajvError struct {
+	InstancePath string                 `json:"instancePath"`
+	SchemaPath   string                 `json:"schemaPath"`
+	Keyword      string                 `json:"keyword"`
+	Params       map[string]interface{} `json:"params"`
+	Message      string                 `json:"message"`
+}
+
+// ajvParams maps gojsonschema error details to AJV params by failure type.
+// Details without a mapping are dropped for these types, and passed through
+// as is for the others.
+var ajvParams = map[string]map[string]string{
+	"required":                        {"property": "missingProperty"},
+	"invalid_type":                    {"expected": "type"},
+	"const":                           {"allowed": "allowedValue"},
+	"enum":                            {"allowed": "allowedValues"},
+	"additional_property_not_allowed": {"property": "additionalProperty"},
+	"invalid_property_name":           {"property": "propertyName"},
+	"missing_dependency":              {"property": "property", "dependency": "missingProperty", "deps": "deps", "depsCount": "depsCount"},
+	"array_min_items":                 {"min": "limit"},
+	"array_max_items":                 {"max": "limit"},
+	"array_min_properties":            {"min": "limit"},
+	"array_max_properties":            {"max": "limit"},
+	"array_no_additional_items":       {"items": "limit"},
+	"unique":                          {"i": "i", "j": "j"},
+	"string_gte":                      {"min": "limit"},
+	"string_lte":                      {"max": "limit"},
+	"multiple_of":                     {"multiple": "multipleOf"},
+	"number_gte":                      {"min": "limit"},
+	"number_gt":                       {"min": "limit"},
+	"number_lte":                      {"max": "limit"},
+	"number_lt":                       {"max": "limit"},
+}
+
+// ajvComparisons are the `comparison` params AJV includes for number limits
+var ajvComparisons = map[string]string{
+	"number_gte": ">=",
+	"number_gt":  ">",
+	"number_lte": "<=",
+	"number_lt":  "<",
+}
+
+// ajvParamPattern matches the `{name}` of params in ajvMessages
+var ajvParamPattern = regexp.MustCompile(`\{\w+\}`)
+
+// ajvKeywords overrides errorKeywords where AJV reports a different keyword
+var ajvKeywords = map[string]string{
+	"false":          "false schema",
+	"condition_then": "if",
+	"condition_else": "if",
+}
+
+// ajvMessages are the messages of AJV's English locale by failure type,
+// where `{name}` is replaced by the AJV param. Other failures, and those
+// missing a param, keep their description.
+var ajvMessages = map[string]string{
+	"false":        "boolean schema is false",
+	"required":     "must have required property '{missingProperty}'",
+	"invalid_type": "must be {type}",
+	"const":        "must be equal to constant",
+	"enum":         "must be equal to one of the allowed values",
+
+	"number_any_of":  "must match a schema in anyOf",
+	"number_one_of":  "must match exactly one schema in oneOf",
+	"number_not":     "must NOT be valid",
+	"condition_then": `must match "then" schema`,
+	"condition_else": `must match "else" schema`,
+
+	"array_no_additional_items": "must NOT have more than {limit} items",
+	"array_min_items":           "must NOT have fewer than {limit} items",
+	"array_max_items":           "must NOT have more than {limit} items",
+	"unique":                    "must NOT have duplicate items (items ## {j} and {i} are identical)",
+	"contains":                  "must contain at least {minContains} valid item(s)",
+
+	"missing_dependency":              "must have property {deps} when property {property} is present",
+	"array_min_properties":            "must NOT have fewer than {limit} properties",
+	"array_max_properties":            "must NOT have more than {limit} properties",
+	"additional_property_not_allowed": "must NOT have additional properties",
+	"invalid_property_name":           "property name must be valid",
+
+	"string_gte": "must NOT have fewer than {limit} characters",
+	"string_lte": "must NOT have more than {limit} characters",
+	"pattern":    `must match pattern "{pattern}"`,
+	"format":     `must match format "{format}"`,
+
+	"multiple_of": "must be multiple of {multipleOf}",
+	"number_gte":  "must be {comparison} {limit}",
+	"number_gt":   "must be {comparison} {limit}",
+	"number_lte":  "must be {comparison} {limit}",
+	"number_lt":   "must be {comparison} {limit}",
+}
+
+// addSchemaDetails adds the details of the failure f that AJV reports but
+// gojsonschema doesn't from the schema doc and the failing value, i.e. the
+// property requiring a missing dependency and the item limit of
+// `additionalItems`.
+func addSchemaDetails(f *failure, doc, value interface{}) {
+	switch f.Type {
+	case "missing_dependency":
+		deps, _ := resolvePointer(doc, f.schemaLocation)
+		obj, _ := value.(map[string]interface{})
+		m, _ := deps.(map[string]interface{})
+		props := make([]string, 0, len(m))
+		for prop := range m {
+			props = append(props, prop)
+		}
+		sort.Strings(props)
+		for _, prop := range props {
+			list, _ := m[prop].([]interface{})
+			if _, ok := obj[prop]; !ok || !containsValue(list, f.details["dependency"]) {
+				continue
+			}
+			names := make([]string, len(list))
+			for i, d := range list {
+				names[i] = fmt.Sprint(d)
+			}
+			f.details["property"] = prop
+			f.details["deps"] = strings.Join(names, ", ")
+			f.details["depsCount"] = len(names)
+			return
+		}
+	case "array_no_additional_items":
+		parent := f.schemaLocation[:strings.LastIndex(f.schemaLocation, "/")]
+		if items, ok := resolvePointer(doc, parent+"/items"); ok {
+			if list, ok := items.([]interface{}); ok {
+				f.details["items"] = len(list)
+			}
+		}
+	}
+}
+
+// containsValue reports whether list has an element equal to v
+func containsValue(list []interface{}, v interface{}) bool {
+	for _, e := range list {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+func newAJVError(f failure) ajvError {
+	keyword, ok := ajvKeywords[f.Type]
+	if !ok {
+		keyword = errorKeywords[f.Type]
+	}
+	params := make(map[string]interface{})
+	names, mapped := ajvParams[f.Type]
+	for k, v := range f.details {
+		if k == "field" || k == "context" {
+			continue // added by gojsonschema to every error
+		}
+		if n, ok := names[k]; ok {
+			k = n
+		} else if mapped {
+			continue // e.g. the given type, which AJV leaves out
+		}
+		if bf, ok := v.(*big.Float); ok {
+			v = json.Number(bf.Text('g', -1))
+		}
+		params[k] = v
+	}
+	if cmp, ok := ajvComparisons[f.Type]; ok {
+		params["comparison"] = cmp
+	}
+	switch f.Type {
+	case "invalid_type":
+		// e.g. `[string,number]` for multiple types
+		params["type"] = strings.Trim(fmt.Sprint(params["type"]), "[]")
+	case "const":
+		// gojsonschema reports the JSON text of the allowed values
+		var v interface{}
+		if json.Unmarshal([]byte(fmt.Sprint(params["allowedValue"])), &v) == nil {
+			params["allowedValue"] = v
+		}
+	case "enum":
+		var vs []interface{}
+		if json.Unmarshal([]byte("["+fmt.Sprint(params["allowedValues"])+"]"), &vs) == nil {
+			params["allowedValues"] = vs
+		}
+	case "number_one_of":
+		params["passingSchemas"] = nil
+	case "contains":
+		params["minContains"] = 1
+	case "condition_then":
+		params["failingKeyword"] = "then"
+	case "condition_else":
+		params["failingKeyword"] = "else"
+	}
+	message := f.Description
+	if msg, ok := ajvMessages[f.Type]; ok {
+		if n, ok := params["depsCount"]; ok && n != 1 {
+			msg = strings.Replace(msg, "property {deps}", "properties {deps}", 1)
+		}
+		missing := false
+		msg = ajvParamPattern.ReplaceAllStringFunc(msg, func(name string) string {
+			v, ok := params[name[1:len(name)-1]]
+			missing = missing || !ok
+			return fmt.Sprint(v)
+		})
+		if !missing {
+			message = msg
+		}
+	}
+	return ajvError{
+		InstancePath: f.instanceLocation,
+		SchemaPath:   "#" + f.schemaLocation,
+		Keyword:      keyword,
+		Params:       params,
+		Message:      message,
+	}
+}
+
+// writeAJV renders the results as a JSON array with the AJV style errors
+// of each document, which are null for documents that pass like AJV.
+// Errors for malformed documents are reported separately.
+func writeAJV(w io.Writer, results []result) error {
+	type ajvResult struct {
+		Path   string     `json:"path"`
+		Valid  bool       `json:"valid"`
+		Errors []ajvError `json:"errors"`
+		Error  string     `json:"error,omitempty"`
+	}
+	report := make([]ajvResult, 0, len(results))
+	for _, r := range results {
+		if r.quiet() {
+			continue
+		}
+		ar := ajvResult{Path: r.Path, Valid: r.Status == statusPass, Error: r.Error}
+		for _, f := range r.Failures {
+			ar.Errors = append(ar.Errors, newAJVError(f))
+		}
+		report = append(report, ar)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
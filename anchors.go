@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// anchor is a plain name fragment declared by `$anchor` or `$dynamicAnchor`
+type anchor struct {
+	resource string // absolute URI of the enclosing schema resource
+	ptr      string // JSON pointer of the schema within the resource
+	dynamic  bool
+}
+
+// anchorIndex maps the URI of each anchor, i.e. `resource#name`, to the
+// schema declaring it.
+type anchorIndex map[string]anchor
+
+// resolveAnchors rewrites the `$ref`s to anchor names in the sources as JSON
+// pointer refs since gojsonschema resolves every fragment as a pointer, e.g.
+// `#foo` to the root schema. A `$dynamicRef` is likewise added as a `$ref`.
+// When it resolves to a `$dynamicAnchor` the primary schema, which is the
+// last source and the outermost scope of any evaluation, takes precedence if
+// it declares the same dynamic anchor. Without evaluation paths the rest of
+// the dynamic scope can't be known, so the initial target is used otherwise.
+func resolveAnchors(sources []schemaSource) {
+	if len(sources) == 0 {
+		return
+	}
+	indexes := make([]anchorIndex, len(sources))
+	global := make(anchorIndex)
+	var primary string
+	for i, src := range sources {
+		indexes[i], primary = indexAnchors(src)
+		for uri, a := range indexes[i] {
+			// Documents without an `$id` can only refer to their own anchors
+			if a.resource != "" {
+				global[uri] = a
+			}
+		}
+	}
+
+	for i, src := range sources {
+		lookup := func(base *url.URL, ref string) (anchor, string, bool) {
+			u, err := url.Parse(ref)
+			if err != nil {
+				return anchor{}, "", false
+			}
+			target := base.ResolveReference(u)
+			if target.Fragment == "" || strings.HasPrefix(target.Fragment, "/") {
+				return anchor{}, "", false
+			}
+			uri := stripFragment(target) + "#" + target.Fragment
+			if a, ok := indexes[i][uri]; ok {
+				return a, target.Fragment, true
+			}
+			a, ok := global[uri]
+			return a, target.Fragment, ok
+		}
+
+		walkSchema(src.doc, src.baseURI(), func(ptr string, base *url.URL, schema map[string]interface{}) {
+			if ref, ok := schema["$ref"].(string); ok {
+				if a, _, ok := lookup(base, ref); ok {
+					if ref, ok := anchorRef(base, a); ok {
+						schema["$ref"] = ref
+					}
+				}
+			}
+
+			dynamicRef, ok := schema["$dynamicRef"].(string)
+			if !ok {
+				return
+			}
+			ref := dynamicRef
+			if a, name, ok := lookup(base, dynamicRef); ok {
+				if outer, ok := indexes[len(sources)-1][primary+"#"+name]; ok && a.dynamic && outer.dynamic {
+					a = outer
+				}
+				if r, ok := anchorRef(base, a); ok {
+					ref = r
+				}
+			}
+			if _, ok := schema["$ref"]; !ok {
+				schema["$ref"] = ref
+				return
+			}
+			allOf, _ := schema["allOf"].([]interface{})
+			schema["allOf"] = append(allOf, map[string]interface{}{"$ref": ref})
+		})
+	}
+}
+
+// indexAnchors returns the anchors declared in the source along with the
+// URI of its root schema resource.
+func indexAnchors(src schemaSource) (anchorIndex, string) {
+	index := make(anchorIndex)
+	roots := make(map[string]string) // resource URI to its pointer in the doc
+	var root string
+	walkSchema(src.doc, src.baseURI(), func(ptr string, base *url.URL, schema map[string]interface{}) {
+		res := stripFragment(base)
+		if ptr == "" {
+			root = res
+		}
+		if _, ok := roots[res]; !ok {
+			roots[res] = ptr
+		}
+		if name, ok := schema["$anchor"].(string); ok {
+			index[res+"#"+name] = anchor{res, strings.TrimPrefix(ptr, roots[res]), false}
+		}
+		if name, ok := schema["$dynamicAnchor"].(string); ok {
+			index[res+"#"+name] = anchor{res, strings.TrimPrefix(ptr, roots[res]), true}
+		}
+	})
+	return index, root
+}
+
+// anchorRef returns the pointer ref to the anchor from a schema with the
+// given base URI. Resources without an absolute URI, i.e. documents with
+// no `$id`, can't be referenced from elsewhere.
+func anchorRef(base *url.URL, a anchor) (string, bool) {
+	frag := "#" + (&url.URL{Fragment: a.ptr}).EscapedFragment()
+	switch {
+	case a.resource == stripFragment(base):
+		return frag, true
+	case a.resource != "":
+		return a.resource + frag, true
+	}
+	return "", false
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// lazySchemas are the schemas compiled for the `$schema` of documents with
+// `-auto-schema` and those matched in the `-catalog`, shared by all
+// documents using the same one.
+var lazySchemas schemaCache
+
+// schemaCache compiles schemas on first use, with the `-r` schemas refs
+type schemaCache struct {
+	mu      sync.Mutex
+	refs    []schemaSource
+	schemas map[string]schemaCacheEntry
+}
+
+type schemaCacheEntry struct {
+	schema compiledSchema
+	err    error
+}
+
+// reset clears the cache, compiling any later schemas with refs
+func (c *schemaCache) reset(refs []schemaSource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refs = refs
+	c.schemas = make(map[string]schemaCacheEntry)
+}
+
+// get returns the compiled schema at location, a path or URL. Dialect
+// warnings are printed the first time, like those of `-s` schemas.
+func (c *schemaCache) get(location string) (compiledSchema, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.schemas[location]; ok {
+		return e.schema, e.err
+	}
+	var e schemaCacheEntry
+	src, err := loadSchema(location)
+	if err == nil {
+		// As with lint, a schema also matched by `-r` mustn't be registered twice
+		abs, _ := filepath.Abs(location)
+		refs := make([]schemaSource, 0, len(c.refs))
+		for _, ref := range c.refs {
+			if p, _ := filepath.Abs(ref.path); isURL(location) || p != abs {
+				refs = append(refs, ref)
+			}
+		}
+		e.schema, err = compileSchema(src, refs)
+		if msg := dialectWarning(src.doc); msg != "" && err == nil && quietFlag < 2 {
+			fmt.Fprintf(os.Stderr, "%s: warning: %s\n", location, msg)
+		}
+	}
+	e.err = err
+	c.schemas[location] = e
+	return e.schema, e.err
+}
+
+// declaredSchema returns the schema named by the `$schema` of the JSON text
+// buf, converted from the document at path, or nil if it doesn't declare
+// one. Relative references are resolved against the document, and the
+// meta-schemas of the supported drafts are never fetched.
+func declaredSchema(path string, buf []byte) (schemaSet, error) {
+	var doc struct {
+		Schema string `json:"$schema"`
+	}
+	if err := json.Unmarshal(buf, &doc); err != nil || doc.Schema == "" {
+		return nil, nil
+	}
+	for _, u := range metaSchemaURLs {
+		if schemaDialect(doc.Schema) == u {
+			meta, err := metaSchema(u)
+			if err != nil {
+				return nil, err
+			}
+			return schemaSet{meta}, nil
+		}
+	}
+
+	ref, err := url.Parse(doc.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid $schema %s: %s", doc.Schema, err)
+	}
+	location := doc.Schema
+	switch {
+	case ref.Scheme == "http" || ref.Scheme == "https":
+	case ref.Scheme == "file":
+		location = ref.Path
+	case ref.Scheme != "":
+		return nil, fmt.Errorf("unsupported $schema %s", doc.Schema)
+	case isURL(path):
+		base, err := url.Parse(path)
+		if err != nil {
+			return nil, err
+		}
+		location = base.ResolveReference(ref).String()
+	case isObjectURI(path):
+		return nil, fmt.Errorf("relative $schema %s of a remote document", doc.Schema)
+	case path != stdinPath && !filepath.IsAbs(doc.Schema):
+		location = filepath.Join(filepath.Dir(path), doc.Schema)
+	}
+	schema, err := lazySchemas.get(location)
+	if err != nil {
+		return nil, err
+	}
+	return schemaSet{schema}, nil
+}
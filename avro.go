@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// validateAvro validates each record of the Avro object container file src
+// as standard JSON, i.e. with union values unwrapped rather than keyed by
+// their type like Avro's JSON encoding. Records are reported by index, e.g.
+// `data.avro[record3]`, unless there is only one.
+func validateAvro(schema schemaSet, path string, src []byte) []result {
+	ocf, err := goavro.NewOCFReader(bytes.NewReader(src))
+	if err != nil {
+		return []result{{Path: path, Status: statusError, Error: fmt.Sprintf("load doc: %s", err)}}
+	}
+	codec, err := goavro.NewCodecForStandardJSONFull(ocf.Codec().Schema())
+	if err != nil {
+		return []result{{Path: path, Status: statusError, Error: fmt.Sprintf("load doc: %s", err)}}
+	}
+
+	var results []result
+	for i := 1; ocf.Scan(); i++ {
+		start := time.Now()
+		name := fmt.Sprintf("%s[record%d]", path, i)
+		datum, err := ocf.Read()
+		if err != nil {
+			results = append(results, result{Path: name, Status: statusError, Error: fmt.Sprintf("load doc: %s", err), file: path})
+			break
+		}
+		buf, err := codec.TextualFromNative(nil, datum)
+		if err != nil {
+			results = append(results, result{Path: name, Status: statusError, Error: fmt.Sprintf("load doc: %s", err), file: path})
+			continue
+		}
+		r := validateJSON(schema, name, buf, len(buf), start, func() map[string]position { return nil })
+		r.file = path
+		results = append(results, r)
+	}
+	if err := ocf.Err(); err != nil {
+		results = append(results, result{Path: path, Status: statusError, Error: fmt.Sprintf("load doc: %s", err)})
+	}
+
+	if len(results) == 1 && results[0].file != "" {
+		results[0].Path, results[0].file = path, ""
+	}
+	return results
+}
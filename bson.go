@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// validateBSON validates each of the concatenated documents in BSON text
+// src, e.g. as written by mongodump, as relaxed extended JSON. Documents
+// are reported by their byte offset in the file, e.g. `dump.bson@1024`,
+// unless there is only one.
+func validateBSON(schema schemaSet, path string, src []byte) []result {
+	var results []result
+	for off := 0; off < len(src); {
+		start := time.Now()
+		name := fmt.Sprintf("%s@%d", path, off)
+		fail := func(format string, args ...interface{}) {
+			results = append(results, result{Path: name, Status: statusError, Error: "load doc: " + fmt.Sprintf(format, args...), file: path})
+		}
+
+		if len(src)-off < 5 {
+			fail("bson: truncated document")
+			break
+		}
+		n := int(binary.LittleEndian.Uint32(src[off:]))
+		if n < 5 || n > len(src)-off {
+			fail("bson: invalid document length %d", n)
+			break
+		}
+		raw := bson.Raw(src[off : off+n])
+		off += n
+
+		if err := raw.Validate(); err != nil {
+			fail("%s", err)
+			continue
+		}
+		buf, err := bson.MarshalExtJSON(raw, false, false)
+		if err != nil {
+			fail("%s", err)
+			continue
+		}
+		r := validateJSON(schema, name, buf, n, start, func() map[string]position { return nil })
+		r.file = path
+		results = append(results, r)
+	}
+
+	if len(results) == 1 {
+		results[0].Path, results[0].file = path, ""
+	}
+	return results
+}
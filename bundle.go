@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// bundleResource locates a schema resource, i.e. a document or a subschema
+// with an `$id`, within a loaded document
+type bundleResource struct {
+	doc int
+	ptr string
+	uri string // canonical URI, which anchors are indexed by
+}
+
+// bundleDoc is a document loaded while bundling, embedded in the bundle at
+// prefix once any ref resolves to it
+type bundleDoc struct {
+	src      schemaSource
+	anchors  anchorIndex
+	prefix   string
+	embedded bool
+}
+
+// bundler inlines the documents referenced by a schema, transitively, as
+// definitions of the schema itself
+type bundler struct {
+	docs      []*bundleDoc
+	resources map[string]bundleResource
+	defs      map[string]interface{}
+	keyword   string
+	pending   []int
+}
+
+// bundleMain implements `yajsv bundle`, writing the bundle of the schema
+// argument as indented JSON.
+func bundleMain(w io.Writer) int {
+	if flag.NArg() != 1 {
+		return usageError("bundle expects a single schema")
+	}
+	refs, err := loadRefs(flag.Args())
+	if err != nil {
+		return schemaError("%s", err)
+	}
+	doc, err := bundleSchema(flag.Arg(0), refs)
+	if err != nil {
+		return schemaError("%s: unable to bundle schema: %s", flag.Arg(0), err)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return schemaError("%s: unable to bundle schema: %s", flag.Arg(0), err)
+	}
+	return 0
+}
+
+// bundleSchema returns a copy of the schema at arg, a path or URL, with every
+// document its `$ref`s resolve to embedded under its `definitions`, or `$defs`
+// if it uses those. Refs resolve as when validating, i.e. to `-r` schemas by
+// their `$id` or remote schemas, and otherwise to files relative to the
+// referencing one. All refs are rewritten as local pointers and the `$id`s
+// of embedded resources are dropped so they can't change how those resolve.
+func bundleSchema(arg string, refs []schemaSource) (interface{}, error) {
+	src, err := loadSchema(arg)
+	if err != nil {
+		return nil, err
+	}
+	if *baseURIFlag != "" {
+		src.base, _ = url.Parse(*baseURIFlag)
+	}
+	src.doc = copyJSON(src.doc)
+	root, ok := src.doc.(map[string]interface{})
+	if !ok {
+		return src.doc, nil
+	}
+
+	b := &bundler{
+		resources: make(map[string]bundleResource),
+		defs:      make(map[string]interface{}),
+		keyword:   "definitions",
+	}
+	if _, ok := root["$defs"]; ok {
+		b.keyword = "$defs"
+	}
+	existing, _ := root[b.keyword].(map[string]interface{})
+	for k, v := range existing {
+		b.defs[k] = v
+	}
+	b.add(src)
+	b.docs[0].embedded = true
+	for _, ref := range refs {
+		ref.doc = copyJSON(ref.doc)
+		b.add(ref)
+	}
+
+	b.pending = []int{0}
+	for len(b.pending) > 0 {
+		d := b.docs[b.pending[0]]
+		b.pending = b.pending[1:]
+		var errs []string
+		walkSchema(d.src.doc, d.src.baseURI(), func(ptr string, base *url.URL, schema map[string]interface{}) {
+			ref, ok := schema["$ref"].(string)
+			if !ok {
+				return
+			}
+			local, err := b.resolve(d, base, ref)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s#%s: %s", d.src.path, ptr, err))
+				return
+			}
+			schema["$ref"] = "#" + (&url.URL{Fragment: local}).EscapedFragment()
+		})
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("%s", strings.Join(errs, "\n"))
+		}
+	}
+
+	if len(b.defs) > len(existing) {
+		root[b.keyword] = b.defs
+	}
+	walkSchema(root, &url.URL{}, func(ptr string, base *url.URL, schema map[string]interface{}) {
+		if ptr == "" {
+			return
+		}
+		if _, ok := schema["$id"].(string); ok {
+			delete(schema, "$id")
+		} else if _, ok := schema["id"].(string); ok {
+			delete(schema, "id")
+		}
+	})
+	return root, nil
+}
+
+// add indexes the resources of a loaded document by their URIs, keeping
+// those already indexed, along with the anchors within each, including the
+// location-independent `$id`s of earlier drafts, e.g. `#foo`.
+func (b *bundler) add(src schemaSource) int {
+	i := len(b.docs)
+	anchors, root := indexAnchors(src)
+	b.docs = append(b.docs, &bundleDoc{src: src, anchors: anchors})
+	if src.base != nil {
+		if _, ok := b.resources[stripFragment(src.base)]; !ok {
+			b.resources[stripFragment(src.base)] = bundleResource{i, "", root}
+		}
+	}
+	roots := make(map[string]string)
+	walkSchema(src.doc, src.baseURI(), func(ptr string, base *url.URL, schema map[string]interface{}) {
+		res := stripFragment(base)
+		if _, ok := roots[res]; !ok {
+			roots[res] = ptr
+			if _, ok := b.resources[res]; !ok {
+				b.resources[res] = bundleResource{i, ptr, res}
+			}
+		}
+		if id := schemaID(schema); strings.HasPrefix(id, "#") {
+			anchors[res+id] = anchor{res, strings.TrimPrefix(ptr, roots[res]), false}
+		}
+	})
+	return i
+}
+
+// resolve returns the pointer, within the bundle, of the schema that ref
+// resolves to from a schema of the document from with the given base URI.
+// Documents that aren't loaded yet are fetched or read from disk.
+func (b *bundler) resolve(from *bundleDoc, base *url.URL, ref string) (string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid reference %s: %s", ref, err)
+	}
+	target := base.ResolveReference(u)
+	res, ok := b.resources[stripFragment(target)]
+	if !ok {
+		var src schemaSource
+		switch {
+		case isURL(target.String()):
+			src, err = loadSchema(stripFragment(target))
+		case u.Scheme == "" && u.Host == "" && u.Path != "" && !isURL(from.src.path):
+			src, err = loadSchema(filepath.Join(filepath.Dir(from.src.path), filepath.FromSlash(u.Path)))
+		default:
+			err = fmt.Errorf("unresolved reference %s", ref)
+		}
+		if err != nil {
+			return "", err
+		}
+		src.base, _ = url.Parse(stripFragment(target))
+		b.add(src)
+		res = b.resources[stripFragment(target)]
+	}
+
+	d := b.docs[res.doc]
+	frag := target.Fragment
+	var ptr string
+	switch {
+	case frag == "" || strings.HasPrefix(frag, "/"):
+		ptr = res.ptr + frag
+		if _, ok := resolvePointer(d.src.doc, ptr); !ok {
+			return "", fmt.Errorf("unresolved reference %s, pointer not found", ref)
+		}
+	default:
+		a, ok := d.anchors[res.uri+"#"+frag]
+		if !ok {
+			return "", fmt.Errorf("unresolved reference %s, anchor not found", ref)
+		}
+		ptr = res.ptr + a.ptr
+	}
+
+	if !d.embedded {
+		d.embedded = true
+		name := bundleName(b.defs, d.src.path)
+		d.prefix = "/" + b.keyword + "/" + pointerEscaper.Replace(name)
+		if m, ok := d.src.doc.(map[string]interface{}); ok {
+			delete(m, "$schema")
+		}
+		b.defs[name] = d.src.doc
+		b.pending = append(b.pending, res.doc)
+	}
+	return d.prefix + ptr, nil
+}
+
+// bundleName returns a definition name for the document at p, its file name
+// sans extension, that's not yet taken in defs.
+func bundleName(defs map[string]interface{}, p string) string {
+	if u, err := url.Parse(p); err == nil && isURL(p) {
+		p = u.Path
+	}
+	name := path.Base(filepath.ToSlash(p))
+	name = strings.TrimSuffix(name, path.Ext(name))
+	if name == "" || name == "." || name == "/" {
+		name = "schema"
+	}
+	unique := name
+	for n := 2; defs[unique] != nil; n++ {
+		unique = fmt.Sprintf("%s-%d", name, n)
+	}
+	return unique
+}
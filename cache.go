@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// cacheEntry is the metadata stored alongside the body of a cached schema
+type cacheEntry struct {
+	URL     string    `json:"url"`
+	ETag    string    `json:"etag,omitempty"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// cacheDir returns the directory for cached remote schemas, `-cache-dir` or
+// `yajsv/schemas` under the user cache directory, e.g. `~/.cache` on Linux.
+func cacheDir() (string, error) {
+	if *cacheDirFlag != "" {
+		return *cacheDirFlag, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "yajsv", "schemas"), nil
+}
+
+// fetchSchema downloads the schema at rawurl, reusing a cached copy fetched
+// within `-cache-ttl`. Older copies are revalidated with their ETag, if any,
+// and used as is when the server can't be reached. With `-offline` only
+// cached copies are used, regardless of age.
+func fetchSchema(rawurl string) ([]byte, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		if *offlineFlag {
+			return nil, fmt.Errorf("%s can't be fetched with -offline: %s", rawurl, err)
+		}
+		return getSchema(rawurl)
+	}
+	sum := sha256.Sum256([]byte(rawurl))
+	file := filepath.Join(dir, hex.EncodeToString(sum[:]))
+
+	var entry cacheEntry
+	cached, err := ioutil.ReadFile(file + ".body")
+	if err == nil {
+		meta, err := ioutil.ReadFile(file + ".json")
+		if err != nil || json.Unmarshal(meta, &entry) != nil || entry.URL != rawurl {
+			cached = nil
+		}
+	}
+	if cached != nil && (*offlineFlag || time.Since(entry.Fetched) < *cacheTTLFlag) {
+		return cached, nil
+	}
+	if *offlineFlag {
+		return nil, fmt.Errorf("%s isn't cached and can't be fetched with -offline", rawurl)
+	}
+
+	req, err := newSchemaRequest(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil && entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+	resp, err := doHTTP(req, schemaTimeout())
+	if err != nil {
+		if cached != nil {
+			return cached, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		if cached, err = ioutil.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+		entry.ETag = resp.Header.Get("ETag")
+	default:
+		return nil, fmt.Errorf("GET %s: %s", rawurl, resp.Status)
+	}
+
+	// Failing to update the cache only costs fetching the schema again
+	entry.URL, entry.Fetched = rawurl, time.Now()
+	if err := os.MkdirAll(dir, 0755); err == nil {
+		meta, _ := json.Marshal(entry)
+		if err := writeCacheFile(file+".body", cached); err == nil {
+			writeCacheFile(file+".json", meta)
+		}
+	}
+	return cached, nil
+}
+
+// writeCacheFile replaces the cache file at path with buf by renaming a
+// temporary file over it, so concurrent runs never read a partial write.
+func writeCacheFile(path string, buf []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(buf)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// cachedSchema is the `-schema-cache` entry of a local schema, the sources
+// resolveSources returned for it, along with the `-r` files among them so
+// that new matches of the patterns are noticed.
+type cachedSchema struct {
+	Refs    []string       `json:"refs"`
+	Sources []cachedSource `json:"sources"`
+}
+
+// cachedSource is a schemaSource that's reused while the sha256 of the
+// local file it was read from is unchanged
+type cachedSource struct {
+	Path     string          `json:"path"`
+	SHA256   string          `json:"sha256"`
+	Base     *string         `json:"base,omitempty"`
+	Fragment string          `json:"fragment,omitempty"`
+	Ref      bool            `json:"ref,omitempty"`
+	Doc      json.RawMessage `json:"doc"`
+}
+
+// fileLoader is the loader of a local schema file, along with the sha256 of
+// its contents with `-schema-cache`
+type fileLoader struct {
+	gojsonschema.JSONLoader
+	sum string
+}
+
+// fileSum returns the sha256 of the file read by loader, or "" if it isn't
+// a fileLoader or `-schema-cache` isn't set.
+func fileSum(loader gojsonschema.JSONLoader) string {
+	if l, ok := loader.(fileLoader); ok {
+		return l.sum
+	}
+	return ""
+}
+
+func sha256Hex(buf []byte) string {
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])
+}
+
+// loadCompiled loads the schema at arg, with the `-base-uri` baseURI if
+// any, and compiles it along with the `-r` schemas returned by refs. Along
+// with the compiled schema are the sources of the schema itself and the `-r`
+// schemas, for their dialect warnings.
+//
+// With `-schema-cache` the sources a local schema resolves to, i.e. it and
+// every `-r` schema and file its refs point to, are cached as JSON. Later runs
+// with the same options reuse them as long as the `-r` patterns match the same
+// files and the sha256 of each file is unchanged, which skips reading,
+// converting and resolving all of them. They're still compiled every time.
+func loadCompiled(arg, baseURI string, targets []string, refs func() ([]schemaSource, error)) (compiledSchema, schemaSource, []schemaSource, error) {
+	file := schemaCacheFile(arg, targets)
+	if file != "" {
+		if sources, n, ok := readCachedSchema(file, targets); ok {
+			cs, err := compileSources(sources)
+			return cs, sources[len(sources)-1], sources[:n], err
+		}
+	}
+
+	rs, err := refs()
+	if err != nil {
+		return compiledSchema{}, schemaSource{}, nil, err
+	}
+	src, err := loadSchema(arg)
+	if err != nil {
+		return compiledSchema{}, schemaSource{}, nil, err
+	}
+	if baseURI != "" {
+		src.base, _ = url.Parse(baseURI)
+	}
+	sources, err := resolveSources(src, rs)
+	if err != nil {
+		return compiledSchema{}, schemaSource{}, nil, err
+	}
+	// The sources are encoded before compiling modifies them, but only
+	// cached once they compile
+	var entry []byte
+	if file != "" {
+		entry = encodeCachedSchema(sources, len(rs))
+	}
+	cs, err := compileSources(sources)
+	if err == nil && entry != nil {
+		// As with remote schemas, failing to update the cache is harmless
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err == nil {
+			writeCacheFile(file, entry)
+		}
+	}
+	return cs, src, rs, err
+}
+
+// schemaCacheFile returns the path of the `-schema-cache` entry of the
+// schema at arg, or "" if it isn't cached. Entries are keyed by the version,
+// working directory, primary schemas targets and the values of all flags
+// since most of them affect how schemas are loaded and resolved.
+func schemaCacheFile(arg string, targets []string) string {
+	location, _ := splitFragment(arg)
+	if !*schemaCacheFlag || isURL(location) || isRegistryURI(location) || isObjectURI(location) || location == stdinPath {
+		return ""
+	}
+	dir, err := cacheDir()
+	if err != nil {
+		return ""
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%q\x00", version, wd, arg, targets)
+	flag.VisitAll(func(f *flag.Flag) {
+		fmt.Fprintf(h, "%s=%s\x00", f.Name, f.Value)
+	})
+	return filepath.Join(dir, "sources", hex.EncodeToString(h.Sum(nil))+".json")
+}
+
+// encodeCachedSchema returns the entry of the sources of a schema, the first
+// refs of which are the `-r` schemas, or nil if any wasn't read from a local
+// file.
+func encodeCachedSchema(sources []schemaSource, refs int) []byte {
+	entry := cachedSchema{Refs: []string{}}
+	for i, s := range sources {
+		if s.sum == "" {
+			return nil
+		}
+		doc, err := json.Marshal(s.doc)
+		if err != nil {
+			return nil
+		}
+		c := cachedSource{Path: s.path, SHA256: s.sum, Fragment: s.fragment, Ref: i < refs, Doc: doc}
+		if s.base != nil {
+			base := s.base.String()
+			c.Base = &base
+		}
+		if c.Ref {
+			entry.Refs = append(entry.Refs, s.path)
+		}
+		entry.Sources = append(entry.Sources, c)
+	}
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return nil
+	}
+	return buf
+}
+
+// readCachedSchema returns the sources of the `-schema-cache` entry file,
+// and how many of the first are `-r` schemas, unless any of its files has
+// changed or the `-r` patterns no longer match the same files.
+func readCachedSchema(file string, targets []string) ([]schemaSource, int, bool) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, 0, false
+	}
+	var entry cachedSchema
+	if err := json.Unmarshal(buf, &entry); err != nil || len(entry.Sources) == 0 {
+		return nil, 0, false
+	}
+	paths, err := refPaths(targets)
+	if err != nil || len(paths) != len(entry.Refs) {
+		return nil, 0, false
+	}
+	for i, p := range paths {
+		if p != entry.Refs[i] {
+			return nil, 0, false
+		}
+	}
+
+	sources := make([]schemaSource, len(entry.Sources))
+	for i, c := range entry.Sources {
+		location, _ := splitFragment(c.Path)
+		contents, err := ioutil.ReadFile(location)
+		if err != nil || sha256Hex(contents) != c.SHA256 {
+			return nil, 0, false
+		}
+		s := schemaSource{path: c.Path, fragment: c.Fragment, sum: c.SHA256}
+		// As when loading them, numbers are kept exact
+		dec := json.NewDecoder(bytes.NewReader(c.Doc))
+		dec.UseNumber()
+		if err := dec.Decode(&s.doc); err != nil {
+			return nil, 0, false
+		}
+		if c.Base != nil {
+			if s.base, err = url.Parse(*c.Base); err != nil {
+				return nil, 0, false
+			}
+		}
+		sources[i] = s
+	}
+	return sources, len(entry.Refs), true
+}
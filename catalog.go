@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// schemaStoreCatalog is the default `-catalog-url`, see https://www.schemastore.org
+const schemaStoreCatalog = "https://www.schemastore.org/api/json/catalog.json"
+
+// catalogEntry is a schema of a SchemaStore catalog along with the
+// `fileMatch` globs of the documents it applies to
+type catalogEntry struct {
+	Name      string   `json:"name"`
+	URL       string   `json:"url"`
+	FileMatch []string `json:"fileMatch"`
+
+	patterns []*regexp.Regexp
+	excludes []*regexp.Regexp
+}
+
+// loadCatalog fetches the catalog at rawurl, through the schema cache, and
+// compiles the `fileMatch` globs of its entries.
+func loadCatalog(rawurl string) ([]catalogEntry, error) {
+	buf, err := fetchSchema(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("%s: unable to load catalog: %s", rawurl, err)
+	}
+	var catalog struct {
+		Schemas []catalogEntry `json:"schemas"`
+	}
+	if err := json.Unmarshal(buf, &catalog); err != nil {
+		return nil, fmt.Errorf("%s: invalid catalog: %s", rawurl, err)
+	}
+	entries := catalog.Schemas[:0]
+	for _, e := range catalog.Schemas {
+		if e.URL == "" {
+			continue
+		}
+		for _, m := range e.FileMatch {
+			if strings.HasPrefix(m, "!") {
+				e.excludes = append(e.excludes, catalogGlob(m[1:]))
+			} else {
+				e.patterns = append(e.patterns, catalogGlob(m))
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// catalogSchema returns the schema URL of the first catalog entry matching
+// the document at p, or "" if there's none.
+func catalogSchema(catalog []catalogEntry, p string) string {
+	if isURL(p) || isObjectURI(p) || p == stdinPath {
+		return ""
+	}
+	p = filepath.ToSlash(p)
+	if abs, err := filepath.Abs(p); err == nil {
+		p = filepath.ToSlash(abs)
+	}
+	for _, e := range catalog {
+		if catalogMatch(e.excludes, p) {
+			continue
+		}
+		if catalogMatch(e.patterns, p) {
+			return e.URL
+		}
+	}
+	return ""
+}
+
+func catalogMatch(patterns []*regexp.Regexp, p string) bool {
+	for _, re := range patterns {
+		if re.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// cborEncodings are the `-cbor-bytes` encodings of byte strings, named by
+// their JSON Schema contentEncoding
+var cborEncodings = map[string]func([]byte) string{
+	"base64":    base64.StdEncoding.EncodeToString,
+	"base64url": base64.RawURLEncoding.EncodeToString,
+	"base16":    hex.EncodeToString,
+}
+
+// cborExpectedEncodings are the tags for the expected JSON encoding of the
+// byte strings within the tagged item, see RFC 8949 section 3.4.5.2
+var cborExpectedEncodings = map[uint64]string{
+	21: "base64url",
+	22: "base64",
+	23: "base16",
+}
+
+// cborToJSON converts a CBOR encoded document to JSON text. Byte strings
+// are encoded per `-cbor-bytes` so they can be described by a matching
+// contentEncoding in the schema, unless an expected conversion tag says
+// otherwise. Map keys are formatted as strings, other tags are dropped,
+// bignums are numbers and timestamps are RFC 3339 strings.
+func cborToJSON(buf []byte) ([]byte, error) {
+	var v interface{}
+	if err := cbor.Unmarshal(buf, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(cborValue(v, cborEncodings[*cborBytesFlag]))
+}
+
+func cborValue(v interface{}, enc func([]byte) string) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = cborValue(e, enc)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = cborValue(e, enc)
+		}
+	case []byte:
+		return enc(v)
+	case big.Int:
+		return json.Number(v.String())
+	case cbor.Tag:
+		if name, ok := cborExpectedEncodings[v.Number]; ok {
+			enc = cborEncodings[name]
+		}
+		return cborValue(v.Content, enc)
+	}
+	return v
+}
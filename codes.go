@@ -0,0 +1,126 @@
+package main
+
+// errorCodes maps gojsonschema failure types to stable yajsv error codes
+// that downstream tooling can filter on rather than matching messages.
+// Codes must never be renumbered or reused, only added.
+var errorCodes = map[string]string{
+	// General
+	"false":        "YJ1001",
+	"required":     "YJ1002",
+	"invalid_type": "YJ1003",
+	"const":        "YJ1004",
+	"enum":         "YJ1005",
+	"internal":     "YJ1006",
+
+	// Combinators
+	"number_any_of": "YJ2001",
+	"number_one_of": "YJ2002",
+	"number_all_of": "YJ2003",
+	"number_not":    "YJ2004",
+
+	// Conditionals
+	"condition_then": "YJ2101",
+	"condition_else": "YJ2102",
+
+	// Arrays
+	"array_no_additional_items": "YJ3001",
+	"array_min_items":           "YJ3002",
+	"array_max_items":           "YJ3003",
+	"unique":                    "YJ3004",
+	"contains":                  "YJ3005",
+
+	// Objects
+	"missing_dependency":              "YJ4001",
+	"array_min_properties":            "YJ4002",
+	"array_max_properties":            "YJ4003",
+	"additional_property_not_allowed": "YJ4004",
+	"invalid_property_pattern":        "YJ4005",
+	"invalid_property_name":           "YJ4006",
+	"duplicate_key":                   "YJ4007",
+
+	// Strings
+	"string_gte": "YJ5001",
+	"string_lte": "YJ5002",
+	"pattern":    "YJ5003",
+	"format":     "YJ5004",
+
+	// Numbers
+	"multiple_of": "YJ6001",
+	"number_gte":  "YJ6002",
+	"number_gt":   "YJ6003",
+	"number_lte":  "YJ6004",
+	"number_lt":   "YJ6005",
+
+	// Documents
+	"tab_indentation": "YJ7001",
+	"deprecated":      "YJ7002",
+
+	// Schemas, reported by `yajsv lint`
+	"unresolved_ref": "YJ8001",
+	"invalid_schema": "YJ8002",
+
+	"unsupported_dialect": "YJ8003",
+	"unknown_keyword":     "YJ8004",
+}
+
+// unknownErrorCode is used for failure types missing from errorCodes
+const unknownErrorCode = "YJ9999"
+
+// errorCode returns the stable error code for the failure type.
+func errorCode(typ string) string {
+	if code, ok := errorCodes[typ]; ok {
+		return code
+	}
+	return unknownErrorCode
+}
+
+// errorKeywords maps gojsonschema failure types to the schema keyword that
+// produced them. The false schema and internal errors have no keyword.
+var errorKeywords = map[string]string{
+	"false":        "",
+	"required":     "required",
+	"invalid_type": "type",
+	"const":        "const",
+	"enum":         "enum",
+	"internal":     "",
+
+	"number_any_of":  "anyOf",
+	"number_one_of":  "oneOf",
+	"number_all_of":  "allOf",
+	"number_not":     "not",
+	"condition_then": "then",
+	"condition_else": "else",
+
+	"array_no_additional_items": "additionalItems",
+	"array_min_items":           "minItems",
+	"array_max_items":           "maxItems",
+	"unique":                    "uniqueItems",
+	"contains":                  "contains",
+
+	"missing_dependency":              "dependencies",
+	"array_min_properties":            "minProperties",
+	"array_max_properties":            "maxProperties",
+	"additional_property_not_allowed": "additionalProperties",
+	"invalid_property_pattern":        "patternProperties",
+	"invalid_property_name":           "propertyNames",
+	"duplicate_key":                   "",
+
+	"string_gte": "minLength",
+	"string_lte": "maxLength",
+	"pattern":    "pattern",
+	"format":     "format",
+
+	"multiple_of": "multipleOf",
+	"number_gte":  "minimum",
+	"number_gt":   "exclusiveMinimum",
+	"number_lte":  "maximum",
+	"number_lt":   "exclusiveMaximum",
+
+	"tab_indentation": "",
+	"deprecated":      "deprecated",
+	"unresolved_ref":  "",
+	"invalid_schema":  "",
+
+	"unsupported_dialect": "",
+	"unknown_keyword":     "",
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// command is a subcommand of yajsv, e.g. `yajsv lint`. The flags are shared
+// by all of them, but only those that apply may be given for each.
+type command struct {
+	name    string
+	args    string
+	summary string
+	help    string
+	applies func(flag string) bool
+}
+
+// documentFlags only apply to validating documents
+var documentFlags = map[string]bool{
+	"any-schema": true, "apply-defaults": true, "auto-schema": true, "catalog": true,
+	"catalog-url": true, "cbor-bytes": true, "crd": true, "csv-infer": true,
+	"fail-deprecated": true, "ini-key-separator": true, "input-format": true, "limit": true,
+	"max-file-size": true, "s": true, "schema": true, "schema-cache": true, "schema-map": true,
+	"sheet": true, "strict-data": true, "strict-json": true, "strict-yaml": true,
+	"timeout": true, "xml-attr-prefix": true, "xml-text-key": true,
+}
+
+// schemaLoadFlags apply to loading schemas and the documents their refs resolve
+// to, and so to every command
+var schemaLoadFlags = map[string]bool{
+	"b": true, "base-uri": true, "cache-dir": true, "cache-ttl": true, "config": true,
+	"debug-refs": true, "http-proxy": true, "http-retries": true, "http-timeout": true,
+	"jsonc": true, "offline": true, "r": true, "ref": true, "registry-auth": true, "registry-url": true,
+	"schema-header": true, "v": true, "version": true, "yaml-version": true,
+}
+
+// commands are the subcommands of yajsv, the first of which is the default
+var commands = []command{{
+	name:    "validate",
+	args:    "-s schema.(json|yml|toml|json5) [options] document.(json|yml|toml|json5|xml|hcl|tf|csv|xlsx|ods|msgpack|cbor|bson|avro|parquet|ini|properties) ...",
+	summary: "validate documents against schemas, the default",
+	help: `  yajsv validates JSON, JSON5, YAML, TOML, XML and HCL document(s) against
+  a schema. One of three status results are reported per document:
+
+    pass: Document is valid relative to the schema
+    fail: Document is invalid relative to the schema
+    error: Document is malformed, e.g. not valid JSON, YAML or TOML
+
+  The 'fail' status may be reported multiple times per-document, once for each
+  schema validation failure. Non-fatal findings are reported as warnings, e.g.
+  'pass with warnings', and don't affect the exit code unless
+  -warnings-as-errors reports them as failures.
+
+  Sets the exit code to 1 on any failures, 2 on any errors, 3 on both, 4 on
+  invalid usage, 5 on schema definition or file-list errors. Otherwise, 0 is
+  returned if everything passes validation. The first two can be changed with
+  -fail-exit-code and -error-exit-code, or ignored with -exit-zero.
+`,
+	applies: func(string) bool { return true },
+}, {
+	name:    "lint",
+	args:    "[options] schema...",
+	summary: "check schemas against the meta-schema of their draft, along with their $refs",
+	help: `  yajsv lint checks the schemas themselves against the meta-schema of their
+  draft, along with their $refs, rather than validating documents. Findings
+  are reported like the failures of documents, with the same exit codes.
+`,
+	applies: func(name string) bool { return !documentFlags[name] },
+}, {
+	name:    "bundle",
+	args:    "[options] schema",
+	summary: "print a schema as JSON with every document its $refs resolve to inlined",
+	help: `  yajsv bundle prints the schema as JSON with every document its $refs
+  resolve to, including -r schemas, inlined as local definitions.
+`,
+	applies: func(name string) bool { return schemaLoadFlags[name] },
+}}
+
+// currentCommand is the command being run, for its usage
+var currentCommand = commands[0]
+
+// lookupCommand returns the command called name
+func lookupCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// checkCommandFlags returns an error for the first flag given on the command
+// line that doesn't apply to the current command. Flags set by the config or
+// environment are shared by all commands and so may not apply.
+func checkCommandFlags() error {
+	var err error
+	flag.Visit(func(f *flag.Flag) {
+		if err == nil && !currentCommand.applies(f.Name) {
+			err = fmt.Errorf("-%s doesn't apply to %s", f.Name, currentCommand.name)
+		}
+	})
+	return err
+}
+
+// helpMain prints the usage of the command named by the argument, or of the
+// default command along with the others.
+func helpMain(args []string) int {
+	if len(args) > 0 {
+		c, ok := lookupCommand(args[0])
+		if !ok {
+			return usageError(fmt.Sprintf("unknown command: %s", args[0]))
+		}
+		currentCommand = c
+	}
+	printUsage()
+	return 0
+}
+
+func printUsage() {
+	c := currentCommand
+	name := os.Args[0]
+	if c.name != commands[0].name {
+		name += " " + c.name
+	}
+	fmt.Fprintf(os.Stderr, "Usage: %s %s\n\n%s\n", name, c.args, c.help)
+	if c.name == commands[0].name {
+		fmt.Fprintf(os.Stderr, "Commands:\n\n")
+		for _, c := range commands {
+			fmt.Fprintf(os.Stderr, "  %-10s%s\n", c.name, c.summary)
+		}
+		fmt.Fprintf(os.Stderr, "\n  Use '%s help command' for the usage and options of each.\n\n", os.Args[0])
+	}
+	fmt.Fprintf(os.Stderr, "Options:\n\n")
+
+	// Only print the flags that apply to the command
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	flag.VisitAll(func(f *flag.Flag) {
+		if c.applies(f.Name) {
+			fs.Var(f.Value, f.Name, f.Usage)
+			fs.Lookup(f.Name).DefValue = f.DefValue
+		}
+	})
+	fs.PrintDefaults()
+	fmt.Fprintln(os.Stderr)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// decompressors map the extensions of compressed files to a reader for the
+// decompressed contents
+var decompressors = map[string]func(io.Reader) (io.Reader, error){
+	".gz": func(r io.Reader) (io.Reader, error) {
+		return gzip.NewReader(r)
+	},
+	".zst": func(r io.Reader) (io.Reader, error) {
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	},
+}
+
+// decompress expands buf when path has a compressed extension like `.gz` or
+// `.zst`, returning the contents along with path minus that extension for
+// detecting the format of the document, e.g. `data.json` for `data.json.gz`.
+func decompress(path string, buf []byte) ([]byte, string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	open, ok := decompressors[ext]
+	if !ok {
+		return buf, path, nil
+	}
+	r, err := open(bytes.NewReader(buf))
+	if err != nil {
+		return nil, path, err
+	}
+	buf, err = readDoc(r)
+	if err != nil {
+		return nil, path, err
+	}
+	return buf, path[:len(path)-len(ext)], nil
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// configFile is the project configuration read from the working directory
+// when no `-config` is given
+const configFile = ".yajsv.yaml"
+
+// configAliases are the friendlier names of flags in configuration files
+var configAliases = map[string]string{
+	"schemas": "s",
+	"refs":    "r",
+}
+
+// trustedFlags run commands, load code, write files, send credentials or
+// choose where requests carrying them go, so they're only taken from the
+// command line, the environment or an explicit `-config` rather than a
+// `.yajsv.yaml` of an untrusted checkout, e.g. in CI for a pull request
+// from a fork
+var trustedFlags = map[string]bool{
+	"apply-defaults": true, "cache-dir": true, "format-command": true, "format-plugin": true,
+	"http-proxy": true, "output": true, "registry-auth": true, "registry-url": true,
+	"report": true, "schema-header": true,
+}
+
+// envPrefix prefixes the environment variables setting flags, e.g.
+// YAJSV_SCHEMA or YAJSV_MAX_ERRORS
+const envPrefix = "YAJSV_"
+
+// applyConfig sets the flags that weren't given on the command line from
+// the environment, and then from the `-config` file, or `.yajsv.yaml` if
+// present, along with the document arguments of its `documents` if none
+// were given to `yajsv validate`. Only a `-config` given on the command line
+// or by the environment, which don't come from the checkout, may set the
+// trustedFlags.
+func applyConfig() error {
+	set := make(map[interface{}]bool)
+	flag.Visit(func(f *flag.Flag) { set[flagTarget(f.Value)] = true })
+	for _, kv := range envSettings() {
+		env, name, value := kv[0], kv[1], kv[2]
+		if set[flagTarget(flag.Lookup(name).Value)] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("$%s: invalid value %q for %s: %s", env, value, name, err)
+		}
+	}
+	// Environment variables take precedence over the config too
+	flag.Visit(func(f *flag.Flag) { set[flagTarget(f.Value)] = true })
+
+	path, explicit := *configFlag, *configFlag != ""
+	if path == "" {
+		if _, err := os.Stat(configFile); err != nil {
+			return nil
+		}
+		path = configFile
+	}
+	settings, docs, err := loadConfig(path)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	for _, kv := range settings {
+		name, value := kv[0], kv[1]
+		if trustedFlags[name] && !explicit {
+			return fmt.Errorf("%s: %s can only be set on the command line, the environment or by an explicit -config", path, name)
+		}
+		if set[flagTarget(flag.Lookup(name).Value)] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("%s: invalid value %q for %s: %s", path, value, name, err)
+		}
+	}
+	// Other commands take schemas as arguments rather than documents
+	if flag.NArg() == 0 && len(docs) > 0 && currentCommand.name == "validate" {
+		flag.CommandLine.Parse(append([]string{"--"}, docs...))
+	}
+	return nil
+}
+
+// envSettings returns the variable, flag name and value of the non-empty
+// YAJSV_ environment variables, named after the flags or their configAliases in
+// upper case with dashes as underscores. Repeatable flags take comma
+// separated lists, e.g. YAJSV_REFS=defs/,common.json.
+func envSettings() [][3]string {
+	names := make(map[string]string)
+	flag.VisitAll(func(f *flag.Flag) { names[envVar(f.Name)] = f.Name })
+	for alias, name := range configAliases {
+		names[envVar(alias)] = name
+	}
+	vars := make([]string, 0, len(names))
+	for v := range names {
+		vars = append(vars, v)
+	}
+	sort.Strings(vars)
+
+	var settings [][3]string
+	for _, v := range vars {
+		value := os.Getenv(v)
+		if value == "" {
+			continue
+		}
+		name := names[v]
+		if _, ok := flag.Lookup(name).Value.(*stringFlags); ok {
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					settings = append(settings, [3]string{v, name, item})
+				}
+			}
+			continue
+		}
+		settings = append(settings, [3]string{v, name, value})
+	}
+	return settings
+}
+
+// envVar returns the environment variable for the flag or alias name
+func envVar(name string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// flagTarget returns the variable a flag sets, shared by aliases like `-o`
+// and `-format` or `-q` and `-qq`
+func flagTarget(v flag.Value) interface{} {
+	var target interface{} = v
+	switch v := v.(type) {
+	case negatedFlag:
+		target = v.b
+	case quietSetter:
+		target = v.q
+	}
+	if rv := reflect.ValueOf(target); rv.Kind() == reflect.Ptr {
+		return rv.Pointer()
+	}
+	return target
+}
+
+// loadConfig reads the JSON or YAML configuration file at path, an object
+// of flag names, or their configAliases, to values, along with the document
+// globs of `documents`, e.g.
+//
+//	schema: schema.json
+//	refs: [defs/]
+//	documents: ["configs/*.yaml"]
+//	exclude: ["configs/*.local.yaml"]
+//	o: json
+//
+// Lists set repeatable flags once for each value. Returns the flag names
+// and values in order.
+func loadConfig(path string) ([][2]string, []string, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if buf, err = toJSON(path, buf); err != nil {
+		return nil, nil, err
+	}
+	var cfg map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, nil, fmt.Errorf("expected an object of flags: %s", err)
+	}
+	keys := make([]string, 0, len(cfg))
+	for k := range cfg {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var settings [][2]string
+	var docs []string
+	for _, k := range keys {
+		values, ok := cfg[k].([]interface{})
+		if !ok {
+			values = []interface{}{cfg[k]}
+		}
+		if k == "documents" {
+			for _, v := range values {
+				docs = append(docs, fmt.Sprint(v))
+			}
+			continue
+		}
+		name := k
+		if alias, ok := configAliases[k]; ok {
+			name = alias
+		}
+		if flag.Lookup(name) == nil || name == "config" {
+			return nil, nil, fmt.Errorf("unknown flag %s", k)
+		}
+		for _, v := range values {
+			switch v.(type) {
+			case []interface{}, map[string]interface{}:
+				return nil, nil, fmt.Errorf("%s: expected a value or list of values", k)
+			}
+			settings = append(settings, [2]string{name, fmt.Sprint(v)})
+		}
+	}
+	return settings, docs, nil
+}
+
+// excludeDocs removes the documents matching any `-exclude` glob. Patterns
+// without a `/` match base names and others any trailing directories of a
+// path, like the `fileMatch`es of a catalog.
+func excludeDocs(docs []string) []string {
+	patterns := excludePatterns(false)
+	if len(patterns) == 0 {
+		return docs
+	}
+	kept := docs[:0]
+	for _, doc := range docs {
+		if !excluded(patterns, doc) {
+			kept = append(kept, doc)
+		}
+	}
+	return kept
+}
+
+// excludePatterns compiles the `-exclude` globs. For directories, those
+// ending with `/**` match the directory itself, e.g. `**/node_modules/**`
+// matches `web/node_modules`.
+func excludePatterns(dirs bool) []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, len(excludeFlags))
+	for i, p := range excludeFlags {
+		p = filepath.ToSlash(p)
+		if dirs {
+			p = strings.TrimSuffix(p, "/**")
+		}
+		patterns[i] = catalogGlob(p)
+	}
+	return patterns
+}
+
+// excluded reports if the path matches any of the patterns
+func excluded(patterns []*regexp.Regexp, path string) bool {
+	slashed := filepath.ToSlash(filepath.Clean(path))
+	for _, re := range patterns {
+		if re.MatchString(slashed) {
+			return true
+		}
+	}
+	return false
+}
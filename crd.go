@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+)
+
+// crdSchemas are the compiled schemas of the `-crd` custom resource
+// definitions by the `group/version/kind` of the resources they apply to.
+var crdSchemas map[string]compiledSchema
+
+// crd is the subset of a CustomResourceDefinition, v1 or v1beta1, needed to
+// select the schema of a resource
+type crd struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		Group string `json:"group"`
+		Names struct {
+			Kind string `json:"kind"`
+		} `json:"names"`
+		Version    string       `json:"version"`
+		Validation *crdSchema   `json:"validation"`
+		Versions   []crdVersion `json:"versions"`
+	} `json:"spec"`
+}
+
+type crdVersion struct {
+	Name   string     `json:"name"`
+	Schema *crdSchema `json:"schema"`
+}
+
+type crdSchema struct {
+	OpenAPIV3Schema interface{} `json:"openAPIV3Schema"`
+}
+
+// loadCRDs compiles the schema of every version of the custom resource
+// definitions in the files at paths, which may be YAML streams of several.
+func loadCRDs(paths []string) (map[string]compiledSchema, error) {
+	schemas := make(map[string]compiledSchema)
+	for _, path := range paths {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: unable to load CRD: %s", path, err)
+		}
+		docs := []yamlDocument{{src, 0}}
+		if docFormat(path, src) == formatYAML {
+			docs = splitYAML(src)
+		}
+		for i, d := range docs {
+			name := path
+			if len(docs) > 1 {
+				name = fmt.Sprintf("%s[doc%d]", path, i+1)
+			}
+			buf, err := toJSON(path, d.src)
+			if err != nil {
+				return nil, fmt.Errorf("%s: unable to load CRD: %s", name, err)
+			}
+			var def crd
+			dec := json.NewDecoder(bytes.NewReader(buf))
+			dec.UseNumber()
+			if err := dec.Decode(&def); err != nil {
+				return nil, fmt.Errorf("%s: unable to load CRD: %s", name, err)
+			}
+			if def.Kind != "CustomResourceDefinition" {
+				continue // e.g. the namespace or RBAC resources of an operator
+			}
+
+			// v1beta1 CRDs may share a single schema among their versions
+			versions := def.Spec.Versions
+			if len(versions) == 0 {
+				versions = []crdVersion{{Name: def.Spec.Version}}
+			}
+			for i, v := range versions {
+				ptr := fmt.Sprintf("/spec/versions/%d/schema/openAPIV3Schema", i)
+				schema := v.Schema
+				if schema == nil {
+					ptr, schema = "/spec/validation/openAPIV3Schema", def.Spec.Validation
+				}
+				if schema == nil || schema.OpenAPIV3Schema == nil {
+					continue
+				}
+				doc := copyJSON(schema.OpenAPIV3Schema)
+				walkSchema(doc, &url.URL{}, convertCRDSchema)
+				cs, err := compileSchema(schemaSource{path: name + "#" + ptr, doc: doc}, nil)
+				if err != nil {
+					return nil, err
+				}
+				schemas[def.Spec.Group+"/"+v.Name+"/"+def.Spec.Names.Kind] = cs
+			}
+		}
+	}
+	return schemas, nil
+}
+
+// convertCRDSchema rewrites the Kubernetes extensions of a structural schema
+// as JSON Schema, along with `nullable` like OpenAPI schemas. Unknown fields
+// are allowed, since the API server prunes rather than rejects them, unless
+// `-strict-data` closes the objects without `x-kubernetes-preserve-unknown-fields`.
+func convertCRDSchema(ptr string, base *url.URL, schema map[string]interface{}) {
+	convertOpenAPISchema(ptr, base, schema)
+	if v, _ := schema["x-kubernetes-int-or-string"].(bool); v {
+		if _, ok := schema["anyOf"]; !ok {
+			schema["anyOf"] = []interface{}{
+				map[string]interface{}{"type": "integer"},
+				map[string]interface{}{"type": "string"},
+			}
+		}
+		delete(schema, "type")
+	}
+	if v, _ := schema["x-kubernetes-preserve-unknown-fields"].(bool); v {
+		if _, ok := schema["additionalProperties"]; !ok {
+			schema["additionalProperties"] = true
+		}
+	}
+	if v, _ := schema["x-kubernetes-embedded-resource"].(bool); v {
+		required, _ := schema["required"].([]interface{})
+		schema["required"] = append(required, "apiVersion", "kind")
+	}
+}
+
+// resourceSchema returns the `-crd` schema for the resource in the JSON text
+// buf by its apiVersion and kind.
+func resourceSchema(buf []byte) (schemaSet, error) {
+	var res struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+	}
+	if err := json.Unmarshal(buf, &res); err != nil || res.APIVersion == "" || res.Kind == "" {
+		return nil, fmt.Errorf("no apiVersion and kind to select a -crd schema by")
+	}
+	schema, ok := crdSchemas[res.APIVersion+"/"+res.Kind]
+	if !ok {
+		return nil, fmt.Errorf("no -crd schema for %s %s", res.APIVersion, res.Kind)
+	}
+	return schemaSet{schema}, nil
+}
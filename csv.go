@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// csvValue converts a CSV field to a JSON value. Fields are strings unless
+// `-csv-infer` is set, in which case numbers and booleans are converted and
+// empty fields are omitted, e.g. so `required` applies to them.
+func csvValue(field string) (interface{}, bool) {
+	if !*csvInferFlag {
+		return field, true
+	}
+	switch field {
+	case "":
+		return nil, false
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	if n := json.Number(field); json.Valid([]byte(n)) {
+		return n, true
+	}
+	return field, true
+}
+
+// validateCSV validates each record of the CSV text src as an object keyed
+// by the names in the header row. Records are reported by line number, e.g.
+// `data.csv:3`, with failures located at the offending field.
+func validateCSV(schema schemaSet, path string, src []byte) []result {
+	rd := csv.NewReader(bytes.NewReader(src))
+	header, err := rd.Read()
+	if err == io.EOF {
+		return nil // an empty file has no records
+	}
+	if err != nil {
+		return []result{{Path: path, Status: statusError, Error: fmt.Sprintf("load doc: %s", err)}}
+	}
+
+	var results []result
+	for {
+		start := time.Now()
+		record, err := rd.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := rd.FieldPos(0)
+		name := fmt.Sprintf("%s:%d", path, line)
+		if err != nil {
+			var perr *csv.ParseError
+			if errors.As(err, &perr) {
+				line = perr.StartLine
+				name = fmt.Sprintf("%s:%d", path, line)
+			}
+			results = append(results, result{Path: name, Status: statusError, Error: fmt.Sprintf("load doc: %s", err), file: path, line: line})
+			if !errors.Is(err, csv.ErrFieldCount) {
+				break // the reader can't recover from syntax errors
+			}
+			continue
+		}
+
+		obj := make(map[string]interface{}, len(record))
+		pos := map[string]position{"": {line, 1}}
+		for i, field := range record {
+			v, ok := csvValue(field)
+			if !ok {
+				continue
+			}
+			obj[header[i]] = v
+			l, c := rd.FieldPos(i)
+			pos["/"+pointerEscaper.Replace(header[i])] = position{l, c}
+		}
+		buf, err := json.Marshal(obj)
+		if err != nil {
+			results = append(results, result{Path: name, Status: statusError, Error: fmt.Sprintf("load doc: %s", err), file: path, line: line})
+			continue
+		}
+		r := validateJSON(schema, name, buf, len(buf), start, func() map[string]position { return pos })
+		r.file, r.line = path, line
+		results = append(results, r)
+	}
+	return results
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// maxDefaultsDepth bounds the `$ref`s followed while applying defaults, e.g.
+// for recursive schemas
+const maxDefaultsDepth = 64
+
+// withDefaults returns the JSON text of the document in buf with the
+// `default`s of the schemas filled in for missing properties. Only the
+// subschemas that apply unconditionally are followed, i.e. those of
+// `properties`, `items`, `allOf` and `$ref`s within the same schema
+// document, since those of `anyOf`, `oneOf` and `if` depend on which match.
+func withDefaults(schemas schemaSet, buf []byte) ([]byte, error) {
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	for _, s := range schemas {
+		if root, ok := resolvePointer(s.doc, s.root); ok {
+			doc = fillDefaults(s.doc, root, doc, 0)
+		}
+	}
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// fillDefaults applies the defaults of schema, a subschema of the schema
+// document schemaDoc, to the value v, returning the updated value.
+func fillDefaults(schemaDoc, schema, v interface{}, depth int) interface{} {
+	s, ok := schema.(map[string]interface{})
+	if !ok || depth > maxDefaultsDepth {
+		return v
+	}
+	if ref, ok := s["$ref"].(string); ok {
+		if target, ok := localRef(schemaDoc, ref); ok {
+			v = fillDefaults(schemaDoc, target, v, depth+1)
+		}
+	}
+	if allOf, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			v = fillDefaults(schemaDoc, sub, v, depth+1)
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		props, _ := s["properties"].(map[string]interface{})
+		for name, sub := range props {
+			if _, ok := v[name]; !ok {
+				if def, ok := schemaDefault(schemaDoc, sub, depth); ok {
+					v[name] = copyJSON(def)
+				}
+			}
+			if child, ok := v[name]; ok {
+				v[name] = fillDefaults(schemaDoc, sub, child, depth+1)
+			}
+		}
+		return v
+	case []interface{}:
+		switch items := s["items"].(type) {
+		case map[string]interface{}:
+			for i := range v {
+				v[i] = fillDefaults(schemaDoc, items, v[i], depth+1)
+			}
+		case []interface{}:
+			for i := 0; i < len(v) && i < len(items); i++ {
+				v[i] = fillDefaults(schemaDoc, items[i], v[i], depth+1)
+			}
+		}
+		return v
+	}
+	return v
+}
+
+// schemaDefault returns the `default` of the schema, or of the schema its
+// `$ref` points to.
+func schemaDefault(schemaDoc, schema interface{}, depth int) (interface{}, bool) {
+	for ; depth <= maxDefaultsDepth; depth++ {
+		s, ok := schema.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if def, ok := s["default"]; ok {
+			return def, true
+		}
+		ref, ok := s["$ref"].(string)
+		if !ok {
+			return nil, false
+		}
+		if schema, ok = localRef(schemaDoc, ref); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// localRef resolves a `$ref` to a JSON pointer within the schema document
+func localRef(schemaDoc interface{}, ref string) (interface{}, bool) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, false
+	}
+	ptr, err := url.PathUnescape(ref[1:])
+	if err != nil {
+		return nil, false
+	}
+	return resolvePointer(schemaDoc, ptr)
+}
+
+// writeDefaults writes the passing documents of the results with their
+// defaults applied, to w for a `-apply-defaults` of `-` or otherwise under
+// that directory, mirroring the relative paths of the files as JSON. Files
+// of several documents get each of them in turn.
+func writeDefaults(dir string, w io.Writer, results []result) error {
+	var files []string
+	docs := make(map[string][][]byte)
+	for _, r := range results {
+		if r.defaulted == nil {
+			continue
+		}
+		p := r.filePath()
+		if _, ok := docs[p]; !ok {
+			files = append(files, p)
+		}
+		docs[p] = append(docs[p], r.defaulted)
+	}
+	for _, p := range files {
+		if dir == "-" {
+			for _, doc := range docs[p] {
+				if _, err := w.Write(doc); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		out := filepath.Join(dir, defaultsPath(p))
+		if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(out, bytes.Join(docs[p], nil), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultsPath returns the relative path under the `-apply-defaults`
+// directory for the document file at p, with a .json extension. Files
+// outside the working directory, URLs and stdin use their base name.
+func defaultsPath(p string) string {
+	switch {
+	case p == stdinPath:
+		p = "stdin"
+	case isURL(p) || isObjectURI(p):
+		if u, err := url.Parse(p); err == nil {
+			p = path.Base(u.Path)
+		}
+	case filepath.IsAbs(p) || strings.HasPrefix(filepath.Clean(p), ".."):
+		p = filepath.Base(p)
+	}
+	p = filepath.Clean(p)
+	if _, ok := decompressors[strings.ToLower(filepath.Ext(p))]; ok {
+		p = strings.TrimSuffix(p, filepath.Ext(p))
+	}
+	return strings.TrimSuffix(p, filepath.Ext(p)) + ".json"
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// deprecatedFailures returns a failure for each value of the document in
+// buf that's described by a schema with `"deprecated": true`, positioned by
+// the lazily computed positions. Like defaults, only the subschemas that
+// apply unconditionally are followed, i.e. those of `properties`, `items`,
+// `allOf` and local `$ref`s.
+func deprecatedFailures(schemas schemaSet, buf []byte, positions func() map[string]position) []failure {
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil
+	}
+	found := make(map[string]string)
+	for _, s := range schemas {
+		if root, ok := resolvePointer(s.doc, s.root); ok {
+			findDeprecated(s.doc, root, doc, "", 0, found)
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	pos := positions()
+	ptrs := make([]string, 0, len(found))
+	for ptr := range found {
+		ptrs = append(ptrs, ptr)
+	}
+	sort.Strings(ptrs)
+
+	fs := make([]failure, len(ptrs))
+	for i, ptr := range ptrs {
+		desc := "Deprecated"
+		if found[ptr] != "" {
+			desc += ": " + found[ptr]
+		}
+		fs[i] = lintFailure("deprecated", ptr, desc, pos)
+	}
+	sort.SliceStable(fs, func(i, j int) bool {
+		if fs[i].Line != fs[j].Line {
+			return fs[i].Line < fs[j].Line
+		}
+		return fs[i].Column < fs[j].Column
+	})
+	return fs
+}
+
+// findDeprecated records the pointer of v, at ptr, in found if schema, a
+// subschema of schemaDoc, deprecates it, along with the schema's description,
+// and then does the same for the values within it.
+func findDeprecated(schemaDoc, schema, v interface{}, ptr string, depth int, found map[string]string) {
+	s, ok := schema.(map[string]interface{})
+	if !ok || depth > maxDefaultsDepth {
+		return
+	}
+	if deprecated, _ := s["deprecated"].(bool); deprecated {
+		if desc, _ := s["description"].(string); desc != "" || found[ptr] == "" {
+			found[ptr] = desc
+		}
+	}
+	if ref, ok := s["$ref"].(string); ok {
+		if target, ok := localRef(schemaDoc, ref); ok {
+			findDeprecated(schemaDoc, target, v, ptr, depth+1, found)
+		}
+	}
+	if allOf, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			findDeprecated(schemaDoc, sub, v, ptr, depth+1, found)
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		props, _ := s["properties"].(map[string]interface{})
+		for name, sub := range props {
+			if child, ok := v[name]; ok {
+				findDeprecated(schemaDoc, sub, child, ptr+"/"+pointerEscaper.Replace(name), depth+1, found)
+			}
+		}
+	case []interface{}:
+		switch items := s["items"].(type) {
+		case map[string]interface{}:
+			for i := range v {
+				findDeprecated(schemaDoc, items, v[i], fmt.Sprintf("%s/%d", ptr, i), depth+1, found)
+			}
+		case []interface{}:
+			for i := 0; i < len(v) && i < len(items); i++ {
+				findDeprecated(schemaDoc, items[i], v[i], fmt.Sprintf("%s/%d", ptr, i), depth+1, found)
+			}
+		}
+	}
+}
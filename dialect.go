@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// metaSchemaURLs are the meta-schemas of the drafts gojsonschema supports,
+// which it embeds rather than fetching. Schemas without a `$schema`, or an
+// unsupported one, are validated as the last.
+var metaSchemaURLs = []string{
+	"http://json-schema.org/draft-04/schema",
+	"http://json-schema.org/draft-06/schema",
+	"http://json-schema.org/draft-07/schema",
+}
+
+// unsupportedDialects names the other drafts in warnings
+var unsupportedDialects = map[string]string{
+	"http://json-schema.org/draft-03/schema":      "draft-03",
+	"http://json-schema.org/draft/2019-09/schema": "2019-09",
+	"http://json-schema.org/draft/2020-12/schema": "2020-12",
+}
+
+// ignoredKeywords are the keywords of later drafts that gojsonschema skips
+// over, validating anything against them.
+var ignoredKeywords = []string{
+	"$recursiveRef", "dependentRequired", "dependentSchemas", "maxContains",
+	"minContains", "prefixItems", "unevaluatedItems", "unevaluatedProperties",
+}
+
+// annotationKeywords are the keywords, other than those with subschemas,
+// known to `-strict-schema` across the drafts, including OpenAPI's
+var annotationKeywords = []string{
+	"$anchor", "$comment", "$dynamicAnchor", "$dynamicRef", "$id", "$recursiveAnchor",
+	"$recursiveRef", "$ref", "$schema", "$vocabulary", "const", "contentEncoding",
+	"contentMediaType", "default", "dependentRequired", "deprecated", "description",
+	"enum", "examples", "exclusiveMaximum", "exclusiveMinimum", "format", "id",
+	"maxContains", "maxItems", "maxLength", "maxProperties", "maximum", "minContains",
+	"minItems", "minLength", "minProperties", "minimum", "multipleOf", "pattern",
+	"readOnly", "required", "title", "type", "uniqueItems", "writeOnly",
+
+	"discriminator", "example", "externalDocs", "nullable", "xml",
+}
+
+// knownKeywords are the keywords `-strict-schema` accepts
+var knownKeywords = func() map[string]bool {
+	known := make(map[string]bool)
+	for _, kws := range [][]string{schemaKeywords, schemaArrayKeywords, schemaMapKeywords, annotationKeywords} {
+		for _, kw := range kws {
+			known[kw] = true
+		}
+	}
+	return known
+}()
+
+// unknownKeywords returns the pointers to the keywords of the schema doc
+// that aren't in any draft, e.g. typos like `require`, in order. Extensions
+// prefixed with `x-` and `-keywords` bindings are allowed.
+func unknownKeywords(doc interface{}) []string {
+	var ptrs []string
+	walkSchema(doc, &url.URL{}, func(ptr string, base *url.URL, schema map[string]interface{}) {
+		kws := make([]string, 0, len(schema))
+		for kw := range schema {
+			if _, ok := customKeywords[kw]; !ok && !knownKeywords[kw] && !strings.HasPrefix(kw, "x-") {
+				kws = append(kws, kw)
+			}
+		}
+		sort.Strings(kws)
+		for _, kw := range kws {
+			ptrs = append(ptrs, ptr+"/"+pointerEscaper.Replace(kw))
+		}
+	})
+	return ptrs
+}
+
+// schemaDialect normalizes the `$schema` URI of a schema for comparison,
+// ignoring any empty fragment and the scheme, e.g. https.
+func schemaDialect(uri string) string {
+	uri = strings.TrimSuffix(uri, "#")
+	return "http://" + strings.TrimPrefix(strings.TrimPrefix(uri, "http://"), "https://")
+}
+
+// dialectWarning returns a warning if the `$schema` of the schema doc names
+// a draft that isn't fully supported, listing any keywords it uses that
+// are ignored as a result, or "" otherwise.
+func dialectWarning(doc interface{}) string {
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	uri, ok := m["$schema"].(string)
+	if !ok {
+		return ""
+	}
+	dialect := schemaDialect(uri)
+	for _, u := range metaSchemaURLs {
+		if dialect == u {
+			return ""
+		}
+	}
+
+	name, ok := unsupportedDialects[dialect]
+	if !ok {
+		name = "unknown dialect"
+	}
+	msg := fmt.Sprintf("$schema %s (%s) is not fully supported, validating as draft-07", uri, name)
+
+	used := make(map[string]bool)
+	walkSchema(doc, &url.URL{}, func(ptr string, base *url.URL, schema map[string]interface{}) {
+		for _, kw := range ignoredKeywords {
+			if _, ok := schema[kw]; ok {
+				used[kw] = true
+			}
+		}
+	})
+	if len(used) > 0 {
+		kws := make([]string, 0, len(used))
+		for kw := range used {
+			kws = append(kws, kw)
+		}
+		sort.Strings(kws)
+		msg += " and ignoring " + strings.Join(kws, ", ")
+	}
+	return msg
+}
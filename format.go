@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// Document formats, see docFormat
+const (
+	formatJSON       = "json"
+	formatJSONC      = "jsonc"
+	formatJSON5      = "json5"
+	formatNDJSON     = "ndjson"
+	formatYAML       = "yaml"
+	formatTOML       = "toml"
+	formatXML        = "xml"
+	formatHCL        = "hcl"
+	formatINI        = "ini"
+	formatProperties = "properties"
+	formatCSV        = "csv"
+	formatXLSX       = "xlsx"
+	formatODS        = "ods"
+	formatMsgpack    = "msgpack"
+	formatCBOR       = "cbor"
+	formatBSON       = "bson"
+	formatAvro       = "avro"
+	formatParquet    = "parquet"
+)
+
+// formats are the names of the supported document formats
+var formats = []string{
+	formatJSON, formatJSONC, formatJSON5, formatNDJSON, formatYAML, formatTOML, formatXML, formatHCL,
+	formatINI, formatProperties, formatCSV, formatXLSX, formatODS, formatMsgpack, formatCBOR, formatBSON, formatAvro, formatParquet,
+}
+
+// isFormat reports if name is one of the supported document formats.
+func isFormat(name string) bool {
+	for _, f := range formats {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// formatExtensions maps file extensions to document formats
+var formatExtensions = map[string]string{
+	".json":       formatJSON,
+	".jsonc":      formatJSONC,
+	".json5":      formatJSON5,
+	".ndjson":     formatNDJSON,
+	".jsonl":      formatNDJSON,
+	".yml":        formatYAML,
+	".yaml":       formatYAML,
+	".toml":       formatTOML,
+	".xml":        formatXML,
+	".hcl":        formatHCL,
+	".tf":         formatHCL,
+	".tfvars":     formatHCL,
+	".ini":        formatINI,
+	".cfg":        formatINI,
+	".properties": formatProperties,
+	".csv":        formatCSV,
+	".xlsx":       formatXLSX,
+	".xlsm":       formatXLSX,
+	".ods":        formatODS,
+	".msgpack":    formatMsgpack,
+	".mpk":        formatMsgpack,
+	".cbor":       formatCBOR,
+	".bson":       formatBSON,
+	".avro":       formatAvro,
+	".parquet":    formatParquet,
+}
+
+// docFormat returns the format of the document at path from its extension,
+// falling back to sniffing the contents buf for unknown extensions.
+func docFormat(path string, buf []byte) string {
+	if f, ok := formatExtensions[strings.ToLower(filepath.Ext(path))]; ok {
+		return f
+	}
+	return sniffFormat(buf)
+}
+
+// sniffFormat detects whether buf is JSON or YAML text from the first
+// character, after decoding UTF-16 and UTF-32 and skipping any BOM. Objects,
+// arrays and strings are JSON, anything else is assumed to be YAML since
+// it's a superset of JSON for the remaining scalars.
+func sniffFormat(buf []byte) string {
+	text := yamlDecodeUTF32(buf)
+	var bom string
+	switch {
+	case bytes.HasPrefix(text, []byte(bomUTF8)):
+		bom = bomUTF8
+	case bytes.HasPrefix(text, []byte(bomUTF16BE)):
+		bom = bomUTF16BE
+	case bytes.HasPrefix(text, []byte(bomUTF16LE)):
+		bom = bomUTF16LE
+	}
+	text = text[len(bom):]
+	for _, c := range text {
+		switch c {
+		case 0, ' ', '\t', '\r', '\n':
+			continue // skip the null bytes of UTF-16 text
+		case '{', '[', '"':
+			return formatJSON
+		}
+		return formatYAML
+	}
+	return formatJSON
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// formatFunc adapts a function to a gojsonschema.FormatChecker
+type formatFunc func(input interface{}) bool
+
+func (f formatFunc) IsFormat(input interface{}) bool {
+	return f(input)
+}
+
+// formatCheckers are the built-in and registered format checkers
+var formatCheckers = &gojsonschema.FormatCheckers
+
+// assertedFormats are the checkers of the formats that assertFormats can
+// disable, the built-in ones and those registered for `format` values, but
+// not custom keywords.
+var assertedFormats = map[string]gojsonschema.FormatChecker{
+	"date":                  gojsonschema.DateFormatChecker{},
+	"time":                  gojsonschema.TimeFormatChecker{},
+	"date-time":             gojsonschema.DateTimeFormatChecker{},
+	"hostname":              gojsonschema.HostnameFormatChecker{},
+	"email":                 gojsonschema.EmailFormatChecker{},
+	"idn-email":             gojsonschema.EmailFormatChecker{},
+	"ipv4":                  gojsonschema.IPV4FormatChecker{},
+	"ipv6":                  gojsonschema.IPV6FormatChecker{},
+	"uri":                   gojsonschema.URIFormatChecker{},
+	"uri-reference":         gojsonschema.URIReferenceFormatChecker{},
+	"iri":                   gojsonschema.URIFormatChecker{},
+	"iri-reference":         gojsonschema.URIReferenceFormatChecker{},
+	"uri-template":          gojsonschema.URITemplateFormatChecker{},
+	"uuid":                  gojsonschema.UUIDFormatChecker{},
+	"regex":                 gojsonschema.RegexFormatChecker{},
+	"json-pointer":          gojsonschema.JSONPointerFormatChecker{},
+	"relative-json-pointer": gojsonschema.RelativeJSONPointerFormatChecker{},
+}
+
+// assertFormats enables or disables the checking of `format` keywords by
+// later validations. Disabled formats are annotations, as in the spec's
+// default semantics for 2019-09 and later, and never fail a value.
+func assertFormats(enabled bool) {
+	for name, f := range assertedFormats {
+		if !enabled {
+			f = formatFunc(func(interface{}) bool { return true })
+		}
+		formatCheckers.Add(name, f)
+	}
+}
+
+// registerFormat adds a checker for values of the `format` name, replacing
+// any built-in one. Organizations can enforce house formats by calling this
+// from the init func of a file added to a build of yajsv, or with a
+// `-format-plugin`.
+func registerFormat(name string, check func(input interface{}) bool) {
+	assertedFormats[name] = formatFunc(check)
+	formatCheckers.Add(name, formatFunc(check))
+}
+
+// commandFormat checks string values of a format by running an external
+// command with the value on stdin, remembering the verdict for each value
+type commandFormat struct {
+	args []string
+
+	mu      sync.Mutex
+	results map[string]bool
+}
+
+// loadFormatCommand registers the checker of a `-format-command` of the form
+// `name=command args...`, where the command is split on whitespace. Values
+// are valid if it exits 0.
+func loadFormatCommand(arg string) error {
+	parts := strings.SplitN(arg, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || strings.TrimSpace(parts[1]) == "" {
+		return fmt.Errorf("expected name=command")
+	}
+	args := strings.Fields(parts[1])
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return err
+	}
+	f := &commandFormat{args: args, results: make(map[string]bool)}
+	registerFormat(parts[0], f.IsFormat)
+	return nil
+}
+
+// IsFormat runs the command for string values, non-strings always pass as
+// with the built-in formats. A command that can't be run fails the value.
+func (f *commandFormat) IsFormat(input interface{}) bool {
+	s, ok := input.(string)
+	if !ok {
+		return true
+	}
+	f.mu.Lock()
+	valid, ok := f.results[s]
+	f.mu.Unlock()
+	if ok {
+		return valid
+	}
+
+	cmd := exec.Command(f.args[0], f.args[1:]...)
+	cmd.Stdin = strings.NewReader(s)
+	valid = cmd.Run() == nil
+
+	f.mu.Lock()
+	f.results[s] = valid
+	f.mu.Unlock()
+	return valid
+}
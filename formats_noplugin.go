@@ -0,0 +1,15 @@
+//go:build !cgo || !(linux || darwin || freebsd)
+
+package main
+
+import "fmt"
+
+// formatPlugins is whether this build can load a `-format-plugin`, which
+// needs cgo and so a dynamically linked binary
+const formatPlugins = false
+
+// loadFormatPlugin fails in builds without plugin support, e.g. the static
+// release binaries, see formats_plugin.go.
+func loadFormatPlugin(path string) error {
+	return fmt.Errorf("format plugins aren't supported by this build of yajsv, which needs cgo on Linux, macOS or FreeBSD")
+}
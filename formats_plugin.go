@@ -0,0 +1,66 @@
+//go:build cgo && (linux || darwin || freebsd)
+
+package main
+
+import (
+	"fmt"
+	"plugin"
+	"sort"
+)
+
+// formatPlugins is whether this build can load a `-format-plugin`, which
+// needs cgo and so a dynamically linked binary
+const formatPlugins = true
+
+// loadFormatPlugin opens the Go plugin at path and registers the checkers
+// of its exported `Formats` variable, along with the `-keywords` templates
+// of its `Keywords`, called with the keyword's value in the schema, e.g.
+//
+//	var Formats = map[string]func(interface{}) bool{
+//		"semver-range": isSemverRange,
+//	}
+//
+//	var Keywords = map[string]func(arg, input interface{}) bool{
+//		"region": inRegion,
+//	}
+//
+// Plugins must be built with `go build -buildmode=plugin` by the same Go
+// version, and with the same versions of any shared dependencies, as yajsv.
+func loadFormatPlugin(path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return err
+	}
+	formats, err := p.Lookup("Formats")
+	keywords, kerr := p.Lookup("Keywords")
+	if err != nil && kerr != nil {
+		return fmt.Errorf("exports neither Formats nor Keywords")
+	}
+	if formats != nil {
+		m, ok := formats.(*map[string]func(interface{}) bool)
+		if !ok {
+			return fmt.Errorf("Formats is a %T rather than a map[string]func(interface{}) bool", formats)
+		}
+		names := make([]string, 0, len(*m))
+		for name := range *m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			registerFormat(name, (*m)[name])
+		}
+	}
+	if keywords != nil {
+		m, ok := keywords.(*map[string]func(arg, input interface{}) bool)
+		if !ok {
+			return fmt.Errorf("Keywords is a %T rather than a map[string]func(arg, input interface{}) bool", keywords)
+		}
+		for name, check := range *m {
+			check := check
+			keywordTemplates[name] = func(arg interface{}) (func(input interface{}) bool, error) {
+				return func(input interface{}) bool { return check(arg, input) }, nil
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// gitRefFlag is a string flag that may also be given without a value, e.g.
+// `-git-changed` or `-git-changed=main`
+type gitRefFlag struct {
+	set bool
+	ref string
+}
+
+func (f *gitRefFlag) String() string {
+	if f.set && f.ref == "" {
+		return "true"
+	}
+	return f.ref
+}
+
+func (f *gitRefFlag) Set(value string) error {
+	if b, err := strconv.ParseBool(value); err == nil || value == "" {
+		f.set, f.ref = b, ""
+		return nil
+	}
+	f.set, f.ref = true, value
+	return nil
+}
+
+func (f *gitRefFlag) IsBoolFlag() bool {
+	return true
+}
+
+// git runs the git command with args in the working directory, returning
+// its output or an error with its stderr.
+func git(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %s", args[0], err)
+	}
+	return out, nil
+}
+
+// gitChangedDocs returns the added, copied, modified or renamed files with
+// one of the `-ext` extensions, relative to the working directory. Without a
+// ref, those are the staged and unstaged changes to HEAD along with untracked
+// files. Otherwise they're the changes since the merge base of ref and HEAD,
+// i.e. those of a branch to be merged into ref.
+func gitChangedDocs(ref string) ([]string, error) {
+	out, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimSpace(string(out))
+
+	base := "HEAD"
+	if ref != "" {
+		out, err := git("merge-base", ref, "HEAD")
+		if err != nil {
+			return nil, err
+		}
+		base = strings.TrimSpace(string(out))
+	}
+	changed, err := git("diff", "--name-only", "-z", "--no-renames", "--diff-filter=ACMR", base, "--")
+	if err != nil {
+		return nil, err
+	}
+	if ref == "" {
+		untracked, err := git("ls-files", "-z", "--others", "--exclude-standard", "--full-name", "--", root)
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, untracked...)
+	}
+
+	return gitDocs(root, changed)
+}
+
+// gitStagedDocs returns the files with one of the `-ext` extensions that are
+// added, copied, modified or renamed in the index, relative to the working
+// directory.
+func gitStagedDocs() ([]string, error) {
+	out, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimSpace(string(out))
+	staged, err := git("diff", "--cached", "--name-only", "-z", "--no-renames", "--diff-filter=ACMR", "--")
+	if err != nil {
+		return nil, err
+	}
+	return gitDocs(root, staged)
+}
+
+// gitDocs returns the NUL separated paths of out, relative to the root of
+// the repository, that have one of the `-ext` extensions as paths relative
+// to the working directory.
+func gitDocs(root string, out []byte) ([]string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(wd); err == nil {
+		wd = resolved
+	}
+	exts := docExtensions()
+	var docs []string
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name == "" || !exts[docExt(name)] {
+			continue
+		}
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if rel, err := filepath.Rel(wd, path); err == nil {
+			path = rel
+		}
+		docs = append(docs, path)
+	}
+	return docs, nil
+}
+
+// gitStaged returns the contents of the file at path as staged in the index
+// rather than in the working tree, checking the size of the blob against the
+// `-max-file-size` before reading any of it.
+func gitStaged(path string) ([]byte, error) {
+	if !filepath.IsAbs(path) {
+		path = "./" + filepath.ToSlash(path)
+	}
+	if maxFileSize > 0 {
+		out, err := git("cat-file", "-s", ":"+path)
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if err := tooLarge(size); err != nil {
+			return nil, err
+		}
+	}
+	return git("cat-file", "blob", ":"+path)
+}
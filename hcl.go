@@ -0,0 +1,12 @@
+package main
+
+import (
+	"github.com/tmccombs/hcl2json/convert"
+)
+
+// hclToJSON converts HCL2 text, e.g. Terraform and Packer configs, to its
+// JSON representation. Expressions that can't be evaluated statically,
+// like references to variables, are kept as `${...}` template strings.
+func hclToJSON(path string, buf []byte) ([]byte, error) {
+	return convert.Bytes(buf, path, convert.Options{})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreFiles are read from each directory searched for documents, with the
+// gitignore syntax
+var ignoreFiles = []string{".gitignore", ".yajsvignore"}
+
+// ignoreRule is a pattern of an ignore file, relative to its directory
+type ignoreRule struct {
+	dir     string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// loadIgnoreRules parses the ignore files of dir, if any. Patterns follow
+// gitignore, i.e. `#` comments, `!` to re-include, a trailing `/` to only
+// match directories and a leading or inner `/` to anchor the pattern to dir
+// rather than matching names at any depth.
+func loadIgnoreRules(dir string) ([]ignoreRule, error) {
+	var rules []ignoreRule
+	for _, name := range ignoreFiles {
+		f, err := os.Open(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimRight(scanner.Text(), " \t\r")
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			r := ignoreRule{dir: dir}
+			if strings.HasPrefix(line, "!") {
+				r.negate, line = true, line[1:]
+			} else if strings.HasPrefix(line, `\`) {
+				line = line[1:] // e.g. \#file or \!file
+			}
+			if strings.HasSuffix(line, "/") {
+				r.dirOnly, line = true, strings.TrimSuffix(line, "/")
+			}
+			if strings.Contains(line, "/") {
+				r.re = regexp.MustCompile("^" + globRegexp(strings.TrimPrefix(line, "/")) + "$")
+			} else {
+				r.re = regexp.MustCompile("(^|/)" + globRegexp(line) + "$")
+			}
+			rules = append(rules, r)
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// ignored reports if the rules, in order of precedence from lowest to
+// highest, ignore the file or directory at path.
+func ignored(rules []ignoreRule, path string, isDir bool) bool {
+	ignore := false
+	for _, r := range rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(r.dir, path)
+		if err != nil || rel == "." {
+			continue
+		}
+		if r.re.MatchString(filepath.ToSlash(rel)) {
+			ignore = !r.negate
+		}
+	}
+	return ignore
+}
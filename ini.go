@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// iniToJSON converts INI or Java properties text to a JSON object of
+// string values. Keys, and INI section names, are split on
+// `-ini-key-separator` into nested objects.
+func iniToJSON(format string, buf []byte) ([]byte, error) {
+	doc, _, err := parseINI(format, buf)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// iniPositions returns the source position of every value in the INI or
+// properties text buf keyed by JSON pointer.
+func iniPositions(format string, buf []byte) map[string]position {
+	_, pos, _ := parseINI(format, buf)
+	return pos
+}
+
+// parseINI parses buf as Java properties for the properties format and as
+// INI otherwise, with `[section]` headers and `;` or `#` comments.
+func parseINI(format string, buf []byte) (map[string]interface{}, map[string]position, error) {
+	props := format == formatProperties
+	doc := make(map[string]interface{})
+	pos := map[string]position{"": {1, 1}}
+	var section []string
+
+	sc := bufio.NewScanner(bytes.NewReader(buf))
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		start := n
+		if props {
+			// Trailing backslashes continue the logical line
+			for strings.HasSuffix(line, `\`) && !strings.HasSuffix(line, `\\`) && sc.Scan() {
+				n++
+				line = line[:len(line)-1] + strings.TrimLeft(sc.Text(), " \t\f")
+			}
+		}
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " \t\f"))
+		switch {
+		case trimmed == "":
+			continue
+		case trimmed[0] == '#' || trimmed[0] == '!' && props || trimmed[0] == ';' && !props:
+			continue
+		case trimmed[0] == '[' && !props:
+			if !strings.HasSuffix(trimmed, "]") {
+				return nil, nil, fmt.Errorf("ini: line %d: unterminated section header", n)
+			}
+			section = splitINIKey(strings.TrimSpace(trimmed[1 : len(trimmed)-1]))
+			continue
+		}
+
+		var key, value string
+		var col int
+		if props {
+			key, value, col = splitProperty(trimmed)
+			key, value = unescapeProperty(key), unescapeProperty(value)
+		} else {
+			i := strings.IndexAny(trimmed, "=:")
+			if i < 0 {
+				return nil, nil, fmt.Errorf("ini: line %d: expected key = value", n)
+			}
+			key = strings.TrimSpace(trimmed[:i])
+			value = strings.TrimSpace(trimmed[i+1:])
+			col = len(trimmed) - len(strings.TrimLeft(trimmed[i+1:], " \t"))
+			if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+				value = value[1 : len(value)-1]
+			}
+		}
+
+		keys := append(append([]string(nil), section...), splitINIKey(key)...)
+		ptr, err := setINIValue(doc, keys, value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ini: line %d: %s", start, err)
+		}
+		pos[ptr] = position{start, indent + col + 1}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, err
+	}
+	return doc, pos, nil
+}
+
+func splitINIKey(key string) []string {
+	if *iniKeySeparatorFlag == "" {
+		return []string{key}
+	}
+	return strings.Split(key, *iniKeySeparatorFlag)
+}
+
+// setINIValue sets the value at the nested keys in doc, returning its
+// JSON pointer. An error is returned if a key is both a value and object.
+func setINIValue(doc map[string]interface{}, keys []string, value string) (string, error) {
+	var ptr strings.Builder
+	for i, k := range keys {
+		ptr.WriteByte('/')
+		ptr.WriteString(pointerEscaper.Replace(k))
+		if i == len(keys)-1 {
+			if _, ok := doc[k].(map[string]interface{}); ok {
+				return "", fmt.Errorf("%s is both a value and a section", strings.Join(keys, *iniKeySeparatorFlag))
+			}
+			doc[k] = value
+			break
+		}
+		switch v := doc[k].(type) {
+		case nil:
+			m := make(map[string]interface{})
+			doc[k] = m
+			doc = m
+		case map[string]interface{}:
+			doc = v
+		default:
+			return "", fmt.Errorf("%s is both a value and a section", strings.Join(keys[:i+1], *iniKeySeparatorFlag))
+		}
+	}
+	return ptr.String(), nil
+}
+
+// splitProperty splits a properties line at the first unescaped `=`, `:`
+// or whitespace, returning the key, value and offset of the value.
+func splitProperty(line string) (string, string, int) {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':', ' ', '\t', '\f':
+			key := line[:i]
+			rest := strings.TrimLeft(line[i:], " \t\f")
+			if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+				rest = strings.TrimLeft(rest[1:], " \t\f")
+			}
+			return key, rest, len(line) - len(rest)
+		}
+	}
+	return line, "", len(line)
+}
+
+// unescapeProperty replaces the backslash escapes in properties keys and
+// values, e.g. `\t` or `\u00e9`.
+func unescapeProperty(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'u':
+			if r, err := strconv.ParseUint(s[i+1:min(i+5, len(s))], 16, 32); err == nil && i+5 <= len(s) {
+				sb.WriteRune(rune(r))
+				i += 4
+				continue
+			}
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/titanous/json5"
+)
+
+// json5ToJSON converts JSON5 text, e.g. with comments, unquoted keys and
+// trailing commas, to JSON text. Numbers are kept as written rather than
+// round-tripped through float64.
+func json5ToJSON(buf []byte) ([]byte, error) {
+	dec := json5.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(json5Value(doc))
+}
+
+// json5Value replaces the json5.Number values within v by json.Number so
+// they are marshaled as is. Numbers that aren't valid JSON, e.g. hex or a
+// leading `+`, are converted to float64, which fails to marshal for the
+// Infinity and NaN literals.
+func json5Value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = json5Value(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = json5Value(e)
+		}
+	case json5.Number:
+		if json.Valid([]byte(v)) {
+			return json.Number(v)
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	}
+	return v
+}
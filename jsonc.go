@@ -0,0 +1,54 @@
+package main
+
+// stripJSONC blanks out the `//` and `/* */` comments and trailing commas
+// in JSONC text, e.g. VS Code settings or tsconfig files. These are replaced
+// with spaces, keeping newlines, so the offsets and line numbers of the
+// remaining JSON are unchanged for reporting failure positions.
+func stripJSONC(buf []byte) []byte {
+	out := make([]byte, len(buf))
+	copy(out, buf)
+	blank := func(i int) {
+		if out[i] != '\n' && out[i] != '\r' {
+			out[i] = ' '
+		}
+	}
+
+	comma := -1 // offset of the last comma, if it could be trailing
+	for i := 0; i < len(out); i++ {
+		switch c := out[i]; {
+		case c == '"':
+			comma = -1
+			for i++; i < len(out) && out[i] != '"'; i++ {
+				if out[i] == '\\' {
+					i++
+				}
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				blank(i)
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			blank(i)
+			blank(i + 1)
+			for i += 2; i < len(out) && !(out[i] == '*' && i+1 < len(out) && out[i+1] == '/'); i++ {
+				blank(i)
+			}
+			if i+1 < len(out) {
+				blank(i)
+				blank(i + 1)
+				i++
+			}
+		case c == ',':
+			comma = i
+		case c == ']' || c == '}':
+			if comma >= 0 {
+				out[comma] = ' '
+			}
+			comma = -1
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		default:
+			comma = -1
+		}
+	}
+	return out
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// keywordTemplate returns the check of a custom keyword for its value in a
+// schema, e.g. 10 for `"x-maxLengthBytes": 10`, or an error if the value
+// is invalid for the template.
+type keywordTemplate func(arg interface{}) (func(input interface{}) bool, error)
+
+// keywordTemplates are the assertions custom keywords can be bound to by
+// name, the built-in ones along with those of any `-format-plugin`.
+var keywordTemplates = map[string]keywordTemplate{
+	"maxBytes": byteLengthTemplate(func(n, limit int) bool { return n <= limit }),
+	"minBytes": byteLengthTemplate(func(n, limit int) bool { return n >= limit }),
+	"prefix":   affixTemplate(strings.HasPrefix),
+	"suffix":   affixTemplate(strings.HasSuffix),
+
+	"ecmaPattern": ecmaPatternTemplate,
+}
+
+// aliasKeywords are the standard assertions custom keywords can be bound
+// to, applying them as if they were spelled that way
+var aliasKeywords = map[string]bool{
+	"const": true, "enum": true, "exclusiveMaximum": true, "exclusiveMinimum": true,
+	"format": true, "maxItems": true, "maxLength": true, "maxProperties": true,
+	"maximum": true, "minItems": true, "minLength": true, "minProperties": true,
+	"minimum": true, "multipleOf": true, "pattern": true, "required": true,
+	"type": true, "uniqueItems": true,
+}
+
+// customKeywords are the `-keywords` bindings of custom keywords to the
+// names of a template or an alias keyword
+var customKeywords map[string]string
+
+// keywordFormats are the templates of the formats registered for each custom
+// keyword and value seen while compiling
+var keywordFormats = struct {
+	sync.Mutex
+	templates map[string]string
+}{templates: make(map[string]string)}
+
+// loadKeywords reads the `-keywords` file at path, a JSON or YAML object
+// binding custom keywords to templates or alias keywords, e.g.
+//
+//	x-maxLengthBytes: maxBytes
+//	x-unit: suffix
+//	x-enum: enum
+func loadKeywords(path string) (map[string]string, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if buf, err = toJSON(path, buf); err != nil {
+		return nil, err
+	}
+	var bindings map[string]string
+	if err := json.Unmarshal(buf, &bindings); err != nil {
+		return nil, err
+	}
+	for kw, name := range bindings {
+		if aliasKeywords[kw] {
+			return nil, fmt.Errorf("%s: standard keywords can't be rebound", kw)
+		}
+		if _, ok := keywordTemplates[name]; !ok && !aliasKeywords[name] {
+			return nil, fmt.Errorf("%s: unknown template or keyword %s", kw, name)
+		}
+	}
+	return bindings, nil
+}
+
+// bindKeywords rewrites the custom keywords of the schema doc in place as an
+// `allOf` of the standard keywords they're bound to. Templates are checked
+// with a `format` registered for the keyword and its value, so they apply
+// to strings and numbers alone.
+func bindKeywords(doc interface{}, base *url.URL) error {
+	if len(customKeywords) == 0 {
+		return nil
+	}
+	var errs []string
+	walkSchema(doc, base, func(ptr string, base *url.URL, schema map[string]interface{}) {
+		kws := make([]string, 0, len(schema))
+		for kw := range schema {
+			if _, ok := customKeywords[kw]; ok {
+				kws = append(kws, kw)
+			}
+		}
+		sort.Strings(kws)
+		allOf, _ := schema["allOf"].([]interface{})
+		for _, kw := range kws {
+			name, arg := customKeywords[kw], schema[kw]
+			if aliasKeywords[name] {
+				allOf = append(allOf, map[string]interface{}{name: arg})
+				continue
+			}
+			format, err := keywordFormat(kw, name, arg)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("#%s/%s: %s", ptr, pointerEscaper.Replace(kw), err))
+				continue
+			}
+			allOf = append(allOf, map[string]interface{}{"format": format})
+			if aliasKeywords[kw] {
+				delete(schema, kw) // e.g. a `pattern` checked by another regex engine
+			}
+		}
+		if len(kws) > 0 {
+			schema["allOf"] = allOf
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// keywordFormat registers, once, the format checking the value of kw with
+// the named template for arg, returning its name, e.g. `x-maxLengthBytes=10`.
+func keywordFormat(kw, name string, arg interface{}) (string, error) {
+	buf, err := json.Marshal(arg)
+	if err != nil {
+		return "", err
+	}
+	format := kw + "=" + string(buf)
+
+	keywordFormats.Lock()
+	defer keywordFormats.Unlock()
+	if keywordFormats.templates[format] == name {
+		return format, nil
+	}
+	check, err := keywordTemplates[name](arg)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s value: %s", name, err)
+	}
+	formatCheckers.Add(format, formatFunc(check))
+	keywordFormats.templates[format] = name
+	return format, nil
+}
+
+// byteLengthTemplate compares the UTF-8 length in bytes of strings, rather
+// than the code points of maxLength and minLength, to an integer limit.
+func byteLengthTemplate(cmp func(n, limit int) bool) keywordTemplate {
+	return func(arg interface{}) (func(input interface{}) bool, error) {
+		limit, ok := jsonInt(arg)
+		if !ok || limit < 0 {
+			return nil, fmt.Errorf("expected a non-negative integer")
+		}
+		return func(input interface{}) bool {
+			s, ok := input.(string)
+			return !ok || cmp(len(s), limit)
+		}, nil
+	}
+}
+
+// affixTemplate checks strings start or end with a string argument, e.g.
+// the unit of a quantity like `10kg`.
+func affixTemplate(has func(s, affix string) bool) keywordTemplate {
+	return func(arg interface{}) (func(input interface{}) bool, error) {
+		affix, ok := arg.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string")
+		}
+		return func(input interface{}) bool {
+			s, ok := input.(string)
+			return !ok || has(s, affix)
+		}, nil
+	}
+}
+
+// jsonInt returns the integer value of a decoded JSON number
+func jsonInt(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		n, err := v.Int64()
+		return int(n), err == nil
+	case float64:
+		return int(v), v == float64(int(v))
+	}
+	return 0, false
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	metaSchemasOnce sync.Once
+	metaSchemas     map[string]compiledSchema
+	metaSchemasErr  error
+)
+
+// metaSchema returns the compiled meta-schema for the `$schema` URI of a
+// schema, falling back to draft-07 for unsupported dialects.
+func metaSchema(uri string) (compiledSchema, error) {
+	metaSchemasOnce.Do(func() {
+		metaSchemas = make(map[string]compiledSchema)
+		for _, u := range metaSchemaURLs {
+			loader := gojsonschema.NewReferenceLoader(u)
+			doc, err := loader.LoadJSON()
+			if err != nil {
+				metaSchemasErr = err
+				return
+			}
+			schema, err := gojsonschema.NewSchema(loader)
+			if err != nil {
+				metaSchemasErr = err
+				return
+			}
+			metaSchemas[u] = compiledSchema{schema, doc, "", u, u}
+		}
+	})
+	if metaSchemasErr != nil {
+		return compiledSchema{}, metaSchemasErr
+	}
+	if schema, ok := metaSchemas[schemaDialect(uri)]; ok {
+		return schema, nil
+	}
+	return metaSchemas[metaSchemaURLs[len(metaSchemaURLs)-1]], nil
+}
+
+// lintSchema checks the schema at path, rather than a document, against the
+// meta-schema of its draft, catching unknown types and invalid patterns
+// among others. Refs that can't be resolved within the schema or the `-r`
+// schemas refs are also failures. Remote refs are instead checked by
+// compiling the schema, which only happens when everything else passes.
+func lintSchema(path string, refs []schemaSource) result {
+	start := time.Now()
+	var src []byte
+	var err error
+	name, base := path, &url.URL{}
+	if isURL(path) {
+		if base, err = url.Parse(path); err == nil {
+			name = base.Path
+			src, err = fetchSchema(path)
+		}
+	} else {
+		src, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return result{Path: path, Status: statusError, Error: fmt.Sprintf("load schema: %s", err)}
+	}
+	if *baseURIFlag != "" {
+		base, _ = url.Parse(*baseURIFlag)
+	}
+	format := docFormat(name, src)
+	buf, err := convertJSON(name, format, src)
+	if err != nil {
+		return result{Path: path, Status: statusError, Error: fmt.Sprintf("load schema: %s", err)}
+	}
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return result{Path: path, Status: statusError, Error: fmt.Sprintf("load schema: %s", err)}
+	}
+
+	var uri string
+	if m, ok := doc.(map[string]interface{}); ok {
+		uri, _ = m["$schema"].(string)
+	}
+	meta, err := metaSchema(uri)
+	if err != nil {
+		return result{Path: path, Status: statusError, Error: fmt.Sprintf("load meta-schema: %s", err)}
+	}
+	var pos map[string]position
+	positions := func() map[string]position {
+		if pos == nil {
+			pos = docPositions(format, src, buf)
+		}
+		return pos
+	}
+	r := validateJSON(schemaSet{meta}, path, buf, len(src), start, positions)
+	if r.Status == statusError {
+		return r
+	}
+	if msg := dialectWarning(doc); msg != "" {
+		r.Warnings = append(r.Warnings, lintFailure("unsupported_dialect", "/$schema", msg, positions()))
+	}
+
+	// A schema that's also matched by `-r` would otherwise be registered twice
+	self := schemaSource{path: path, doc: doc, base: base}
+	abs, _ := filepath.Abs(path)
+	others := make([]schemaSource, 0, len(refs))
+	for _, ref := range refs {
+		if p, _ := filepath.Abs(ref.path); p != abs {
+			others = append(others, ref)
+		}
+	}
+
+	// Relative refs to local files are loaded along with the schema
+	var file *url.URL
+	if self.base.String() == "" && path != stdinPath {
+		file, _ = fileURI(path)
+	}
+
+	var fs []failure
+	resources := indexResources(append(others, self), nil)
+	walkSchema(doc, base, func(ptr string, base *url.URL, schema map[string]interface{}) {
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			return
+		}
+		u, err := url.Parse(ref)
+		if err != nil {
+			fs = append(fs, lintFailure("unresolved_ref", ptr+"/$ref", fmt.Sprintf("Invalid reference %s: %s", ref, err), positions()))
+			return
+		}
+		target := base.ResolveReference(u)
+		_, found, ok := lookupRef(resources, target)
+		if !found && file != nil {
+			if _, p, local := localRefFile(file, base, u); local {
+				found, ok = true, !strings.HasPrefix(target.Fragment, "/")
+				if loader, err := jsonLoader(p); err == nil && !ok {
+					if doc, err := loader.LoadJSON(); err == nil {
+						_, ok = resolvePointer(doc, target.Fragment)
+					}
+				}
+			}
+		}
+		if !ok && (found || !isURL(target.String())) {
+			fs = append(fs, lintFailure("unresolved_ref", ptr+"/$ref", fmt.Sprintf("Unresolved reference %s", ref), positions()))
+		}
+	})
+	if *strictSchemaFlag {
+		for _, ptr := range unknownKeywords(doc) {
+			kw := ptr[strings.LastIndex(ptr, "/")+1:]
+			fs = append(fs, lintFailure("unknown_keyword", ptr, fmt.Sprintf("Unknown keyword %s", pointerUnescaper.Replace(kw)), positions()))
+		}
+	}
+	addFailures(&r, fs)
+
+	if r.Status == statusPass {
+		if _, err := compileSchema(self, others); err != nil {
+			var invalid invalidSchemaError
+			if errors.As(err, &invalid) {
+				err = invalid.err
+			}
+			addFailures(&r, []failure{lintFailure("invalid_schema", "", err.Error(), nil)})
+		}
+	}
+	return r
+}
+
+// lintFailure returns a failure of the given type for the value at ptr in a
+// schema, located with pos.
+func lintFailure(typ, ptr, desc string, pos map[string]position) failure {
+	field, context := pointerContext(ptr)
+	p := pos[ptr]
+	f := failure{
+		Field:       field,
+		Type:        typ,
+		Code:        errorCode(typ),
+		Description: desc,
+		Line:        p.Line,
+		Column:      p.Column,
+		message:     fmt.Sprintf("%s: %s", context, desc),
+
+		instanceLocation: ptr,
+	}
+	if *codesFlag {
+		f.message = fmt.Sprintf("[%s] %s", f.Code, f.message)
+	}
+	return f
+}
@@ -7,34 +7,108 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/text/encoding"
 	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
 
-	"github.com/ghodss/yaml"
 	"github.com/mitchellh/go-homedir"
 	"github.com/xeipuuv/gojsonschema"
 )
 
 var (
-	version     = "v1.4.0-dev"
-	schemaFlag  = flag.String("s", "", "primary JSON schema to validate against, required")
-	quietFlag   = flag.Bool("q", false, "quiet, only print validation failures and errors")
-	versionFlag = flag.Bool("v", false, "print version and exit")
-	bomFlag     = flag.Bool("b", false, "allow BOM in JSON files, error if seen and unset")
-
-	listFlags stringFlags
-	refFlags  stringFlags
+	version             = "v1.4.0-dev"
+	versionFlag         = flag.Bool("v", false, "print version and exit")
+	bomFlag             = flag.Bool("b", false, "allow BOM in JSON files, error if seen and unset")
+	strictJSONFlag      = flag.Bool("strict-json", false, "fail JSON documents with duplicate object keys rather than keeping the last value")
+	strictYAMLFlag      = flag.Bool("strict-yaml", false, "fail YAML documents with duplicate mapping keys or tab indentation rather than accepting them")
+	yamlVersionFlag     = flag.String("yaml-version", "1.1", "YAML version for resolving plain scalars like yes, no and 0777, one of: 1.1, 1.2")
+	jsoncFlag           = flag.Bool("jsonc", false, "allow comments and trailing commas in JSON files, implied for .jsonc")
+	inputFormatFlag     = flag.String("input-format", "", "parse all documents as this format rather than detecting it from the extension or contents, e.g. json, yaml, toml")
+	xmlAttrPrefixFlag   = flag.String("xml-attr-prefix", "@", "prefix of the properties for attributes when converting XML documents")
+	cborBytesFlag       = flag.String("cbor-bytes", "base64", "encoding of CBOR byte strings, one of: base64, base64url, base16")
+	iniKeySeparatorFlag = flag.String("ini-key-separator", ".", "split INI and properties keys into nested objects on this separator, empty to disable")
+	csvInferFlag        = flag.Bool("csv-infer", false, "infer numbers and booleans in CSV and spreadsheet fields, omitting empty ones, rather than treating all as strings")
+	extFlag             = flag.String("ext", "json,yml,yaml", "comma separated extensions of the documents found in directory arguments, which are searched recursively")
+	allowEmptyGlobFlag  = flag.Bool("allow-empty-glob", false, "skip document patterns without any matches rather than reporting them as errors, e.g. for optional sets of documents")
+	followSymlinksFlag  = flag.Bool("follow-symlinks", false, "search symbolic links to directories in directory arguments, -r directories and ** globs, once for each directory they resolve to")
+	stagedFlag          = flag.Bool("staged", false, "validate the contents staged in the git index, of the files with an -ext extension staged as added or modified unless documents are given, e.g. for pre-commit hooks")
+	timeoutFlag         = flag.Duration("timeout", 30*time.Second, "timeout for fetching documents from HTTP(S) URLs and S3 or GCS objects")
+	configFlag          = flag.String("config", "", "read defaults for flags not given, and documents if none are, from FILE, or "+configFile+" in the working directory if present")
+	httpTimeoutFlag     = flag.Duration("http-timeout", 0, "timeout for each attempt at fetching remote schemas and $refs, defaults to -timeout")
+	httpRetriesFlag     = flag.Int("http-retries", 0, "retry HTTP(S) fetches failing with a network error or a 429 or 5xx status up to N times, backing off exponentially")
+	httpProxyFlag       = flag.String("http-proxy", "", "proxy URL for HTTP(S) fetches, overriding the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables")
+	catalogFlag         = flag.Bool("catalog", false, "validate documents against the schema of the first entry of the -catalog-url catalog matching their file name")
+	catalogURLFlag      = flag.String("catalog-url", schemaStoreCatalog, "URL of the SchemaStore catalog for -catalog, cached like remote schemas")
+	autoSchemaFlag      = flag.Bool("auto-schema", false, "validate documents declaring a $schema path or URL against it rather than the -s schemas")
+	schemaMapFlag       = flag.String("schema-map", "", "validate documents against the schema of the first matching glob in FILE, with lines of pattern -> schema")
+	applyDefaultsFlag   = flag.String("apply-defaults", "", "write passing documents with the defaults of their schemas filled in as JSON under DIR, or to stdout for -, moving the results to stderr")
+	strictDataFlag      = flag.Bool("strict-data", false, "fail documents with properties their schemas don't declare, as if additionalProperties were false wherever it's unset")
+	failExitCodeFlag    = flag.Int("fail-exit-code", 1, "exit code for validation failures, combined bitwise with -error-exit-code when there are both")
+	errorExitCodeFlag   = flag.Int("error-exit-code", 2, "exit code for malformed documents, combined bitwise with -fail-exit-code when there are both")
+	exitZeroFlag        = flag.Bool("exit-zero", false, "exit with 0 despite failures and malformed documents, still reporting them, though not on usage or schema errors")
+	warningsFailFlag    = flag.Bool("warnings-as-errors", false, "report warnings, e.g. deprecated values or unsupported dialects, as failures affecting the exit code")
+	failDeprecatedFlag  = flag.Bool("fail-deprecated", false, "fail documents setting values their schemas mark deprecated rather than warning")
+	strictSchemaFlag    = flag.Bool("strict-schema", false, "reject schemas with unknown keywords, e.g. typos like require, other than x- extensions")
+	keywordsFlag        = flag.String("keywords", "", "enforce custom schema keywords bound to templates or standard keywords in FILE, e.g. x-maxLengthBytes: maxBytes")
+	regexEngineFlag     = flag.String("regex-engine", "re2", "engine for pattern keywords and regex formats, one of: re2, ecma (which rejects patternProperties)")
+	assertFormatsFlag   = flag.Bool("assert-formats", true, "fail values not matching their format keyword rather than treating formats as annotations")
+	anySchemaFlag       = flag.Bool("any-schema", false, "pass documents matching any of the -s schemas, rather than all, reporting the failures of the closest otherwise")
+	registryURLFlag     = flag.String("registry-url", "", "URL of the Confluent-compatible schema registry for -s registry://subject[:version] schemas")
+	registryAuthFlag    = flag.String("registry-auth", "", "USER:PASSWORD for the -registry-url, with $VAR references to the environment expanded")
+	cacheDirFlag        = flag.String("cache-dir", "", "directory for caching remote schemas, defaults to yajsv/schemas in the user cache directory")
+	schemaCacheFlag     = flag.Bool("schema-cache", false, "cache local schemas as JSON along with every file their refs resolve to in the -cache-dir, reusing them while none of the files change")
+	baseURIFlag         = flag.String("base-uri", "", "resolve the $refs of the -s schemas as if they were retrieved from this absolute URI rather than their paths")
+	cacheTTLFlag        = flag.Duration("cache-ttl", 24*time.Hour, "reuse cached remote schemas for this long before revalidating them")
+	offlineFlag         = flag.Bool("offline", false, "never access the network, only using cached remote schemas and failing for remote documents")
+	limitFlag           = flag.Int("limit", 0, "only validate the first N rows of Parquet files, 0 for no limit")
+	sheetFlag           = flag.String("sheet", "", "name of the XLSX or ODS sheet to validate, defaults to the first")
+	xmlTextKeyFlag      = flag.String("xml-text-key", "#text", "property for the text of elements with attributes or children when converting XML documents")
+	pointerFlag         = flag.Bool("pointer", false, "report failure locations as JSON pointers, e.g. /foo/0 rather than foo.0")
+	showValueFlag       = flag.Bool("show-value", false, "include a (truncated) snippet of the offending value in failures")
+	summaryFlag         = flag.String("summary", "counts", "text summary after all documents, one of: none, counts, full")
+	summaryOnlyFlag     = flag.Bool("summary-only", false, "only print the counts of passing, failing and malformed documents")
+	maxErrorsFlag       = flag.Int("max-errors", 0, "only report the first N failures per document, 0 for no limit")
+	concurrencyFlag     = flag.Int("j", runtime.GOMAXPROCS(0)+10, "validate up to N documents at once, e.g. more for remote documents or 1 to limit open files")
+	failFastFlag        = flag.Bool("fail-fast", false, "stop validating after the first failure or error")
+	codesFlag           = flag.Bool("codes", false, "prefix text failures with their stable error code, e.g. [YJ1002]")
+	byKeywordFlag       = flag.Bool("by-keyword", false, "summarize the failures grouped by schema keyword across all documents")
+	statsFlag           = flag.Bool("stats", false, "record the size, parse and validation time of each document and print aggregate statistics")
+	streamFlag          = flag.Bool("stream", false, "print text results as each document is validated rather than sorted by path")
+	debugRefsFlag       = flag.Bool("debug-refs", false, "log where each $id and $ref in the schemas resolves to")
+	colorFlag           = flag.String("color", "auto", "colorize text output, one of: auto, always, never")
+	outputFlag          = flag.String("o", "text", "output format, one of: text, json, tap, checkstyle, teamcity, quickfix, ajv")
+	outputUnitFlag      = flag.String("output-unit", "", "include standard JSON Schema output units with -o json, one of: flag, basic, detailed, verbose")
+	outputFileFlag      = flag.String("output", "", "write the -o output format to FILE, reporting progress as text on the console")
+
+	quietFlag    quietLevel
+	schemaFlags  stringFlags
+	listFlags    stringFlags
+	list0Flags   stringFlags
+	gitChanged   gitRefFlag
+	refFlags     stringFlags
+	crdFlags     stringFlags
+	pluginFlags  stringFlags
+	commandFlags stringFlags
+	reportFlags  stringFlags
+	headerFlags  stringFlags
+	excludeFlags stringFlags
+	maxFileSize  byteSize
 )
 
 // https://en.wikipedia.org/wiki/Byte_order_mark#Byte_order_marks_by_encoding
@@ -42,188 +116,964 @@ const (
 	bomUTF8    = "\xEF\xBB\xBF"
 	bomUTF16BE = "\xFE\xFF"
 	bomUTF16LE = "\xFF\xFE"
+	bomUTF32BE = "\x00\x00\xFE\xFF"
+	bomUTF32LE = "\xFF\xFE\x00\x00"
 )
 
 var (
 	encUTF16BE = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
 	encUTF16LE = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	encUTF32BE = utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM)
+	encUTF32LE = utf32.UTF32(utf32.LittleEndian, utf32.IgnoreBOM)
 )
 
 func init() {
-	flag.Var(&listFlags, "l", "validate JSON documents from newline separated paths and/or globs in a text file (relative to the basename of the file itself)")
-	flag.Var(&refFlags, "r", "referenced schema(s), can be globs and/or used multiple times")
+	flag.Var(&schemaFlags, "s", "primary JSON schema to validate against, required, can be used multiple times for documents that must pass each")
+	flag.Var(&quietFlag, "q", "quiet, only print validation failures and errors, repeat for quieter levels")
+	flag.Var(quietSetter{&quietFlag, 2}, "qq", "quieter, only print errors and rely on the exit code for failures")
+	flag.Var(quietSetter{&quietFlag, 3}, "qqq", "silent, rely on the exit code alone")
+	flag.Var(&listFlags, "l", "validate JSON documents from newline separated paths and/or globs in a text file (relative to the basename of the file itself), or stdin for -")
+	flag.Var(&list0Flags, "l0", "like -l for NUL separated paths, e.g. from find -print0, rather than lines of globs")
+	flag.Var(&gitChanged, "git-changed", "validate the files with an -ext extension that git reports as added or modified since HEAD, including untracked ones, or since the merge base with the REF of -git-changed=REF")
+	flag.Var(&crdFlags, "crd", "Kubernetes CRD(s) to validate custom resources against by apiVersion and kind, can be globs and/or used multiple times")
+	flag.Var(&pluginFlags, "format-plugin", "Go plugin registering custom format checkers with its exported Formats, can be used multiple times")
+	flag.Var(&commandFlags, "format-command", "check a custom format by running a command as name=command, valid if it exits 0 with the value on stdin, can be used multiple times")
+	flag.Var(negatedFlag{assertFormatsFlag}, "no-formats", "alias for -assert-formats=false")
+	flag.Var(negatedFlag{followSymlinksFlag}, "no-follow-symlinks", "alias for -follow-symlinks=false, the default, e.g. to override a config")
+	flag.Var(&headerFlags, "schema-header", "send a header with HTTPS schema and $ref requests, as 'Name: value' or 'host=Name: value' for any scheme, with $VARs expanded, can be used multiple times")
+	flag.Var(&excludeFlags, "exclude", "skip documents matching a glob, by base name or trailing path with a /, including **, can be used multiple times")
+	flag.Var(&maxFileSize, "max-file-size", "report documents over this size, e.g. 100M, as errors rather than loading them, after any decompression, 0 for no limit")
+	flag.Var(&refFlags, "r", "referenced schema(s), can be globs, including **, or directories and/or used multiple times")
+	flag.StringVar(outputFlag, "format", "text", "alias for -o")
+	flag.Var(&reportFlags, "report", "write an additional report as format=FILE, e.g. junit=report.xml, can be used multiple times")
+	for long, short := range longFlags {
+		flag.Var(flag.Lookup(short).Value, long, "alias for -"+short)
+	}
 	flag.Usage = printUsage
 }
 
+// longFlags are descriptive aliases of the single letter flags, e.g. for
+// `--schema=schema.json` in scripts
+var longFlags = map[string]string{
+	"schema":      "s",
+	"ref":         "r",
+	"quiet":       "q",
+	"file-list":   "l",
+	"file-list0":  "l0",
+	"version":     "v",
+	"concurrency": "j",
+}
+
+// stdinPath is the reported path of the document read from stdin when `-`
+// is given as an argument
+const stdinPath = "<stdin>"
+
+// stdin is the source of the `-` document, replaced for testing
+var stdin io.Reader = os.Stdin
+
 func main() {
 	log.SetFlags(0)
 	os.Exit(realMain(os.Args[1:], os.Stdout))
 }
 
 func realMain(args []string, w io.Writer) int {
+	// `yajsv lint` checks the schemas given as arguments rather than documents
+	// and `yajsv bundle` inlines the refs of one, while `yajsv validate` is
+	// the default
+	currentCommand = commands[0]
+	if len(args) > 0 && args[0] == "help" {
+		return helpMain(args[1:])
+	}
+	if len(args) > 0 {
+		if c, ok := lookupCommand(args[0]); ok {
+			currentCommand, args = c, args[1:]
+		}
+	}
+	cmd := currentCommand.name
+	lint := cmd == "lint"
 	flag.CommandLine.Parse(args)
+	if err := checkCommandFlags(); err != nil {
+		return usageError(err.Error())
+	}
+	if err := applyConfig(); err != nil {
+		return usageError(err.Error())
+	}
 	if *versionFlag {
 		fmt.Fprintln(w, version)
 		return 0
 	}
-	if *schemaFlag == "" {
+	if *baseURIFlag != "" {
+		if u, err := url.Parse(*baseURIFlag); err != nil || !u.IsAbs() {
+			return usageError(fmt.Sprintf("invalid -base-uri, expected an absolute URI: %s", *baseURIFlag))
+		}
+	}
+	if *httpProxyFlag != "" {
+		if u, err := url.Parse(*httpProxyFlag); err != nil || u.Host == "" {
+			return usageError(fmt.Sprintf("invalid -http-proxy, expected a URL like http://proxy:3128: %s", *httpProxyFlag))
+		}
+	}
+	for _, h := range headerFlags {
+		if _, _, _, err := parseSchemaHeader(h); err != nil {
+			return usageError(fmt.Sprintf("invalid -schema-header %q: %s", h, err))
+		}
+	}
+	for _, code := range []int{*failExitCodeFlag, *errorExitCodeFlag} {
+		if code < 1 || code > 125 {
+			return usageError(fmt.Sprintf("invalid exit code %d, expected 1 to 125", code))
+		}
+	}
+	if *concurrencyFlag < 1 {
+		return usageError(fmt.Sprintf("invalid -j, expected at least 1: %d", *concurrencyFlag))
+	}
+	if *httpRetriesFlag < 0 {
+		return usageError(fmt.Sprintf("invalid -http-retries, expected a non-negative count: %d", *httpRetriesFlag))
+	}
+	if cmd == "bundle" {
+		return bundleMain(w)
+	}
+	if len(schemaFlags) == 0 && *schemaMapFlag == "" && !*autoSchemaFlag && !*catalogFlag && len(crdFlags) == 0 && !lint {
 		return usageError("missing required -s schema argument")
 	}
+	output, ok := outputFormats[*outputFlag]
+	if !ok {
+		return usageError(fmt.Sprintf("unknown -o output format: %s", *outputFlag))
+	}
+	// Progress is reported as text on the console when the selected output
+	// format is redirected to a file
+	console := *outputFlag
+	if *outputFileFlag != "" {
+		console = "text"
+		output = outputFormats[console]
+	}
+	if *outputUnitFlag != "" {
+		if _, ok := outputUnits[*outputUnitFlag]; !ok {
+			return usageError(fmt.Sprintf("unknown -output-unit format: %s", *outputUnitFlag))
+		}
+		if *outputFlag != "json" {
+			return usageError("-output-unit requires -o json")
+		}
+	}
+	switch *summaryFlag {
+	case "none", "counts", "full":
+	default:
+		return usageError(fmt.Sprintf("unknown -summary mode: %s", *summaryFlag))
+	}
+	if *inputFormatFlag != "" && !isFormat(*inputFormatFlag) {
+		return usageError(fmt.Sprintf("unknown -input-format: %s, expected one of: %s", *inputFormatFlag, strings.Join(formats, ", ")))
+	}
+	switch *regexEngineFlag {
+	case "re2", "ecma":
+	default:
+		return usageError(fmt.Sprintf("unknown -regex-engine: %s", *regexEngineFlag))
+	}
+	switch *yamlVersionFlag {
+	case "1.1", "1.2":
+	default:
+		return usageError(fmt.Sprintf("unknown -yaml-version: %s", *yamlVersionFlag))
+	}
+	if _, ok := cborEncodings[*cborBytesFlag]; !ok {
+		return usageError(fmt.Sprintf("unknown -cbor-bytes encoding: %s", *cborBytesFlag))
+	}
+	// Documents with defaults applied take the place of the results
+	docsOut := w
+	if *applyDefaultsFlag == "-" {
+		w = os.Stderr
+	}
+	var err error
+	if colorText, err = useColor(*colorFlag, w); err != nil {
+		return usageError(err.Error())
+	}
+	reports := make(map[string]func(io.Writer, []result) error)
+	for _, r := range reportFlags {
+		parts := strings.SplitN(r, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return usageError(fmt.Sprintf("invalid -report, expected format=FILE: %s", r))
+		}
+		report, ok := reportFormats[parts[0]]
+		if !ok {
+			return usageError(fmt.Sprintf("unknown -report format: %s", parts[0]))
+		}
+		reports[parts[1]] = report
+	}
+	if *outputFileFlag != "" {
+		reports[*outputFileFlag] = reportFormats[*outputFlag]
+	}
+
+	var mappings []schemaMapping
+	if *schemaMapFlag != "" && !lint {
+		if mappings, err = readSchemaMap(*schemaMapFlag); err != nil {
+			return schemaError("%s: invalid schema map: %s", *schemaMapFlag, err)
+		}
+	}
 
-	// Resolve document paths to validate
+	// Resolve document paths to validate, reporting patterns that can't be
+	// resolved in their place
 	docs := make([]string, 0)
+	globErrors := make(map[string]string)
+	readStdin := false
 	for _, arg := range flag.Args() {
-		docs = append(docs, glob(arg)...)
-	}
-	for _, list := range listFlags {
-		dir := filepath.Dir(list)
-		f, err := os.Open(list)
+		if arg == "-" {
+			if readStdin {
+				return usageError("stdin can only be validated once")
+			}
+			readStdin = true
+			docs = append(docs, stdinPath)
+			continue
+		}
+		if *offlineFlag && (isURL(arg) || isObjectURI(arg)) {
+			return schemaError("%s: remote documents can't be fetched with -offline", arg)
+		}
+		if isURL(arg) {
+			docs = append(docs, arg)
+			continue
+		}
+		if isObjectURI(arg) {
+			uris, err := globObjects(arg)
+			if err != nil {
+				docs = append(docs, globError(globErrors, arg, err)...)
+				continue
+			}
+			docs = append(docs, uris...)
+			continue
+		}
+		matches, err := globPaths(arg)
+		if err != nil {
+			docs = append(docs, globError(globErrors, arg, err)...)
+			continue
+		}
+		paths, err := expandDirs(matches)
 		if err != nil {
-			return schemaError("%s: %s", list, err)
+			return schemaError("%s: %s", arg, err)
 		}
-		defer f.Close()
+		docs = append(docs, paths...)
+	}
+	// Lists of `-l0` are NUL separated paths, e.g. from `find -print0`,
+	// taken literally rather than as trimmed globs
+	lists := make([]string, 0, len(listFlags)+len(list0Flags))
+	lists = append(append(lists, listFlags...), list0Flags...)
+	for i, list := range lists {
+		nul := i >= len(listFlags)
 
-		scanner := bufio.NewScanner(f)
+		// A list of `-` is read from stdin, relative to the working directory
+		var r io.Reader = stdin
+		dir := "."
+		if list == "-" {
+			if readStdin {
+				return usageError("stdin can only be read once")
+			}
+			readStdin = true
+		} else {
+			dir = filepath.Dir(list)
+			f, err := os.Open(list)
+			if err != nil {
+				return schemaError("%s: %s", list, err)
+			}
+			defer f.Close()
+			r = f
+		}
+
+		scanner := bufio.NewScanner(r)
+		if nul {
+			scanner.Split(scanNUL)
+		}
 		for scanner.Scan() {
 			// Calclate the glob relative to the directory of the file list
-			pattern := strings.TrimSpace(scanner.Text())
+			pattern := scanner.Text()
+			if !nul {
+				pattern = strings.TrimSpace(pattern)
+			}
+			if pattern == "" {
+				continue
+			}
 			if !filepath.IsAbs(pattern) {
 				pattern = filepath.Join(dir, pattern)
 			}
-			docs = append(docs, glob(pattern)...)
+			matches := []string{pattern}
+			if !nul {
+				var err error
+				if matches, err = globPaths(pattern); err != nil {
+					docs = append(docs, globError(globErrors, pattern, err)...)
+					continue
+				}
+			}
+			paths, err := expandDirs(matches)
+			if err != nil {
+				return schemaError("%s: %s", list, err)
+			}
+			docs = append(docs, paths...)
 		}
 		if err := scanner.Err(); err != nil {
 			return schemaError("%s: invalid file list: %s", list, err)
 		}
 	}
+	if gitChanged.set {
+		changed, err := gitChangedDocs(gitChanged.ref)
+		if err != nil {
+			return schemaError("-git-changed: %s", err)
+		}
+		docs = append(docs, changed...)
+	}
+	if *stagedFlag && len(docs) == 0 && len(flag.Args()) == 0 && len(lists) == 0 && !gitChanged.set {
+		staged, err := gitStagedDocs()
+		if err != nil {
+			return schemaError("-staged: %s", err)
+		}
+		docs = staged
+	}
+	// Without any documents, those matched by the schema map are validated
+	if len(docs) == 0 && len(flag.Args()) == 0 && len(lists) == 0 && !gitChanged.set && !*stagedFlag {
+		seen := make(map[string]bool)
+		for _, m := range mappings {
+			paths, _ := filepath.Glob(m.pattern)
+			for _, p := range paths {
+				if !seen[p] {
+					seen[p] = true
+					docs = append(docs, p)
+				}
+			}
+		}
+	}
+	docs = excludeDocs(docs)
+	// Nothing changing is a success for `-git-changed` and `-staged`, e.g.
+	// in CI or a pre-commit hook, as is nothing matching `-allow-empty-glob`
+	if len(docs) == 0 && (gitChanged.set || *stagedFlag || *allowEmptyGlobFlag && (flag.NArg() > 0 || len(lists) > 0)) {
+		return 0
+	}
 	if len(docs) == 0 {
+		if lint {
+			return usageError("no schemas to lint")
+		}
 		return usageError("no documents to validate")
 	}
 
-	// Compile target schema
-	sl := gojsonschema.NewSchemaLoader()
-	schemaPath, err := filepath.Abs(*schemaFlag)
-	if err != nil {
-		return schemaError("%s: unable to convert to absolute path: %s", *schemaFlag, err)
+	for _, p := range pluginFlags {
+		if err := loadFormatPlugin(p); err != nil {
+			return schemaError("%s: unable to load format plugin: %s", p, err)
+		}
 	}
-	for _, ref := range refFlags {
-		for _, p := range glob(ref) {
-			absPath, err := filepath.Abs(p)
+	for _, c := range commandFlags {
+		if err := loadFormatCommand(c); err != nil {
+			return usageError(fmt.Sprintf("invalid -format-command %s: %s", c, err))
+		}
+	}
+	customKeywords = nil
+	if *keywordsFlag != "" {
+		var err error
+		if customKeywords, err = loadKeywords(*keywordsFlag); err != nil {
+			return schemaError("%s: unable to load keywords: %s", *keywordsFlag, err)
+		}
+	}
+	useRegexEngine(*regexEngineFlag)
+	assertFormats(*assertFormatsFlag || lint) // meta-schemas check regexes and URIs by format
+
+	// Compile target schemas, or lint each argument as a schema instead
+	targets := append([]string{}, schemaFlags...)
+	for _, m := range mappings {
+		targets = append(targets, m.schema)
+	}
+	// With -schema-cache the -r schemas are only loaded once a schema isn't
+	// cached, unless linting or loading schemas lazily needs them anyway
+	var refs []schemaSource
+	var refsErr error
+	refsLoaded := false
+	loadAllRefs := func() ([]schemaSource, error) {
+		if !refsLoaded {
+			refs, refsErr = loadRefs(targets)
+			refsLoaded = true
+		}
+		return refs, refsErr
+	}
+	if !*schemaCacheFlag || lint || *catalogFlag || *autoSchemaFlag {
+		if _, err := loadAllRefs(); err != nil {
+			return schemaError("%s", err)
+		}
+	}
+	check := func(path string) []result {
+		return []result{lintSchema(path, refs)}
+	}
+	if !lint {
+		var schemas schemaSet
+		sources, refSources := []schemaSource(nil), refs
+		for _, arg := range schemaFlags {
+			cs, src, rs, err := loadCompiled(arg, *baseURIFlag, targets, loadAllRefs)
 			if err != nil {
-				return schemaError("%s: unable to convert to absolute path: %s", absPath, err)
+				return schemaError("%s", err)
 			}
-
-			if absPath == schemaPath {
+			schemas = append(schemas, cs)
+			sources, refSources = append(sources, src), rs
+		}
+		mapped := make(map[string]schemaSet)
+		for _, m := range mappings {
+			if _, ok := mapped[m.schema]; ok {
 				continue
 			}
-
-			loader, err := jsonLoader(absPath)
+			cs, src, rs, err := loadCompiled(m.schema, "", targets, loadAllRefs)
 			if err != nil {
-				return schemaError("%s: unable to load schema ref: %s", *schemaFlag, err)
+				return schemaError("%s", err)
 			}
-
-			if err := sl.AddSchemas(loader); err != nil {
-				return schemaError("%s: invalid schema: %s", p, err)
+			mapped[m.schema] = schemaSet{cs}
+			sources, refSources = append(sources, src), rs
+		}
+		if quietFlag < 2 {
+			for _, s := range append(refSources, sources...) {
+				if msg := dialectWarning(s.doc); msg != "" {
+					fmt.Fprintf(os.Stderr, "%s: warning: %s\n", s.path, msg)
+				}
+			}
+		}
+		var crds []string
+		for _, c := range crdFlags {
+			paths, err := globPaths(c)
+			if err != nil {
+				return schemaError("-crd %s", err)
 			}
+			crds = append(crds, paths...)
+		}
+		if crdSchemas, err = loadCRDs(crds); err != nil {
+			return schemaError("%s", err)
+		}
+		var catalog []catalogEntry
+		var matchers []string
+		if *schemaMapFlag != "" {
+			matchers = append(matchers, "pattern of "+*schemaMapFlag)
+		}
+		if *catalogFlag {
+			if catalog, err = loadCatalog(*catalogURLFlag); err != nil {
+				return schemaError("%s", err)
+			}
+			matchers = append(matchers, "entry of "+*catalogURLFlag)
+		}
+		unmatched := fmt.Sprintf("no -s schema and no %s matches", strings.Join(matchers, " or "))
+		lazySchemas.reset(refs)
+		check = func(path string) []result {
+			if s := mappedSchema(mappings, path); s != "" {
+				return validate(mapped[s], path)
+			}
+			if u := catalogSchema(catalog, path); u != "" {
+				schema, err := lazySchemas.get(u)
+				if err != nil {
+					return []result{{Path: path, Status: statusError, Error: fmt.Sprintf("load catalog schema: %s", err)}}
+				}
+				return validate(schemaSet{schema}, path)
+			}
+			if len(schemas) == 0 && !*autoSchemaFlag && len(crdFlags) == 0 {
+				return []result{{Path: path, Status: statusError, Error: unmatched}}
+			}
+			return validate(schemas, path)
 		}
 	}
 
-	schemaLoader, err := jsonLoader(schemaPath)
-	if err != nil {
-		return schemaError("%s: unable to load schema: %s", *schemaFlag, err)
-	}
-	schema, err := sl.Compile(schemaLoader)
-	if err != nil {
-		return schemaError("%s: invalid schema: %s", *schemaFlag, err)
-	}
+	start := time.Now()
 
-	// Validate the schema against each doc in parallel, limiting simultaneous
-	// open files to avoid ulimit issues.
+	// Validate the schema against each doc in parallel, feeding them in order
+	// to `-j` workers to limit simultaneous open files and avoid ulimit issues.
+	// With `-fail-fast` the first failure or error closes done so that any
+	// pending documents are skipped.
 	var wg sync.WaitGroup
-	sem := make(chan int, runtime.GOMAXPROCS(0)+10)
-	failures := make([]string, 0)
-	errors := make([]string, 0)
-	for _, p := range docs {
-		wg.Add(1)
-		go func(path string) {
-			defer wg.Done()
-			sem <- 0
-			defer func() { <-sem }()
-
-			loader, err := jsonLoader(path)
-			if err != nil {
-				msg := fmt.Sprintf("%s: error: load doc: %s", path, err)
-				fmt.Fprintln(w, msg)
-				errors = append(errors, msg)
+	var mu sync.Mutex
+	var once sync.Once
+	done := make(chan struct{})
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := range docs {
+			select {
+			case jobs <- i:
+			case <-done:
 				return
 			}
-			result, err := schema.Validate(loader)
-			switch {
-			case err != nil:
-				msg := fmt.Sprintf("%s: error: validate: %s", path, err)
-				fmt.Fprintln(w, msg)
-				errors = append(errors, msg)
-
-			case !result.Valid():
-				lines := make([]string, len(result.Errors()))
-				for i, desc := range result.Errors() {
-					lines[i] = fmt.Sprintf("%s: fail: %s", path, desc)
+		}
+	}()
+	validated := make([][]result, len(docs))
+	for n := 0; n < *concurrencyFlag; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				select {
+				case <-done:
+					continue
+				default:
 				}
-				msg := strings.Join(lines, "\n")
-				fmt.Fprintln(w, msg)
-				failures = append(failures, msg)
 
-			case !*quietFlag:
-				fmt.Fprintf(w, "%s: pass\n", path)
+				path := docs[i]
+				if msg, ok := globErrors[path]; ok {
+					validated[i] = []result{{Path: path, Status: statusError, Error: msg}}
+				} else {
+					validated[i] = check(path)
+				}
+				if *warningsFailFlag {
+					for j := range validated[i] {
+						promoteWarnings(&validated[i][j])
+					}
+				}
+				for _, r := range validated[i] {
+					if *failFastFlag && r.Status != statusPass {
+						once.Do(func() { close(done) })
+					}
+				}
+				if *streamFlag && console == "text" {
+					mu.Lock()
+					for _, r := range validated[i] {
+						writeTextResult(w, r, colorText)
+					}
+					mu.Unlock()
+				}
 			}
-		}(p)
+		}()
 	}
 	wg.Wait()
 
+	// Buffered results are reported in a stable order, independent of the
+	// order in which the documents were validated. Documents within the
+	// same file keep their order. Skipped documents have no results.
+	order := make([]int, len(docs))
+	for i := range order {
+		order[i] = i
+	}
+	if !*streamFlag {
+		sort.SliceStable(order, func(i, j int) bool {
+			return docs[order[i]] < docs[order[j]]
+		})
+	}
+	results := make([]result, 0, len(docs))
+	for _, i := range order {
+		results = append(results, validated[i]...)
+	}
+	if !*streamFlag {
+		if console == "text" {
+			for _, r := range results {
+				writeTextResult(w, r, colorText)
+			}
+		}
+	}
+
+	if unit := outputUnits[*outputUnitFlag]; unit != nil {
+		for i := range results {
+			results[i].Output = unit(results[i])
+		}
+	}
+
 	// Summarize results (e.g. errors)
-	if !*quietFlag {
-		if len(failures) > 0 {
-			fmt.Fprintf(w, "%d of %d failed validation\n", len(failures), len(docs))
-			fmt.Fprintln(w, strings.Join(failures, "\n"))
+	if err := output(w, results); err != nil {
+		log.Printf("output: %s", err)
+	}
+	// Keep machine readable output parseable by moving any extra text
+	// to stderr
+	extra := w
+	if console != "text" {
+		extra = os.Stderr
+	}
+	if *byKeywordFlag {
+		writeKeywordSummary(extra, results)
+	}
+	if *statsFlag {
+		writeStats(extra, results, time.Since(start))
+	}
+	if *applyDefaultsFlag != "" {
+		if err := writeDefaults(*applyDefaultsFlag, docsOut, results); err != nil {
+			log.Printf("apply defaults: %s", err)
 		}
-		if len(errors) > 0 {
-			fmt.Fprintf(w, "%d of %d malformed documents\n", len(errors), len(docs))
-			fmt.Fprintln(w, strings.Join(errors, "\n"))
+	}
+	for path, report := range reports {
+		if err := writeReport(path, report, results); err != nil {
+			log.Printf("%s: report: %s", path, err)
 		}
 	}
 	exit := 0
-	if len(failures) > 0 {
-		exit |= 1
+	for _, r := range results {
+		switch r.Status {
+		case statusFail:
+			exit |= *failExitCodeFlag
+		case statusError:
+			exit |= *errorExitCodeFlag
+		}
 	}
-	if len(errors) > 0 {
-		exit |= 2
+	if *exitZeroFlag {
+		return 0
 	}
 	return exit
 }
 
+// promoteWarnings turns the warnings of r into failures for
+// `-warnings-as-errors`, failing it unless it's already an error.
+func promoteWarnings(r *result) {
+	if len(r.Warnings) == 0 {
+		return
+	}
+	r.Failures = append(r.Failures, r.Warnings...)
+	r.Warnings = nil
+	if r.Status == statusPass {
+		r.Status = statusFail
+	}
+}
+
+// compiledSchema pairs the compiled schema with its JSON document, which
+// is used to report the schema locations of failures, and its path.
+type compiledSchema struct {
+	*gojsonschema.Schema
+	doc  interface{}
+	root string // JSON pointer of the root schema within doc
+	path string
+	uri  string // absolute URI of doc for absolute keyword locations, if any
+}
+
+// schemaSet is the schemas given by `-s`, which documents must all pass
+type schemaSet []compiledSchema
+
+// validate loads the file at path and validates the document(s) within it
+// against schema. Each line of NDJSON files is a separate document, as is
+// each document of a multi-document YAML stream or BSON dump and each CSV,
+// spreadsheet, Avro or Parquet record. Gzip and zstd compressed files are
+// expanded first.
+func validate(schema schemaSet, path string) []result {
+	var src []byte
+	var err error
+	name := path
+	switch {
+	case path == stdinPath:
+		src, err = readDoc(stdin)
+	case isURL(path):
+		src, name, err = fetchURL(path, *timeoutFlag)
+	case isObjectURI(path):
+		src, name, err = fetchObject(path)
+	case *stagedFlag:
+		src, err = gitStaged(path)
+	default:
+		src, err = readFile(path)
+	}
+	if err == nil {
+		err = tooLarge(int64(len(src)))
+	}
+	if err != nil {
+		return []result{{Path: path, Status: statusError, Error: fmt.Sprintf("load doc: %s", err)}}
+	}
+	src, name, err = decompress(name, src)
+	if err != nil {
+		return []result{{Path: path, Status: statusError, Error: fmt.Sprintf("decompress doc: %s", err)}}
+	}
+	format := *inputFormatFlag
+	if format == "" {
+		format = docFormat(name, src)
+	}
+	switch format {
+	case formatYAML:
+		docs := splitYAML(src)
+		if len(docs) <= 1 {
+			return []result{validateDoc(schema, path, format, src, 0)}
+		}
+		results := make([]result, len(docs))
+		for i, doc := range docs {
+			r := validateDoc(schema, path, format, doc.src, doc.line)
+			r.Path, r.file, r.line = fmt.Sprintf("%s[doc%d]", path, i+1), path, doc.line+1
+			results[i] = r
+		}
+		return results
+	case formatCSV:
+		return records(path, validateCSV(schema, path, src))
+	case formatXLSX, formatODS:
+		return records(path, validateSheet(schema, path, format, src))
+	case formatBSON:
+		return records(path, validateBSON(schema, path, src))
+	case formatAvro:
+		return records(path, validateAvro(schema, path, src))
+	case formatParquet:
+		return records(path, validateParquet(schema, path, src))
+	case formatNDJSON:
+	default:
+		return []result{validateDoc(schema, path, format, src, 0)}
+	}
+
+	var results []result
+	for i, line := range bytes.Split(src, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		r := validateDoc(schema, path, formatJSON, bytes.TrimSuffix(line, []byte("\r")), i)
+		r.Path, r.file, r.line = fmt.Sprintf("%s:%d", path, i+1), path, i+1
+		results = append(results, r)
+	}
+	return records(path, results)
+}
+
+// records returns the results of the records of the file at path, or a pass
+// of the file itself when it has none, e.g. a CSV with only a header row, so
+// it's still reported and counted rather than silently dropped.
+func records(path string, results []result) []result {
+	if len(results) == 0 {
+		return []result{{Path: path, Status: statusPass}}
+	}
+	return results
+}
+
+// validateDoc validates the document src, in the given format, from the file
+// at path against schema, or the `-crd` schema of a custom resource and the
+// schema it declares with `-auto-schema`. Failure positions are offset by the
+// number of lines preceding src in the file.
+func validateDoc(schema schemaSet, path, format string, src []byte, lines int) result {
+	start := time.Now()
+	buf, err := convertJSON(path, format, src)
+	if err != nil {
+		return result{Path: path, Status: statusError, Error: fmt.Sprintf("load doc: %s", err)}
+	}
+	if len(crdFlags) > 0 {
+		crd, err := resourceSchema(buf)
+		switch {
+		case err == nil:
+			schema = crd
+		case len(schema) == 0 && !*autoSchemaFlag:
+			return result{Path: path, Status: statusError, Error: err.Error()}
+		}
+	}
+	if *autoSchemaFlag {
+		declared, err := declaredSchema(path, buf)
+		if err != nil {
+			return result{Path: path, Status: statusError, Error: fmt.Sprintf("load $schema: %s", err)}
+		}
+		if declared != nil {
+			schema = declared
+		}
+	}
+	r := validateJSON(schema, path, buf, len(src), start, func() map[string]position {
+		pos := docPositions(format, src, buf)
+		for ptr, p := range pos {
+			p.Line += lines
+			pos[ptr] = p
+		}
+		return pos
+	})
+	if *strictJSONFlag && (format == formatJSON || format == formatJSONC) {
+		addFailures(&r, duplicateFailures(jsonDuplicateKeys(buf), lines))
+	}
+	if *strictYAMLFlag && format == formatYAML {
+		addFailures(&r, append(duplicateFailures(yamlDuplicateKeys(src), lines), tabFailures(src, lines)...))
+	}
+	return r
+}
+
+// docPositions returns the positions of the values in the document src of
+// the given format, keyed by JSON pointer, where buf is its JSON text.
+func docPositions(format string, src, buf []byte) map[string]position {
+	switch format {
+	case formatYAML:
+		return yamlPositions(src)
+	case formatINI, formatProperties:
+		return iniPositions(format, src)
+	case formatJSON, formatJSONC:
+		return jsonPositions(buf)
+	}
+	// TODO positions aren't supported for the other formats
+	return nil
+}
+
+// validateJSON validates the JSON text buf, converted from a document of
+// size bytes starting at start, against each schema of the set. The source
+// positions of failures are only resolved, by positions, if validation
+// fails. With several schemas, failures name the schema they're from and
+// with `-any-schema` only those of the closest schema, i.e. with the fewest
+// failures, are reported unless one passes.
+func validateJSON(schemas schemaSet, path string, buf []byte, size int, start time.Time, positions func() map[string]position) result {
+	if len(schemas) == 0 {
+		// Only with `-auto-schema`, for documents that don't declare one
+		return result{Path: path, Status: statusError, Error: "no $schema and no -s schema to validate against"}
+	}
+	r := result{Path: path, Status: statusPass}
+	parsed := time.Now()
+	var pos map[string]position
+	var closest []failure
+	matched := false
+	for _, schema := range schemas {
+		res, err := schema.Validate(gojsonschema.NewBytesLoader(buf))
+		if err != nil {
+			r.Status = statusError
+			r.Error = fmt.Sprintf("validate: %s", err)
+			r.Failures = nil
+			break
+		}
+		if res.Valid() {
+			if *anySchemaFlag {
+				matched = true
+				break
+			}
+			continue
+		}
+		if pos == nil {
+			pos = positions()
+		}
+		var fs []failure
+		for _, desc := range res.Errors() {
+			ptr := contextPointer(desc.Context())
+			field, context := desc.Field(), desc.Context().String()
+			if *pointerFlag {
+				field, context = ptr, ptr
+				if ptr == "" {
+					context = "(root)"
+				}
+			}
+			p := pos[ptr]
+			f := failure{
+				Field:       field,
+				Type:        desc.Type(),
+				Code:        errorCode(desc.Type()),
+				Description: desc.Description(),
+				Line:        p.Line,
+				Column:      p.Column,
+				message:     fmt.Sprintf("%s: %s", context, desc.Description()),
+
+				instanceLocation: ptr,
+				details:          desc.Details(),
+			}
+			f.keywordLocation, f.schemaLocation = keywordLocation(schema.doc, schema.root, ptr, errorKeywords[desc.Type()])
+			addSchemaDetails(&f, schema.doc, desc.Value())
+			if schema.uri != "" && f.keywordLocation != "" {
+				f.absoluteKeywordLocation = schema.uri + "#" + (&url.URL{Fragment: f.schemaLocation}).EscapedFragment()
+			}
+			if *codesFlag {
+				f.message = fmt.Sprintf("[%s] %s", f.Code, f.message)
+			}
+			if *showValueFlag {
+				f.Value = snippet(desc.Value())
+				f.message += ", got: " + f.Value
+			}
+			if len(schemas) > 1 {
+				f.Schema = schema.path
+				f.message += " (" + schema.path + ")"
+			}
+			fs = append(fs, f)
+		}
+		sortFailures(fs)
+		if !*anySchemaFlag {
+			r.Failures = append(r.Failures, fs...)
+		} else if closest == nil || len(fs) < len(closest) {
+			closest = fs
+		}
+	}
+	if *anySchemaFlag && !matched && r.Status != statusError {
+		r.Failures = closest
+	}
+	if r.Status != statusError {
+		fs := deprecatedFailures(schemas, buf, func() map[string]position {
+			if pos == nil {
+				pos = positions()
+			}
+			return pos
+		})
+		if *failDeprecatedFlag {
+			r.Failures = append(fs, r.Failures...)
+		} else {
+			r.Warnings = append(r.Warnings, fs...)
+		}
+	}
+	if len(r.Failures) > 0 {
+		r.Status = statusFail
+	}
+	if *applyDefaultsFlag != "" && r.Status == statusPass {
+		var err error
+		if r.defaulted, err = withDefaults(schemas, buf); err != nil {
+			r.Status = statusError
+			r.Error = fmt.Sprintf("apply defaults: %s", err)
+		}
+	}
+	if *statsFlag {
+		r.Stats = &docStats{
+			Size:     size,
+			Parse:    parsed.Sub(start),
+			Validate: time.Since(parsed),
+		}
+	}
+	if *maxErrorsFlag > 0 && len(r.Failures) > *maxErrorsFlag {
+		r.Truncated = len(r.Failures) - *maxErrorsFlag
+		r.Failures = r.Failures[:*maxErrorsFlag]
+	}
+	return r
+}
+
 func jsonLoader(path string) (gojsonschema.JSONLoader, error) {
 	buf, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	switch filepath.Ext(path) {
-	case ".yml", ".yaml":
+	var sum string
+	if *schemaCacheFlag {
+		sum = sha256Hex(buf)
+	}
+	buf, err = toJSON(path, buf)
+	if err != nil {
+		return nil, err
+	}
+	return fileLoader{gojsonschema.NewBytesLoader(buf), sum}, nil
+}
+
+// toJSON converts the contents of the file at path to JSON text based on
+// the file extension, or the contents for unknown extensions.
+func toJSON(path string, buf []byte) ([]byte, error) {
+	return convertJSON(path, docFormat(path, buf), buf)
+}
+
+// convertJSON converts the contents of the file at path to JSON text from
+// the given document format.
+func convertJSON(path, format string, buf []byte) ([]byte, error) {
+	var err error
+	switch format {
+	case formatYAML:
 		// TODO YAML requires the precense of a BOM to detect UTF-16
 		// text. Is there a decent hueristic to detect UTF-16 text
 		// missing a BOM so we can provide a better error message?
-		buf, err = yaml.YAMLToJSON(buf)
+		buf, err = yamlToJSON(yamlDecodeUTF32(buf), *yamlVersionFlag)
+	case formatTOML:
+		buf, err = tomlToJSON(buf)
+	case formatJSON5:
+		buf, err = json5ToJSON(buf)
+	case formatXML:
+		buf, err = xmlToJSON(buf)
+	case formatHCL:
+		buf, err = hclToJSON(path, buf)
+	case formatMsgpack:
+		buf, err = msgpackToJSON(buf)
+	case formatCBOR:
+		buf, err = cborToJSON(buf)
+	case formatINI, formatProperties:
+		buf, err = iniToJSON(format, buf)
 	default:
 		buf, err = jsonDecodeCharset(buf)
+		if err == nil && (*jsoncFlag || format == formatJSONC) {
+			buf = stripJSONC(buf)
+		}
 	}
 	if err != nil {
 		return nil, err
 	}
 	// TODO What if we have an empty document?
-	return gojsonschema.NewBytesLoader(buf), nil
+	return buf, nil
 }
 
-// jsonDecodeCharset attempts to detect UTF-16 (LE or BE) JSON text and
-// decode as appropriate. It also skips a BOM at the start of the buffer
+// yamlDecodeUTF32 detects UTF-32 (LE or BE) YAML text, with or without a
+// BOM, and decodes it to UTF-8 since the YAML parser only supports UTF-8
+// and UTF-16.
+func yamlDecodeUTF32(buf []byte) []byte {
+	var enc encoding.Encoding
+	switch {
+	case len(buf) < 4:
+		return buf
+	case bytes.HasPrefix(buf, []byte(bomUTF32BE)):
+		buf, enc = buf[len(bomUTF32BE):], encUTF32BE
+	case bytes.HasPrefix(buf, []byte(bomUTF32LE)):
+		buf, enc = buf[len(bomUTF32LE):], encUTF32LE
+	case buf[0] == 0 && buf[1] == 0:
+		enc = encUTF32BE
+	case buf[1] == 0 && buf[2] == 0 && buf[3] == 0:
+		enc = encUTF32LE
+	default:
+		return buf
+	}
+	if dec, err := enc.NewDecoder().Bytes(buf); err == nil {
+		return dec
+	}
+	return buf
+}
+
+// jsonDecodeCharset attempts to detect UTF-16 or UTF-32 (LE or BE) JSON text
+// and decode as appropriate. It also skips a BOM at the start of the buffer
 // if `-b` was specified. Presence of a BOM is an error otherwise.
 func jsonDecodeCharset(buf []byte) ([]byte, error) {
 	if len(buf) < 2 { // UTF-8
@@ -235,6 +1085,16 @@ func jsonDecodeCharset(buf []byte) ([]byte, error) {
 	switch {
 	case bytes.HasPrefix(buf, []byte(bomUTF8)):
 		bom = bomUTF8
+	case bytes.HasPrefix(buf, []byte(bomUTF32BE)):
+		bom = bomUTF32BE
+		enc = encUTF32BE
+	case bytes.HasPrefix(buf, []byte(bomUTF32LE)):
+		bom = bomUTF32LE
+		enc = encUTF32LE
+	case len(buf) >= 4 && buf[0] == 0 && buf[1] == 0:
+		enc = encUTF32BE
+	case len(buf) >= 4 && buf[1] == 0 && buf[2] == 0 && buf[3] == 0:
+		enc = encUTF32LE
 	case bytes.HasPrefix(buf, []byte(bomUTF16BE)):
 		bom = bomUTF16BE
 		enc = encUTF16BE
@@ -259,30 +1119,6 @@ func jsonDecodeCharset(buf []byte) ([]byte, error) {
 	return buf, nil
 }
 
-func printUsage() {
-	fmt.Fprintf(os.Stderr, `Usage: %s -s schema.(json|yml) [options] document.(json|yml) ...
-
-  yajsv validates JSON and YAML document(s) against a schema. One of three status
-  results are reported per document:
-
-    pass: Document is valid relative to the schema
-    fail: Document is invalid relative to the schema
-    error: Document is malformed, e.g. not valid JSON or YAML
-
-  The 'fail' status may be reported multiple times per-document, once for each
-  schema validation failure.
-
-  Sets the exit code to 1 on any failures, 2 on any errors, 3 on both, 4 on
-  invalid usage, 5 on schema definition or file-list errors. Otherwise, 0 is
-  returned if everything passes validation.
-
-Options:
-
-`, os.Args[0])
-	flag.PrintDefaults()
-	fmt.Fprintln(os.Stderr)
-}
-
 func usageError(msg string) int {
 	fmt.Fprintln(os.Stderr, msg)
 	printUsage()
@@ -294,21 +1130,135 @@ func schemaError(format string, args ...interface{}) int {
 	return 5
 }
 
-// glob is a wrapper that also resolves `~` since we may be skipping
-// the shell expansion when single-quoting globs at the command line
-func glob(pattern string) []string {
+// globError records the error of a document pattern to report it as the
+// result of the pattern, returning the pattern to validate in its place.
+// Patterns without any matches are skipped with `-allow-empty-glob`.
+func globError(errs map[string]string, pattern string, err error) []string {
+	if errors.Is(err, errNoMatches) && *allowEmptyGlobFlag {
+		return nil
+	}
+	errs[pattern] = fmt.Sprintf("load doc: %s", err)
+	return []string{pattern}
+}
+
+// errNoMatches is the error of patterns without any matches
+var errNoMatches = errors.New("no such file or directory")
+
+// globPaths returns the paths matching pattern, resolving `~` since we may
+// be skipping the shell expansion when single-quoting globs at the command
+// line, or an error if there are none. Unlike filepath.Glob, `{a,b}` matches
+// either alternative and `**` any number of directories, e.g.
+// `configs/**/*.{yml,yaml}`.
+func globPaths(pattern string) ([]string, error) {
 	pattern, err := homedir.Expand(pattern)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	paths, err := filepath.Glob(pattern)
-	if err != nil {
-		log.Fatal(err)
+	var paths []string
+	seen := make(map[string]bool)
+	for _, p := range expandBraces(pattern) {
+		matches, err := globStar(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				paths = append(paths, m)
+			}
+		}
 	}
 	if len(paths) == 0 {
-		log.Fatalf("%s: no such file or directory", pattern)
+		return nil, fmt.Errorf("%s: %w", pattern, errNoMatches)
+	}
+	return paths, nil
+}
+
+// scanNUL is a bufio.SplitFunc for NUL terminated tokens, where the final
+// token may omit the terminator
+func scanNUL(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
 	}
-	return paths
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// quietLevel is a counting flag, each `-q` further reduces the output
+type quietLevel int
+
+func (q *quietLevel) String() string {
+	return strconv.Itoa(int(*q))
+}
+
+func (q *quietLevel) Set(value string) error {
+	if n, err := strconv.Atoi(value); err == nil {
+		*q = quietLevel(n)
+		return nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	if b {
+		*q++
+	}
+	return nil
+}
+
+func (q *quietLevel) IsBoolFlag() bool {
+	return true
+}
+
+// quietSetter is a boolean flag that raises the quiet level to at least
+// the given level, e.g. `-qq` is equivalent to `-q -q`.
+type quietSetter struct {
+	q     *quietLevel
+	level quietLevel
+}
+
+func (qs quietSetter) String() string {
+	return "false"
+}
+
+func (qs quietSetter) Set(value string) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	if b && *qs.q < qs.level {
+		*qs.q = qs.level
+	}
+	return nil
+}
+
+func (qs quietSetter) IsBoolFlag() bool {
+	return true
+}
+
+// negatedFlag is a boolean flag that sets another to its opposite, e.g.
+// `-no-formats` is equivalent to `-assert-formats=false`.
+type negatedFlag struct {
+	b *bool
+}
+
+func (nf negatedFlag) String() string {
+	return "false"
+}
+
+func (nf negatedFlag) Set(value string) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*nf.b = !b
+	return nil
+}
+
+func (nf negatedFlag) IsBoolFlag() bool {
+	return true
 }
 
 type stringFlags []string
@@ -1,12 +1,25 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func init() {
@@ -18,6 +31,32 @@ func init() {
 	os.Stderr = devnull
 }
 
+// resetFlags restores the global flag state mutated by prior realMain calls
+func resetFlags() {
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		if strings.HasPrefix(f.Name, "test.") {
+			return
+		}
+		if _, ok := f.Value.(quietSetter); ok {
+			return
+		}
+		if _, ok := f.Value.(negatedFlag); ok {
+			return // reset with the flag it negates
+		}
+		if sf, ok := f.Value.(*stringFlags); ok {
+			*sf = nil
+			return
+		}
+		f.Value.Set(f.DefValue)
+	})
+	// Forget which flags were set, e.g. for those a config doesn't override
+	fs := flag.NewFlagSet(flag.CommandLine.Name(), flag.CommandLine.ErrorHandling())
+	flag.CommandLine.VisitAll(func(f *flag.Flag) { fs.Var(f.Value, f.Name, f.Usage) })
+	fs.Usage = flag.CommandLine.Usage
+	fs.Parse(nil)
+	flag.CommandLine = fs
+}
+
 func TestMain(t *testing.T) {
 	tests := []struct {
 		in   string
@@ -46,19 +85,19 @@ func TestMain(t *testing.T) {
 			0,
 		}, {
 			"-q -s testdata/utf-8/schema.yml testdata/utf-8/data-fail.yml",
-			[]string{"testdata/utf-8/data-fail.yml: fail: (root): foo is required"},
+			[]string{"testdata/utf-8/data-fail.yml:2:1: fail: (root): foo is required"},
 			1,
 		}, {
 			"-q -s testdata/utf-8/schema.json testdata/utf-8/data-fail.yml",
-			[]string{"testdata/utf-8/data-fail.yml: fail: (root): foo is required"},
+			[]string{"testdata/utf-8/data-fail.yml:2:1: fail: (root): foo is required"},
 			1,
 		}, {
 			"-q -s testdata/utf-8/schema.json testdata/utf-8/data-fail.json",
-			[]string{"testdata/utf-8/data-fail.json: fail: (root): foo is required"},
+			[]string{"testdata/utf-8/data-fail.json:1:1: fail: (root): foo is required"},
 			1,
 		}, {
 			"-q -s testdata/utf-8/schema.yml testdata/utf-8/data-fail.json",
-			[]string{"testdata/utf-8/data-fail.json: fail: (root): foo is required"},
+			[]string{"testdata/utf-8/data-fail.json:1:1: fail: (root): foo is required"},
 			1,
 		}, {
 			"-q -s testdata/utf-8/schema.json testdata/utf-8/data-error.json",
@@ -71,15 +110,808 @@ func TestMain(t *testing.T) {
 		}, {
 			"-q -s testdata/utf-8/schema.json testdata/utf-8/data-*.json",
 			[]string{
-				"testdata/utf-8/data-fail.json: fail: (root): foo is required",
+				"testdata/utf-8/data-fail.json:1:1: fail: (root): foo is required",
 				"testdata/utf-8/data-error.json: error: validate: invalid character 'o' in literal null (expecting 'u')",
 			}, 3,
 		}, {
 			"-q -s testdata/utf-8/schema.yml testdata/utf-8/data-*.yml",
 			[]string{
 				"testdata/utf-8/data-error.yml: error: load doc: yaml: found unexpected end of stream",
-				"testdata/utf-8/data-fail.yml: fail: (root): foo is required",
+				"testdata/utf-8/data-fail.yml:2:1: fail: (root): foo is required",
+			}, 3,
+		}, {
+			"-s testdata/toml/schema.json testdata/toml/data-pass.toml",
+			[]string{"testdata/toml/data-pass.toml: pass"},
+			0,
+		}, {
+			"-q -s testdata/toml/schema.json testdata/toml/data-*.toml",
+			[]string{
+				"testdata/toml/data-error.toml: error: load doc: toml: line 1 (last key \"title\"): strings cannot contain newlines",
+				"testdata/toml/data-fail.toml: fail: (root): title is required",
+				"testdata/toml/data-fail.toml: fail: (root).owner: name is required",
+				"testdata/toml/data-fail.toml: fail: (root).ports.1: Invalid type. Expected: integer, given: string",
+			}, 3,
+		}, {
+			"-s testdata/json5/schema.json5 testdata/json5/data-pass.json5",
+			[]string{"testdata/json5/data-pass.json5: pass"},
+			0,
+		}, {
+			"-q -s testdata/json5/schema.json5 testdata/json5/data-*.json5",
+			[]string{
+				"testdata/json5/data-error.json5: error: load doc: invalid character '\\n' in string literal",
+				"testdata/json5/data-fail.json5: fail: (root): title is required",
+				"testdata/json5/data-fail.json5: fail: (root).ports.1: Invalid type. Expected: integer, given: number",
+			}, 3,
+		}, {
+			"-s testdata/jsonc/schema.jsonc testdata/jsonc/data-pass.jsonc",
+			[]string{"testdata/jsonc/data-pass.jsonc: pass"},
+			0,
+		}, {
+			"-q -s testdata/jsonc/schema.jsonc testdata/jsonc/data-fail.json",
+			[]string{"testdata/jsonc/data-fail.json: error: validate: invalid character '/' looking for beginning of value"},
+			2,
+		}, {
+			"-q -jsonc -s testdata/jsonc/schema.jsonc testdata/jsonc/data-fail.json",
+			[]string{"testdata/jsonc/data-fail.json:6:19: fail: (root).compilerOptions.strict: Invalid type. Expected: boolean, given: string"},
+			1,
+		}, {
+			"-s testdata/utf-8/schema.json testdata/ndjson/data.ndjson",
+			[]string{
+				"testdata/ndjson/data.ndjson:1: pass",
+				"testdata/ndjson/data.ndjson:2:1: fail: (root): foo is required",
+				"testdata/ndjson/data.ndjson:4:9: fail: (root).foo: Invalid type. Expected: string, given: integer",
+				"testdata/ndjson/data.ndjson:5: error: validate: unexpected EOF",
+				"2 of 4 failed validation",
+				"1 of 4 malformed documents",
+			}, 3,
+		}, {
+			"-s testdata/utf-8/schema.json testdata/yaml-stream/data.yml",
+			[]string{
+				"testdata/yaml-stream/data.yml[doc1]: pass",
+				"testdata/yaml-stream/data.yml:5:1: fail: (root): foo is required",
+				"testdata/yaml-stream/data.yml:8:6: fail: (root).foo: Invalid type. Expected: string, given: integer",
+				"testdata/yaml-stream/data.yml[doc4]: error: load doc: yaml: line 1: did not find expected ',' or ']'",
+				"2 of 4 failed validation",
+				"1 of 4 malformed documents",
+			}, 3,
+		}, {
+			"-q -s testdata/xml/schema.json testdata/xml/data-*.xml",
+			[]string{
+				"testdata/xml/data-error.xml: error: load doc: XML syntax error on line 3: element <server> closed by </config>",
+				`testdata/xml/data-fail.xml: fail: (root).config.@version: config.@version must be one of the following: "1", "2"`,
+				"testdata/xml/data-fail.xml: fail: (root).config.server.0.port: Does not match pattern '^[0-9]+$'",
+				"testdata/xml/data-fail.xml: fail: (root).config.server.1: @host is required",
+			}, 3,
+		}, {
+			"-q -xml-attr-prefix - -s testdata/xml/schema.json testdata/xml/data-pass.xml",
+			[]string{
+				"testdata/xml/data-pass.xml: fail: (root).config: @version is required",
+				"testdata/xml/data-pass.xml: fail: (root).config.server.0: @host is required",
+				"testdata/xml/data-pass.xml: fail: (root).config.server.1: @host is required",
+			}, 1,
+		}, {
+			"-q -s testdata/hcl/schema.json testdata/hcl/pass.tfvars testdata/hcl/fail.tfvars testdata/hcl/error.tfvars",
+			[]string{
+				"testdata/hcl/error.tfvars: error: load doc: parse config: [testdata/hcl/error.tfvars:1,10-11: Invalid expression; Expected the start of an expression, but found an invalid expression token.]",
+				"testdata/hcl/fail.tfvars: fail: (root): region is required",
+				"testdata/hcl/fail.tfvars: fail: (root).instance_count: Must be greater than or equal to 1",
+				"testdata/hcl/fail.tfvars: fail: (root).tags.cost: Invalid type. Expected: string, given: integer",
+			}, 3,
+		}, {
+			"-csv-infer -s testdata/csv/schema.json testdata/csv/data.csv",
+			[]string{
+				"testdata/csv/data.csv:2: pass",
+				"testdata/csv/data.csv:3:3: fail: (root).email: Does not match format 'email'",
+				"testdata/csv/data.csv:4:1: fail: (root): email is required",
+				"testdata/csv/data.csv:5:3: fail: (root).email: Does not match format 'email'",
+				"testdata/csv/data.csv:6:19: fail: (root).active: Invalid type. Expected: boolean, given: string",
+				"testdata/csv/data.csv:7: error: load doc: record on line 7: wrong number of fields",
+				"3 of 5 failed validation",
+				"1 of 5 malformed documents",
+			}, 3,
+		}, {
+			"-s testdata/csv/schema.json testdata/csv/empty.csv testdata/csv/blank.csv testdata/ndjson/empty.ndjson testdata/bson/empty.bson testdata/avro/empty.avro",
+			[]string{
+				"testdata/avro/empty.avro: pass",
+				"testdata/bson/empty.bson: pass",
+				"testdata/csv/blank.csv: pass",
+				"testdata/csv/empty.csv: pass",
+				"testdata/ndjson/empty.ndjson: pass",
+			}, 0,
+		}, {
+			"-q -s testdata/csv/schema.json testdata/csv/data.csv",
+			[]string{
+				"testdata/csv/data.csv:2:1: fail: (root).id: Invalid type. Expected: integer, given: string",
+				"testdata/csv/data.csv:2:17: fail: (root).active: Invalid type. Expected: boolean, given: string",
+				"testdata/csv/data.csv:3:1: fail: (root).id: Invalid type. Expected: integer, given: string",
+				"testdata/csv/data.csv:3:3: fail: (root).email: Does not match format 'email'",
+				"testdata/csv/data.csv:3:16: fail: (root).active: Invalid type. Expected: boolean, given: string",
+				"testdata/csv/data.csv:4:1: fail: (root).id: Invalid type. Expected: integer, given: string",
+				"testdata/csv/data.csv:4:5: fail: (root).email: Does not match format 'email'",
+				"testdata/csv/data.csv:4:6: fail: (root).active: Invalid type. Expected: boolean, given: string",
+				"testdata/csv/data.csv:5:1: fail: (root).id: Invalid type. Expected: integer, given: string",
+				"testdata/csv/data.csv:5:3: fail: (root).email: Does not match format 'email'",
+				"testdata/csv/data.csv:6:19: fail: (root).active: Invalid type. Expected: boolean, given: string",
+				"testdata/csv/data.csv:7: error: load doc: record on line 7: wrong number of fields",
+			}, 3,
+		}, {
+			"-s testdata/utf-8/schema.json testdata/msgpack/data-pass.msgpack testdata/msgpack/data-fail.msgpack testdata/msgpack/data-error.msgpack",
+			[]string{
+				"testdata/msgpack/data-error.msgpack: error: load doc: EOF",
+				"testdata/msgpack/data-fail.msgpack: fail: (root).foo: Invalid type. Expected: string, given: integer",
+				"testdata/msgpack/data-pass.msgpack: pass",
+				"1 of 3 failed validation",
+				"1 of 3 malformed documents",
+			}, 3,
+		}, {
+			"-q -s testdata/cbor/schema.json testdata/cbor/data-pass.cbor testdata/cbor/data-fail.cbor testdata/cbor/data-error.cbor",
+			[]string{
+				"testdata/cbor/data-error.cbor: error: load doc: unexpected EOF",
+				"testdata/cbor/data-fail.cbor: fail: (root): foo is required",
+			}, 3,
+		}, {
+			"-q -cbor-bytes base64url -s testdata/cbor/schema.json testdata/cbor/data-pass.cbor testdata/cbor/data-fail.cbor",
+			[]string{
+				"testdata/cbor/data-fail.cbor: fail: (root): foo is required",
+				"testdata/cbor/data-fail.cbor: fail: (root).data: Does not match pattern '^[A-Za-z0-9+/]*=*$'",
+			}, 1,
+		}, {
+			"-s testdata/utf-8/schema.json testdata/bson/dump.bson testdata/bson/single.bson testdata/bson/truncated.bson",
+			[]string{
+				"testdata/bson/dump.bson@0: pass",
+				"testdata/bson/dump.bson@58: fail: (root).foo: Invalid type. Expected: string, given: integer",
+				"testdata/bson/dump.bson@89: fail: (root): foo is required",
+				"testdata/bson/single.bson: pass",
+				"testdata/bson/truncated.bson@0: pass",
+				"testdata/bson/truncated.bson@58: fail: (root).foo: Invalid type. Expected: string, given: integer",
+				"testdata/bson/truncated.bson@89: error: load doc: bson: invalid document length 11",
+				"3 of 7 failed validation",
+				"1 of 7 malformed documents",
+			}, 3,
+		}, {
+			"-s testdata/utf-8/schema.json testdata/avro/data.avro testdata/avro/error.avro",
+			[]string{
+				"testdata/avro/data.avro[record1]: pass",
+				"testdata/avro/data.avro[record2]: fail: (root).foo: Invalid type. Expected: string, given: null",
+				"testdata/avro/error.avro: error: load doc: cannot create OCFReader: cannot read OCF header metadata: cannot read map key: cannot read bytes: unexpected EOF",
+				"1 of 3 failed validation",
+				"1 of 3 malformed documents",
+			}, 3,
+		}, {
+			"-s testdata/ini/schema.json testdata/ini/data.ini testdata/ini/data.properties",
+			[]string{
+				"testdata/ini/data.ini:4:8: fail: (root).server.port: Does not match pattern '^[0-9]+$'",
+				"testdata/ini/data.properties: pass",
+				"1 of 2 failed validation",
+			}, 1,
+		}, {
+			"-ini-key-separator= -s testdata/ini/schema.json testdata/ini/data.properties",
+			[]string{
+				"testdata/ini/data.properties:1:1: fail: (root): server is required",
+				"1 of 1 failed validation",
+			}, 1,
+		}, {
+			"-s testdata/sniff/schema testdata/sniff/app.conf testdata/sniff/db.conf testdata/sniff/settings",
+			[]string{
+				"testdata/sniff/app.conf: pass",
+				"testdata/sniff/db.conf:3:11: fail: (root).port: Invalid type. Expected: integer, given: string",
+				"testdata/sniff/settings:2:1: fail: (root): name is required",
+				"2 of 3 failed validation",
+			}, 1,
+		}, {
+			"-input-format toml -s testdata/sniff/schema testdata/sniff/generated.json",
+			[]string{"testdata/sniff/generated.json: pass"},
+			0,
+		}, {
+			"-s testdata/compress/schema.json testdata/compress/corrupt.json.gz testdata/compress/data.json.gz testdata/compress/data.yaml.zst",
+			[]string{
+				"testdata/compress/corrupt.json.gz: error: decompress doc: gzip: invalid header",
+				"testdata/compress/data.json.gz: pass",
+				"testdata/compress/data.yaml.zst:1:1: fail: (root): id is required",
+				"1 of 3 failed validation",
+				"1 of 3 malformed documents",
+			}, 3,
+		}, {
+			"-csv-infer -s testdata/sheet/schema.json testdata/sheet/data.xlsx testdata/sheet/data.ods",
+			[]string{
+				"testdata/sheet/data.ods:2: pass",
+				"testdata/sheet/data.ods:5:1: fail: (root): name is required",
+				"testdata/sheet/data.ods:5:2: fail: (root).age: Invalid type. Expected: integer, given: number",
+				"testdata/sheet/data.xlsx:2: pass",
+				"testdata/sheet/data.xlsx:3:2: fail: (root).age: Invalid type. Expected: integer, given: string",
+				"testdata/sheet/data.xlsx:5:2: fail: (root).age: Must be greater than or equal to 0",
+				"3 of 5 failed validation",
+			}, 1,
+		}, {
+			"-sheet People -s testdata/sheet/schema.json testdata/sheet/data.xlsx testdata/sheet/data.ods",
+			[]string{
+				"testdata/sheet/data.ods: error: load doc: sheet People does not exist",
+				"testdata/sheet/data.xlsx:2:2: fail: (root).age: Invalid type. Expected: integer, given: string",
+				"1 of 2 failed validation",
+				"1 of 2 malformed documents",
+			}, 3,
+		}, {
+			"-s testdata/sniff/schema testdata/strict/dups.json",
+			[]string{
+				"testdata/strict/dups.json:5:11: fail: (root).port: Invalid type. Expected: integer, given: string",
+				"1 of 1 failed validation",
+			}, 1,
+		}, {
+			"-strict-json -s testdata/sniff/schema testdata/strict/dups.json",
+			[]string{
+				"testdata/strict/dups.json:4:36: fail: (root).tls: Duplicate property cert",
+				"testdata/strict/dups.json:5:3: fail: (root): Duplicate property port",
+				"testdata/strict/dups.json:5:11: fail: (root).port: Invalid type. Expected: integer, given: string",
+				"1 of 1 failed validation",
+			}, 1,
+		}, {
+			"-strict-json -max-errors 1 -s testdata/sniff/schema testdata/strict/order.json",
+			[]string{
+				"testdata/strict/order.json:2:11: fail: (root).name: Invalid type. Expected: string, given: integer",
+				"testdata/strict/order.json: fail: and 1 more",
+				"1 of 1 failed validation",
+			}, 1,
+		}, {
+			"-s testdata/sniff/schema testdata/strict/dups.yaml",
+			[]string{
+				"testdata/strict/dups.yaml[doc1]: pass",
+				"testdata/strict/dups.yaml[doc2]: pass",
+			}, 0,
+		}, {
+			"-strict-yaml -s testdata/sniff/schema testdata/strict/dups.yaml",
+			[]string{
+				"testdata/strict/dups.yaml:4:1: fail: (root): Indentation contains a tab character",
+				"testdata/strict/dups.yaml:5:1: fail: (root): Duplicate property name",
+				"testdata/strict/dups.yaml[doc2]: pass",
+				"1 of 2 failed validation",
+			}, 1,
+		}, {
+			"-s testdata/anchor/schema.json -r testdata/anchor/defs.json -r testdata/anchor/tree.json testdata/anchor/data-pass.json",
+			[]string{"testdata/anchor/data-pass.json: pass"}, 0,
+		}, {
+			"-s testdata/anchor/schema.json -r testdata/anchor/defs.json -r testdata/anchor/tree.json testdata/anchor/data-fail.json",
+			[]string{
+				"testdata/anchor/data-fail.json:1:1: fail: (root): Must validate all the schemas (allOf)",
+				"testdata/anchor/data-fail.json:1:10: fail: (root).name: String length must be greater than or equal to 1",
+				"testdata/anchor/data-fail.json:1:21: fail: (root).tag: Does not match pattern '^a-'",
+				"testdata/anchor/data-fail.json:1:41: fail: (root).children.0: name is required",
+				"1 of 1 failed validation",
+			}, 1,
+		}, {
+			"lint -r testdata/lint/defs.json testdata/lint/good.json testdata/lint/defs.json",
+			[]string{
+				"testdata/lint/defs.json: pass",
+				"testdata/lint/good.json: pass",
+			}, 0,
+		}, {
+			"lint -r testdata/lint/defs.json testdata/lint/bad.yaml",
+			[]string{
+				"testdata/lint/bad.yaml:10:11: fail: (root).properties.owner.$ref: Unresolved reference #/definitions/person",
+				"testdata/lint/bad.yaml:12:11: fail: (root).properties.other.$ref: Unresolved reference defs.json#/definitions/missing",
+				"testdata/lint/bad.yaml:5:11: fail: (root).properties.name.type: Must validate at least one schema (anyOf)",
+				`testdata/lint/bad.yaml:5:11: fail: (root).properties.name.type: properties.name.type must be one of the following: "array", "boolean", "integer", "null", "number", "object", "string"`,
+				"testdata/lint/bad.yaml:8:14: fail: (root).properties.code.pattern: Does not match format 'regex'",
+				"1 of 1 failed validation",
+			}, 1,
+		}, {
+			"lint testdata/lint/dialect.json",
+			[]string{
+				"testdata/lint/dialect.json: pass with warnings",
+				"testdata/lint/dialect.json:2:14: warning: (root).$schema: $schema https://json-schema.org/draft/2020-12/schema (2020-12) is not fully supported, validating as draft-07 and ignoring prefixItems, unevaluatedProperties",
+				"1 of 1 passed with warnings",
+			}, 0,
+		}, {
+			"lint testdata/lint/good.json",
+			[]string{"testdata/lint/good.json: pass"}, 0,
+		}, {
+			"-s testdata/multi/base.json -s testdata/multi/prod.json testdata/multi/data-*.json",
+			[]string{
+				"testdata/multi/data-fail-both.json:1:1: fail: (root): name is required (testdata/multi/base.json)",
+				"testdata/multi/data-fail-both.json:2:15: fail: (root).replicas: Must be greater than or equal to 1 (testdata/multi/base.json)",
+				"testdata/multi/data-fail-both.json:2:15: fail: (root).replicas: Must be greater than or equal to 3 (testdata/multi/prod.json)",
+				"testdata/multi/data-fail.json:3:15: fail: (root).replicas: Must be greater than or equal to 3 (testdata/multi/prod.json)",
+				"testdata/multi/data-fail.json:4:12: fail: (root).debug: debug does not match: false (testdata/multi/prod.json)",
+				"testdata/multi/data-pass.json: pass",
+				"2 of 3 failed validation",
+			}, 1,
+		}, {
+			"-s testdata/multi/base.json testdata/multi/data-fail.json",
+			[]string{"testdata/multi/data-fail.json: pass"}, 0,
+		}, {
+			"-any-schema -s testdata/multi/base.json -s testdata/multi/prod.json testdata/multi/data-*.json",
+			[]string{
+				"testdata/multi/data-fail-both.json:2:15: fail: (root).replicas: Must be greater than or equal to 3 (testdata/multi/prod.json)",
+				"testdata/multi/data-fail.json: pass",
+				"testdata/multi/data-pass.json: pass",
+				"1 of 3 failed validation",
+			}, 1,
+		}, {
+			"-s testdata/formats/schema.json testdata/formats/data.json",
+			[]string{
+				"testdata/formats/data.json:2:12: fail: (root).email: Does not match format 'email'",
+				"1 of 1 failed validation",
+			}, 1,
+		}, {
+			"-no-formats -s testdata/formats/schema.json testdata/formats/data.json",
+			[]string{"testdata/formats/data.json: pass"}, 0,
+		}, {
+			"-assert-formats=false -s testdata/formats/schema.json testdata/formats/data.json",
+			[]string{"testdata/formats/data.json: pass"}, 0,
+		}, {
+			"-keywords testdata/keywords/keywords.yml -s testdata/keywords/schema.json testdata/keywords/data-*.json",
+			[]string{
+				"testdata/keywords/data-fail.json:2:11: fail: (root).name: Does not match format 'x-maxLengthBytes=4'",
+				"testdata/keywords/data-fail.json:2:11: fail: (root).name: Must validate all the schemas (allOf)",
+				"testdata/keywords/data-fail.json:3:13: fail: (root).weight: Does not match format 'x-unit=\"kg\"'",
+				"testdata/keywords/data-fail.json:3:13: fail: (root).weight: Must validate all the schemas (allOf)",
+				"testdata/keywords/data-fail.json:4:12: fail: (root).color: Must validate all the schemas (allOf)",
+				"testdata/keywords/data-fail.json:4:12: fail: (root).color: color must be one of the following: \"red\", \"blue\"",
+				"testdata/keywords/data-pass.json: pass",
+				"1 of 2 failed validation",
+			}, 1,
+		}, {
+			"-keywords testdata/keywords/keywords.yml -no-formats -s testdata/keywords/schema.json testdata/keywords/data-fail.json",
+			[]string{
+				"testdata/keywords/data-fail.json:2:11: fail: (root).name: Does not match format 'x-maxLengthBytes=4'",
+				"testdata/keywords/data-fail.json:2:11: fail: (root).name: Must validate all the schemas (allOf)",
+				"testdata/keywords/data-fail.json:3:13: fail: (root).weight: Does not match format 'x-unit=\"kg\"'",
+				"testdata/keywords/data-fail.json:3:13: fail: (root).weight: Must validate all the schemas (allOf)",
+				"testdata/keywords/data-fail.json:4:12: fail: (root).color: Must validate all the schemas (allOf)",
+				"testdata/keywords/data-fail.json:4:12: fail: (root).color: color must be one of the following: \"red\", \"blue\"",
+				"1 of 1 failed validation",
+			}, 1,
+		}, {
+			"-keywords testdata/keywords/unknown.yml -s testdata/keywords/schema.json testdata/keywords/data-pass.json",
+			[]string{}, 5,
+		}, {
+			"-keywords testdata/keywords/keywords.yml -s testdata/keywords/invalid.json testdata/keywords/data-pass.json",
+			[]string{}, 5,
+		}, {
+			"-s testdata/regex/schema.json testdata/regex/data-pass.json",
+			[]string{}, 5,
+		}, {
+			"-regex-engine ecma -s testdata/regex/schema.json testdata/regex/data-*.json",
+			[]string{
+				"testdata/regex/data-fail.json:2:15: fail: (root).password: Does not match format 'pattern=\"^(?=.*\\\\d)(?=.*[a-z]).{8,}$\"'",
+				"testdata/regex/data-fail.json:2:15: fail: (root).password: Must validate all the schemas (allOf)",
+				"testdata/regex/data-fail.json:3:12: fail: (root).twice: Does not match format 'pattern=\"^(\\\\w)\\\\1$\"'",
+				"testdata/regex/data-fail.json:3:12: fail: (root).twice: Must validate all the schemas (allOf)",
+				"testdata/regex/data-pass.json: pass",
+				"1 of 2 failed validation",
+			}, 1,
+		}, {
+			"lint -regex-engine ecma testdata/regex/schema.json",
+			[]string{"testdata/regex/schema.json: pass"}, 0,
+		}, {
+			"-regex-engine ecma -s testdata/regex/pattern-properties.json testdata/regex/data-pass.json",
+			[]string{}, 5,
+		}, {
+			"-regex-engine ecma -s testdata/regex/x-properties.json testdata/regex/data-pass.json",
+			[]string{}, 5,
+		}, {
+			"-s testdata/regex/x-properties.json testdata/regex/data-pass.json",
+			[]string{"testdata/regex/data-pass.json: pass"}, 0,
+		}, {
+			"-regex-engine pcre -s testdata/regex/schema.json testdata/regex/data-pass.json",
+			[]string{}, 4,
+		}, {
+			"-s testdata/strict-schema/schema.json testdata/strict-schema/data.json",
+			[]string{"testdata/strict-schema/data.json: pass"}, 0,
+		}, {
+			"-strict-schema -s testdata/strict-schema/schema.json testdata/strict-schema/data.json",
+			[]string{}, 5,
+		}, {
+			"lint -strict-schema testdata/strict-schema/schema.json",
+			[]string{
+				"testdata/strict-schema/schema.json:3:14: fail: (root).require: Unknown keyword require",
+				"testdata/strict-schema/schema.json:5:46: fail: (root).properties.name.maxlength: Unknown keyword maxlength",
+				"1 of 1 failed validation",
+			}, 1,
+		}, {
+			"-strict-schema -keywords testdata/keywords/keywords.yml -s testdata/keywords/schema.json testdata/keywords/data-pass.json",
+			[]string{"testdata/keywords/data-pass.json: pass"}, 0,
+		}, {
+			"-s testdata/strict-data/schema.json testdata/strict-data/data-*.json",
+			[]string{
+				"testdata/strict-data/data-fail.json: pass",
+				"testdata/strict-data/data-pass.json: pass",
+			}, 0,
+		}, {
+			"-strict-data -s testdata/strict-data/schema.json testdata/strict-data/data-*.json",
+			[]string{
+				"testdata/strict-data/data-fail.json:1:1: fail: (root): (root) must be one of the following: \"created\", \"labels\", \"name\", \"owner\"",
+				"testdata/strict-data/data-fail.json:1:1: fail: (root): Property name of \"nmae\" does not match",
+				"testdata/strict-data/data-fail.json:3:12: fail: (root).owner: Additional property phone is not allowed",
+				"testdata/strict-data/data-pass.json: pass",
+				"1 of 2 failed validation",
+			}, 1,
+		}, {
+			"-base-uri https://schemas.example.com/app/schema.json -r testdata/base-uri/defs.json -s testdata/base-uri/local/schema.json testdata/base-uri/data-fail.json",
+			[]string{
+				"testdata/base-uri/data-fail.json:2:11: fail: (root).port: Must be greater than or equal to 1",
+				"1 of 1 failed validation",
+			}, 1,
+		}, {
+			"-offline -r testdata/base-uri/defs.json -s testdata/base-uri/local/schema.json testdata/base-uri/data-fail.json",
+			[]string{}, 5,
+		}, {
+			"lint -base-uri https://schemas.example.com/app/schema.json -r testdata/base-uri/defs.json testdata/base-uri/local/schema.json",
+			[]string{"testdata/base-uri/local/schema.json: pass"}, 0,
+		}, {
+			"-base-uri schemas/app.json -s testdata/base-uri/local/schema.json testdata/base-uri/data-fail.json",
+			[]string{}, 4,
+		}, {
+			"-s testdata/fragment/schema.json#/definitions/Address testdata/fragment/address-*.json",
+			[]string{
+				"testdata/fragment/address-fail.json:1:1: fail: (root): street is required",
+				"testdata/fragment/address-fail.json:2:14: fail: (root).country: Does not match pattern '^[A-Z]{2}$'",
+				"testdata/fragment/address-pass.json: pass",
+				"1 of 2 failed validation",
+			}, 1,
+		}, {
+			"-s testdata/fragment/schema.json#/definitions/Missing testdata/fragment/address-pass.json",
+			[]string{}, 5,
+		}, {
+			"-s testdata/openapi/openapi.yaml#/components/schemas/Pet testdata/openapi/*.json",
+			[]string{
+				"testdata/openapi/cat-pass.json: pass",
+				"testdata/openapi/dog-fail.json:1:1: fail: (root): Must validate \"then\" as \"if\" was valid",
+				"testdata/openapi/dog-fail.json:1:1: fail: (root): Must validate all the schemas (allOf)",
+				"testdata/openapi/dog-fail.json:1:1: fail: (root): Must validate all the schemas (allOf)",
+				"testdata/openapi/dog-fail.json:1:1: fail: (root): bark is required",
+				"testdata/openapi/dog-fail.json:4:12: fail: (root).owner: Invalid type. Expected: [string,null], given: integer",
+				"testdata/openapi/dog-pass.json: pass",
+				"testdata/openapi/fish-fail.json:1:1: fail: (root): Must validate all the schemas (allOf)",
+				"testdata/openapi/fish-fail.json:2:14: fail: (root).petType: petType must be one of the following: \"kitty\", \"Dog\"",
+				"2 of 4 failed validation",
+			}, 1,
+		}, {
+			"-s testdata/openapi/openapi.yaml testdata/openapi/cat-pass.json",
+			[]string{}, 5,
+		}, {
+			"-crd testdata/crd/widgets.yaml testdata/crd/resources.yaml",
+			[]string{
+				"testdata/crd/resources.yaml:15:13: fail: (root).spec.replicas: Must be greater than or equal to 1",
+				"testdata/crd/resources.yaml:18:5: fail: (root).spec.template: apiVersion is required",
+				"testdata/crd/resources.yaml:18:5: fail: (root).spec.template: kind is required",
+				"testdata/crd/resources.yaml:25:9: fail: (root).spec.size: Invalid type. Expected: integer, given: string",
+				"testdata/crd/resources.yaml[doc1]: pass",
+				"testdata/crd/resources.yaml[doc4]: error: no -crd schema for v1 ConfigMap",
+				"2 of 4 failed validation",
+				"1 of 4 malformed documents",
+			}, 3,
+		}, {
+			"-crd testdata/crd/*.yaml -s testdata/multi/base.json testdata/crd/resources.yaml",
+			[]string{
+				"testdata/crd/resources.yaml:15:13: fail: (root).spec.replicas: Must be greater than or equal to 1",
+				"testdata/crd/resources.yaml:18:5: fail: (root).spec.template: apiVersion is required",
+				"testdata/crd/resources.yaml:18:5: fail: (root).spec.template: kind is required",
+				"testdata/crd/resources.yaml:25:9: fail: (root).spec.size: Invalid type. Expected: integer, given: string",
+				"testdata/crd/resources.yaml[doc1]: pass",
+				"testdata/crd/resources.yaml:27:1: fail: (root): name is required",
+				"testdata/crd/resources.yaml:27:1: fail: (root): replicas is required",
+				"3 of 4 failed validation",
+			}, 1,
+		}, {
+			"-s testdata/nested/schema.json -r testdata/nested/refs testdata/nested/data-*.json",
+			[]string{
+				"testdata/nested/data-fail.json:2:11: fail: (root).name: String length must be greater than or equal to 1",
+				"testdata/nested/data-fail.json:3:15: fail: (root).location: lon is required",
+				"testdata/nested/data-pass.json: pass",
+				"1 of 2 failed validation",
+			}, 1,
+		}, {
+			"-s testdata/nested/schema.json -r testdata/nested/**/*.json -r testdata/nested/refs/**/*.yaml testdata/nested/data-*.json",
+			[]string{
+				"testdata/nested/data-fail.json:2:11: fail: (root).name: String length must be greater than or equal to 1",
+				"testdata/nested/data-fail.json:3:15: fail: (root).location: lon is required",
+				"testdata/nested/data-pass.json: pass",
+				"1 of 2 failed validation",
+			}, 1,
+		}, {
+			"-s testdata/nested/schema.json -r testdata/nested/**/*.nope testdata/nested/data-*.json",
+			[]string{}, 5,
+		}, {
+			"-s testdata/deprecated/schema.json testdata/deprecated/data-*",
+			[]string{
+				"1 of 3 failed validation",
+				"1 of 3 passed with warnings",
+				"testdata/deprecated/data-current.json: pass",
+				"testdata/deprecated/data-fail.json:1:11: fail: (root).name: Invalid type. Expected: string, given: integer",
+				"testdata/deprecated/data-fail.json:1:25: warning: (root).retries: Deprecated",
+				"testdata/deprecated/data-legacy.yaml: pass with warnings",
+				"testdata/deprecated/data-legacy.yaml:2:7: warning: (root).host: Deprecated: use endpoints instead",
+				"testdata/deprecated/data-legacy.yaml:3:10: warning: (root).retries: Deprecated",
+				"testdata/deprecated/data-legacy.yaml:6:15: warning: (root).endpoints.0.insecure: Deprecated",
+			}, 1,
+		}, {
+			"-fail-deprecated -s testdata/deprecated/schema.json testdata/deprecated/data-legacy.yaml testdata/deprecated/data-current.json",
+			[]string{
+				"1 of 2 failed validation",
+				"testdata/deprecated/data-current.json: pass",
+				"testdata/deprecated/data-legacy.yaml:2:7: fail: (root).host: Deprecated: use endpoints instead",
+				"testdata/deprecated/data-legacy.yaml:3:10: fail: (root).retries: Deprecated",
+				"testdata/deprecated/data-legacy.yaml:6:15: fail: (root).endpoints.0.insecure: Deprecated",
+			}, 1,
+		}, {
+			"-s testdata/dirs/schema.json testdata/dirs/docs",
+			[]string{
+				"1 of 2 failed validation",
+				"testdata/dirs/docs/a.json: pass",
+				"testdata/dirs/docs/nested/b.yaml:1:7: fail: (root).name: Invalid type. Expected: string, given: integer",
+			}, 1,
+		}, {
+			"-ext toml,.JSON -s testdata/dirs/schema.json testdata/dirs/docs",
+			[]string{
+				"testdata/dirs/docs/a.json: pass",
+				"testdata/dirs/docs/nested/c.toml: pass",
+			}, 0,
+		}, {
+			"-s testdata/dirs/schema.json testdata/dirs/docs/**/*.{json,toml}",
+			[]string{
+				"testdata/dirs/docs/.hidden/d.json:1:1: fail: (root): name is required",
+				"testdata/dirs/docs/a.json: pass",
+				"testdata/dirs/docs/nested/c.toml: pass",
+				"1 of 3 failed validation",
+			}, 1,
+		}, {
+			"-s testdata/exclude/schema.json -exclude **/node_modules/** -exclude *.generated.json -exclude fixtures/broken.json -exclude testdata/exclude/schema.json testdata/exclude",
+			[]string{
+				"testdata/exclude/app.json: pass",
+				"testdata/exclude/fixtures/ok.json: pass",
+			}, 0,
+		}, {
+			"-s testdata/exclude/schema.json -exclude **/node_modules/** -exclude testdata/exclude/{app,schema}.json testdata/exclude/**/*.json",
+			[]string{
+				"2 of 3 failed validation",
+				"testdata/exclude/fixtures/broken.json:1:1: fail: (root): name is required",
+				"testdata/exclude/fixtures/empty.generated.json:1:1: fail: (root): name is required",
+				"testdata/exclude/fixtures/ok.json: pass",
+			}, 1,
+		}, {
+			"-s testdata/ignore/schema.json testdata/ignore testdata/ignore/build/out.json",
+			[]string{
+				"2 of 4 failed validation",
+				"testdata/ignore/app.json: pass",
+				"testdata/ignore/build/out.json:1:1: fail: (root): name is required",
+				"testdata/ignore/keep.tmp.json: pass",
+				"testdata/ignore/sub/vendored.json:1:1: fail: (root): name is required",
+			}, 1,
+		}, {
+			"--schema=testdata/utf-8/schema.json --quiet --file-list0 testdata/list0/files.nul",
+			[]string{
+				"testdata/list0/[draft].json:1:9: fail: (root).foo: Invalid type. Expected: string, given: integer",
+			}, 1,
+		}, {
+			"-fail-exit-code 8 -error-exit-code 16 -q -s testdata/utf-8/schema.json testdata/utf-8/data-pass.json testdata/utf-8/data-fail.json",
+			[]string{"testdata/utf-8/data-fail.json:1:1: fail: (root): foo is required"}, 8,
+		}, {
+			"-fail-exit-code 8 -error-exit-code 16 -qq -s testdata/utf-8/schema.json testdata/utf-8/data-*.json",
+			[]string{"testdata/utf-8/data-error.json: error: validate: invalid character 'o' in literal null (expecting 'u')"}, 24,
+		}, {
+			"-exit-zero -qq -s testdata/utf-8/schema.json testdata/utf-8/data-*.json",
+			[]string{"testdata/utf-8/data-error.json: error: validate: invalid character 'o' in literal null (expecting 'u')"}, 0,
+		}, {
+			"-exit-zero -fail-exit-code 0 -s testdata/utf-8/schema.json testdata/utf-8/data-pass.json",
+			[]string{}, 4,
+		}, {
+			"-warnings-as-errors -s testdata/deprecated/schema.json testdata/deprecated/data-*",
+			[]string{
+				"2 of 3 failed validation",
+				"testdata/deprecated/data-current.json: pass",
+				"testdata/deprecated/data-fail.json:1:11: fail: (root).name: Invalid type. Expected: string, given: integer",
+				"testdata/deprecated/data-fail.json:1:25: fail: (root).retries: Deprecated",
+				"testdata/deprecated/data-legacy.yaml:2:7: fail: (root).host: Deprecated: use endpoints instead",
+				"testdata/deprecated/data-legacy.yaml:3:10: fail: (root).retries: Deprecated",
+				"testdata/deprecated/data-legacy.yaml:6:15: fail: (root).endpoints.0.insecure: Deprecated",
+			}, 1,
+		}, {
+			"-s testdata/utf-8/schema.json testdata/utf-8/data-pass.json testdata/utf-8/missing-*.json testdata/utf-8/missing.json",
+			[]string{
+				"2 of 3 malformed documents",
+				"testdata/utf-8/data-pass.json: pass",
+				"testdata/utf-8/missing-*.json: error: load doc: testdata/utf-8/missing-*.json: no such file or directory",
+				"testdata/utf-8/missing.json: error: load doc: testdata/utf-8/missing.json: no such file or directory",
+			}, 2,
+		}, {
+			"-s testdata/utf-8/schema.json testdata/utf-8/data-[!ef]*.json testdata/utf-8/**/data-[p]ass.yml",
+			[]string{
+				"testdata/utf-8/data-pass.json: pass",
+				"testdata/utf-8/data-pass.yml: pass",
+			}, 0,
+		}, {
+			"-s testdata/utf-8/schema.json -exclude [!d]*.* -exclude data-[!p]* testdata/utf-8",
+			[]string{
+				"testdata/utf-8/data-pass.json: pass",
+				"testdata/utf-8/data-pass.yml: pass",
+			}, 0,
+		}, {
+			"-allow-empty-glob -s testdata/utf-8/schema.json testdata/utf-8/data-pass.json testdata/utf-8/missing-*.json",
+			[]string{"testdata/utf-8/data-pass.json: pass"}, 0,
+		}, {
+			"-allow-empty-glob -s testdata/utf-8/schema.json testdata/utf-8/missing-*.json",
+			[]string{}, 0,
+		}, {
+			"-max-file-size 40 -s testdata/utf-8/schema.json testdata/utf-8/data-pass.json testdata/utf-8/data-fail.json",
+			[]string{
+				"1 of 2 failed validation",
+				"1 of 2 malformed documents",
+				"testdata/utf-8/data-fail.json:1:1: fail: (root): foo is required",
+				"testdata/utf-8/data-pass.json: error: load doc: 41 bytes exceeds -max-file-size of 40",
 			}, 3,
+		}, {
+			"-max-file-size 1K -s testdata/utf-8/schema.json testdata/utf-8/data-pass.json",
+			[]string{"testdata/utf-8/data-pass.json: pass"}, 0,
+		}, {
+			"-j 1 -s testdata/utf-8/schema.json testdata/utf-8/data-pass.json testdata/utf-8/data-fail.json",
+			[]string{
+				"1 of 2 failed validation",
+				"testdata/utf-8/data-fail.json:1:1: fail: (root): foo is required",
+				"testdata/utf-8/data-pass.json: pass",
+			}, 1,
+		}, {
+			"-concurrency 0 -s testdata/utf-8/schema.json testdata/utf-8/data-pass.json",
+			[]string{}, 4,
+		}, {
+			"-config testdata/config/yajsv.yaml",
+			[]string{
+				"1 of 2 failed validation",
+				"testdata/config/configs/api.yaml: pass",
+				"testdata/config/configs/worker.yaml:1:11: fail: /replicas: Must be greater than or equal to 1",
+			}, 1,
+		}, {
+			"-config testdata/config/yajsv.yaml -pointer=false testdata/config/configs/worker.yaml testdata/config/configs/next.draft.yaml",
+			[]string{
+				"1 of 1 failed validation",
+				"testdata/config/configs/worker.yaml:1:11: fail: (root).replicas: Must be greater than or equal to 1",
+			}, 1,
+		}, {
+			"-config testdata/config/invalid.yaml testdata/config/configs/api.yaml",
+			[]string{}, 4,
+		}, {
+			"-config testdata/config/unknown.yaml testdata/config/configs/api.yaml",
+			[]string{}, 4,
+		}, {
+			"-s testdata/yaml-anchors/schema.yaml testdata/yaml-anchors/data-*.json",
+			[]string{
+				"testdata/yaml-anchors/data-fail.json:2:12: fail: (root).owner: String length must be greater than or equal to 1",
+				"testdata/yaml-anchors/data-fail.json:3:12: fail: (root).pets.0: id is required",
+				"testdata/yaml-anchors/data-fail.json:3:22: fail: (root).pets.0.name: String length must be greater than or equal to 1",
+				"testdata/yaml-anchors/data-fail.json:4:11: fail: (root).tags: label is required",
+				"testdata/yaml-anchors/data-pass.json: pass",
+				"1 of 2 failed validation",
+			}, 1,
+		}, {
+			"-s testdata/yaml-anchors/recursive.yaml testdata/yaml-anchors/data-pass.json",
+			[]string{}, 5,
+		}, {
+			"-s testdata/yaml-anchors/schema.yaml testdata/yaml-anchors/laughs.yaml",
+			[]string{
+				"1 of 1 malformed documents",
+				"testdata/yaml-anchors/laughs.yaml: error: load doc: yaml: document contains excessive aliasing",
+			}, 2,
+		}, {
+			"-s testdata/yaml-anchors/laughs.yaml testdata/yaml-anchors/data-pass.json",
+			[]string{}, 5,
+		}, {
+			"lint testdata/yaml-anchors/schema.yaml testdata/yaml-anchors/invalid.yaml",
+			[]string{
+				"1 of 2 failed validation",
+				"testdata/yaml-anchors/invalid.yaml:4:14: fail: (root).definitions.count.minimum: Invalid type. Expected: number, given: string",
+				"testdata/yaml-anchors/invalid.yaml:4:14: fail: (root).properties.total.minimum: Invalid type. Expected: number, given: string",
+				"testdata/yaml-anchors/schema.yaml: pass",
+			}, 1,
+		}, {
+			"-s testdata/relative-refs/schema.json testdata/relative-refs/data-*.json",
+			[]string{
+				"testdata/relative-refs/data-fail.json:4:16: fail: (root).address.country: address.country must be one of the following: \"CA\", \"NZ\", \"US\"",
+				"testdata/relative-refs/data-fail.json:5:27: fail: (root).address.resident.name: String length must be greater than or equal to 1",
+				"testdata/relative-refs/data-pass.json: pass",
+				"1 of 2 failed validation",
+			}, 1,
+		}, {
+			"-s testdata/relative-refs/broken.json testdata/relative-refs/data-pass.json",
+			[]string{}, 5,
+		}, {
+			"lint testdata/relative-refs/schema.json testdata/relative-refs/broken.json",
+			[]string{
+				"1 of 2 failed validation",
+				"testdata/relative-refs/broken.json:3:26: fail: (root).properties.address.$ref: Unresolved reference common/missing.json",
+				"testdata/relative-refs/schema.json: pass",
+			}, 1,
+		}, {
+			"-s testdata/parent-refs/schemas/root.json testdata/parent-refs/data-fail.json testdata/parent-refs/data-pass.json",
+			[]string{
+				"1 of 2 failed validation",
+				"testdata/parent-refs/data-fail.json:1:20: fail: (root).owner.name: String length must be greater than or equal to 1",
+				"testdata/parent-refs/data-pass.json: pass",
+			}, 1,
+		}, {
+			"lint testdata/parent-refs/schemas/root.json",
+			[]string{"testdata/parent-refs/schemas/root.json: pass"}, 0,
+		}, {
+			"-auto-schema testdata/auto-schema/data-*.json testdata/auto-schema/data-pass.yml testdata/auto-schema/sub/data-fail.json testdata/auto-schema/meta.json",
+			[]string{
+				"testdata/auto-schema/data-fail.json:3:11: fail: (root).name: Invalid type. Expected: string, given: integer",
+				"testdata/auto-schema/data-pass.yml: pass",
+				"testdata/auto-schema/meta.json:3:11: fail: (root).type: Must validate at least one schema (anyOf)",
+				"testdata/auto-schema/meta.json:3:11: fail: (root).type: type must be one of the following: \"array\", \"boolean\", \"integer\", \"null\", \"number\", \"object\", \"string\"",
+				"testdata/auto-schema/sub/data-fail.json:1:1: fail: (root): name is required",
+				"3 of 4 failed validation",
+			}, 1,
+		}, {
+			"-auto-schema testdata/auto-schema/none.json testdata/auto-schema/missing.json",
+			[]string{
+				"testdata/auto-schema/missing.json: error: load $schema: testdata/auto-schema/nonexistent.json: unable to load schema: open testdata/auto-schema/nonexistent.json: no such file or directory",
+				"testdata/auto-schema/none.json: error: no $schema and no -s schema to validate against",
+				"2 of 2 malformed documents",
+			}, 2,
+		}, {
+			"-auto-schema -s testdata/multi/base.json testdata/auto-schema/none.json testdata/auto-schema/data-pass.yml",
+			[]string{
+				"testdata/auto-schema/data-pass.yml: pass",
+				"testdata/auto-schema/none.json:1:1: fail: (root): replicas is required",
+				"1 of 2 failed validation",
+			}, 1,
+		}, {
+			"-schema-map testdata/schema-map/schemas.map",
+			[]string{
+				"testdata/schema-map/configs/db.yaml:2:7: fail: (root).port: Invalid type. Expected: integer, given: string",
+				"testdata/schema-map/configs/web.yaml: pass",
+				"testdata/schema-map/events/created.json: pass",
+				"testdata/schema-map/events/updated.json:1:1: fail: (root): at is required",
+				"testdata/schema-map/events/updated.json:2:11: fail: (root).type: type must be one of the following: \"created\", \"deleted\"",
+				"2 of 4 failed validation",
+			}, 1,
+		}, {
+			"-schema-map testdata/schema-map/schemas.map testdata/schema-map/other.json testdata/schema-map/configs/web.yaml",
+			[]string{
+				"testdata/schema-map/configs/web.yaml: pass",
+				"testdata/schema-map/other.json: error: no -s schema and no pattern of testdata/schema-map/schemas.map matches",
+				"1 of 2 malformed documents",
+			}, 2,
+		}, {
+			"-schema-map testdata/schema-map/schemas.map -s testdata/multi/base.json testdata/schema-map/other.json testdata/schema-map/events/created.json",
+			[]string{
+				"testdata/schema-map/events/created.json: pass",
+				"testdata/schema-map/other.json:1:1: fail: (root): name is required",
+				"testdata/schema-map/other.json:1:1: fail: (root): replicas is required",
+				"1 of 2 failed validation",
+			}, 1,
+		}, {
+			"-s testdata/yaml-version/schema.json testdata/yaml-version/data.yml",
+			[]string{
+				"testdata/yaml-version/data.yml:1:10: fail: (root).country: Invalid type. Expected: string, given: boolean",
+				"1 of 1 failed validation",
+			}, 1,
+		}, {
+			"-yaml-version 1.2 -s testdata/yaml-version/schema.json testdata/yaml-version/data.yml",
+			[]string{
+				"testdata/yaml-version/data.yml:2:8: fail: (root).debug: Invalid type. Expected: boolean, given: string",
+				"testdata/yaml-version/data.yml:3:7: fail: (root).mode: mode does not match: 511",
+				"1 of 1 failed validation",
+			}, 1,
+		}, {
+			"-s testdata/precision/schema.json testdata/precision/data.yml",
+			[]string{
+				"testdata/precision/data.yml:1:5: fail: (root).id: Must be less than or equal to 1.8446744073709552e+19",
+				"testdata/precision/data.yml:2:8: fail: (root).ratio: Must be a multiple of 0.1",
+				"1 of 1 failed validation",
+			}, 1,
+		}, {
+			"-s testdata/parquet/schema.json testdata/parquet/data.parquet",
+			[]string{
+				"testdata/parquet/data.parquet[row1]: pass",
+				"testdata/parquet/data.parquet[row2]: fail: (root).age: Invalid type. Expected: integer, given: null",
+				"testdata/parquet/data.parquet[row2]: fail: (root).name: String length must be greater than or equal to 1",
+				"testdata/parquet/data.parquet[row2]: fail: (root).price: Must be greater than or equal to 0",
+				"testdata/parquet/data.parquet[row3]: fail: (root).age: Must be greater than or equal to 0",
+				"2 of 3 failed validation",
+			}, 1,
+		}, {
+			"-limit 1 -s testdata/parquet/schema.json testdata/parquet/data.parquet",
+			[]string{"testdata/parquet/data.parquet: pass"},
+			0,
 		},
 	}
 
@@ -90,6 +922,7 @@ func TestMain(t *testing.T) {
 		out = strings.Replace(out, "/", string(filepath.Separator), -1)
 
 		t.Run(in, func(t *testing.T) {
+			resetFlags()
 			var w strings.Builder
 			exit := realMain(strings.Split(in, " "), &w)
 			if exit != tt.exit {
@@ -113,7 +946,10 @@ func TestMatrix(t *testing.T) {
 		allowBOM                  bool
 	}
 
-	encodings := []string{"utf-8", "utf-16be", "utf-16le", "utf-8_bom", "utf-16be_bom", "utf-16le_bom"}
+	encodings := []string{
+		"utf-8", "utf-16be", "utf-16le", "utf-32be", "utf-32le",
+		"utf-8_bom", "utf-16be_bom", "utf-16le_bom", "utf-32be_bom", "utf-32le_bom",
+	}
 	formats := []string{"json", "yml"}
 	results := []string{"pass", "fail", "error"}
 	tests := []testcase{}
@@ -149,14 +985,14 @@ func TestMatrix(t *testing.T) {
 			want := 0
 			switch {
 			// Schema Errors (exit = 5)
-			// - YAML w/out BOM for UTF-16
+			// - YAML w/out BOM for UTF-16 (UTF-32 is detected)
 			// - JSON w/ BOM but missing allowBOM flag
 			case tt.schemaFmt == "yml" && !schemaBOM && schema16:
 				want = 5
 			case tt.schemaFmt == "json" && schemaBOM && !tt.allowBOM:
 				want = 5
 			// Data Errors (exit = 2)
-			// - YAML w/out BOM for UTF-16
+			// - YAML w/out BOM for UTF-16 (UTF-32 is detected)
 			// - JSON w/ BOM but missing allowBOM flag
 			// - standard malformed files (e.g. data-error)
 			case tt.dataFmt == "yml" && !dataBOM && data16:
@@ -171,6 +1007,7 @@ func TestMatrix(t *testing.T) {
 			}
 
 			// TODO: Cleanup this global monkey-patching
+			resetFlags()
 			*bomFlag = tt.allowBOM
 
 			var w strings.Builder
@@ -181,3 +1018,1769 @@ func TestMatrix(t *testing.T) {
 		})
 	}
 }
+
+func TestStdin(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	defer func() { stdin = os.Stdin }()
+	stdin = strings.NewReader("name: foo\nport: http\n")
+
+	var w strings.Builder
+	exit := realMain([]string{"-s", "testdata/sniff/schema", "-", "testdata/sniff/app.conf"}, &w)
+	if exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	want := strings.Join([]string{
+		"<stdin>:2:7: fail: (root).port: Invalid type. Expected: integer, given: string",
+		"testdata/sniff/app.conf: pass",
+		"1 of 2 failed validation",
+	}, "\n")
+	want = strings.Replace(want, "/", string(filepath.Separator), -1)
+	if got := strings.TrimSpace(w.String()); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSymlinks(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	dir := t.TempDir()
+	for path, content := range map[string]string{
+		"shared/base.json":   `{"foo": 1}`,
+		"configs/app.json":   `{"foo": "bar"}`,
+		"configs/sub/x.json": `{"foo": "baz"}`,
+	} {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"configs/shared":     "../shared",
+		"configs/sub/loop":   "..",
+		"configs/alias.json": "app.json",
+		"configs/zdir.json":  "../shared",
+	} {
+		if err := os.Symlink(filepath.FromSlash(target), filepath.Join(dir, filepath.FromSlash(link))); err != nil {
+			t.Skipf("symlinks unsupported: %s", err)
+		}
+	}
+	configs := filepath.Join(dir, "configs")
+
+	tests := []struct {
+		args []string
+		want []string
+		exit int
+	}{
+		{nil, []string{"alias.json", "app.json", "sub/x.json"}, 0},
+		{[]string{"-follow-symlinks"}, []string{"alias.json", "app.json", "shared/base.json", "sub/x.json"}, 1},
+		{[]string{"-follow-symlinks", "-no-follow-symlinks"}, []string{"alias.json", "app.json", "sub/x.json"}, 0},
+	}
+	for _, tt := range tests {
+		resetFlags()
+		var w strings.Builder
+		args := append(append([]string{"-o", "json", "-s", "testdata/utf-8/schema.json"}, tt.args...), configs)
+		if exit := realMain(args, &w); exit != tt.exit {
+			t.Errorf("%s: exit: got %d, want %d", tt.args, exit, tt.exit)
+		}
+		var results struct{ Documents []struct{ Path string } }
+		if err := json.Unmarshal([]byte(w.String()), &results); err != nil {
+			t.Fatalf("%s: %s", tt.args, err)
+		}
+		var got []string
+		for _, r := range results.Documents {
+			rel, _ := filepath.Rel(configs, r.Path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestStdinList(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	defer func() { stdin = os.Stdin }()
+	stdin = strings.NewReader("testdata/utf-8/data-pass.json\n\ntestdata/utf-8/data-fail.json\n")
+
+	var w strings.Builder
+	exit := realMain([]string{"-s", "testdata/utf-8/schema.json", "-l", "-"}, &w)
+	if exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	want := strings.Join([]string{
+		"testdata/utf-8/data-fail.json:1:1: fail: (root): foo is required",
+		"testdata/utf-8/data-pass.json: pass",
+		"1 of 2 failed validation",
+	}, "\n")
+	want = strings.Replace(want, "/", string(filepath.Separator), -1)
+	if got := strings.TrimSpace(w.String()); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	resetFlags()
+	exit = realMain([]string{"-s", "testdata/utf-8/schema.json", "-l", "-", "-"}, ioutil.Discard)
+	if exit != 4 {
+		t.Errorf("stdin twice: exit: got %d, want 4", exit)
+	}
+
+	resetFlags()
+	w.Reset()
+	stdin = strings.NewReader("testdata/list0/with space.json\x00")
+	exit = realMain([]string{"-s", "testdata/utf-8/schema.json", "-l0", "testdata/list0/files.nul", "-l0", "-"}, &w)
+	if exit != 1 {
+		t.Fatalf("-l0: exit: got %d, want 1", exit)
+	}
+	want = strings.Join([]string{
+		"testdata/list0/[draft].json:1:9: fail: (root).foo: Invalid type. Expected: string, given: integer",
+		"testdata/list0/with space.json: pass",
+		"testdata/list0/with space.json: pass",
+		"1 of 3 failed validation",
+	}, "\n")
+	want = strings.Replace(want, "/", string(filepath.Separator), -1)
+	if got := strings.TrimSpace(w.String()); got != want {
+		t.Errorf("-l0: got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	resetFlags()
+	defer resetFlags()
+	schema, err := filepath.Abs("testdata/utf-8/schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "init.defaultBranch=main"}, args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %s\n%s", args[6], err, out)
+		}
+	}
+	write := func(path, content string) {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("init", "-q")
+	write("same.json", `{"foo": "bar"}`)
+	write("config/old.yaml", "foo: 1\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "initial")
+	run("checkout", "-q", "-b", "feature")
+	write("config/branch.json", `{"foo": "bar"}`)
+	run("add", ".")
+	run("commit", "-q", "-m", "branch")
+	write("config/staged.yaml", "foo: 2\n")
+	run("add", "config/staged.yaml")
+	write("config/staged.yaml", "foo: fixed but unstaged\n")
+	write("untracked.json", `{"foo": "bar"}`)
+	write("notes.txt", "not a document")
+
+	tests := []struct {
+		args []string
+		want []string
+		exit int
+	}{
+		{[]string{"-git-changed"}, []string{
+			"config/staged.yaml: pass",
+			"untracked.json: pass",
+		}, 0},
+		{[]string{"-git-changed=main"}, []string{
+			"config/branch.json: pass",
+			"config/staged.yaml: pass",
+		}, 0},
+		{[]string{"-staged"}, []string{
+			"config/staged.yaml:1:6: fail: (root).foo: Invalid type. Expected: string, given: integer",
+			"1 of 1 failed validation",
+		}, 1},
+		{[]string{"-staged", "config/branch.json", "config/staged.yaml"}, []string{
+			"config/branch.json: pass",
+			"config/staged.yaml:1:6: fail: (root).foo: Invalid type. Expected: string, given: integer",
+			"1 of 2 failed validation",
+		}, 1},
+		{[]string{"-staged", "-ext", "txt"}, nil, 0},
+		{[]string{"-staged", "-max-file-size", "10"}, []string{
+			"config/staged.yaml:1:6: fail: (root).foo: Invalid type. Expected: string, given: integer",
+			"1 of 1 failed validation",
+		}, 1},
+		{[]string{"-staged", "-max-file-size", "5"}, []string{
+			"config/staged.yaml: error: load doc: 7 bytes exceeds -max-file-size of 5",
+			"1 of 1 malformed documents",
+		}, 2},
+		{[]string{"-git-changed=main", "-exclude", "staged.yaml"}, []string{
+			"config/branch.json: pass",
+		}, 0},
+		{[]string{"-git-changed=HEAD", "-ext", "txt"}, nil, 0},
+		{[]string{"-git-changed=missing"}, nil, 5},
+	}
+	for _, tt := range tests {
+		resetFlags()
+		var w strings.Builder
+		args := append([]string{"-s", schema}, tt.args...)
+		if exit := realMain(args, &w); exit != tt.exit {
+			t.Fatalf("%s: exit: got %d, want %d", tt.args, exit, tt.exit)
+		}
+		want := strings.Join(tt.want, "\n")
+		want = strings.Replace(want, "/", string(filepath.Separator), -1)
+		if got := strings.TrimSpace(w.String()); got != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.args, got, want)
+		}
+	}
+}
+
+func TestURL(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/app.yaml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "name: web\nport: http\n")
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name": "api", "port": 80}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var w strings.Builder
+	args := []string{"-s", "testdata/sniff/schema", srv.URL + "/api?v=1", srv.URL + "/app.yaml", srv.URL + "/missing.json"}
+	exit := realMain(args, &w)
+	if exit != 3 {
+		t.Fatalf("exit: got %d, want 3", exit)
+	}
+	want := strings.Join([]string{
+		srv.URL + "/api?v=1: pass",
+		srv.URL + "/app.yaml:2:7: fail: (root).port: Invalid type. Expected: integer, given: string",
+		srv.URL + "/missing.json: error: load doc: GET " + srv.URL + "/missing.json: 404 Not Found",
+		"1 of 3 failed validation",
+		"1 of 3 malformed documents",
+	}, "\n")
+	if got := strings.TrimSpace(w.String()); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSchemaURL(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/schemas/config.yaml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "type: object\nproperties:\n  name:\n    type: string\n  port:\n    $ref: defs.json#/definitions/port\n")
+	})
+	mux.HandleFunc("/schemas/defs.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"definitions": {"port": {"type": "integer", "maximum": 65535}}}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var w strings.Builder
+	cache := t.TempDir()
+	args := []string{"-cache-dir", cache, "-s", srv.URL + "/schemas/config.yaml", "testdata/sniff/app.conf", "testdata/sniff/db.conf"}
+	exit := realMain(args, &w)
+	if exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	want := strings.Join([]string{
+		"testdata/sniff/app.conf: pass",
+		"testdata/sniff/db.conf:3:11: fail: (root).port: Invalid type. Expected: integer, given: string",
+		"1 of 2 failed validation",
+	}, "\n")
+	if got := strings.TrimSpace(w.String()); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	w.Reset()
+	exit = realMain([]string{"-cache-dir", cache, "-s", srv.URL + "/schemas/missing.json", "testdata/sniff/db.conf"}, &w)
+	if exit != 5 {
+		t.Fatalf("exit: got %d, want 5", exit)
+	}
+	// Fragments select a subschema of the fetched document
+	port := filepath.Join(cache, "port.json")
+	ioutil.WriteFile(port, []byte("70000"), 0644)
+	resetFlags()
+	w.Reset()
+	exit = realMain([]string{"-cache-dir", cache, "-s", srv.URL + "/schemas/defs.json#/definitions/port", port}, &w)
+	if exit != 1 {
+		t.Fatalf("fragment: exit: got %d, want 1", exit)
+	}
+	if !strings.Contains(w.String(), "Must be less than or equal to 65535") {
+		t.Errorf("fragment: got\n%s", w.String())
+	}
+}
+
+func TestRemoteRefs(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var fetched, revalidated int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/defs.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			revalidated++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fetched++
+		fmt.Fprint(w, `{"definitions": {"port": {"type": "integer"}}}`)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	schema := filepath.Join(dir, "schema.json")
+	ioutil.WriteFile(schema, []byte(`{"properties": {"port": {"$ref": "`+srv.URL+`/defs.json#/definitions/port"}}}`), 0644)
+	cache := filepath.Join(dir, "cache")
+
+	for _, tt := range []struct {
+		ttl                  string
+		fetched, revalidated int
+	}{
+		{"24h", 1, 0},
+		{"24h", 1, 0}, // cached
+		{"0s", 1, 1},  // revalidated with the ETag
+	} {
+		var w strings.Builder
+		args := []string{"-cache-dir", cache, "-cache-ttl", tt.ttl, "-s", schema, "testdata/sniff/db.conf"}
+		if exit := realMain(args, &w); exit != 1 {
+			t.Errorf("%s: exit: got %d, want 1", tt.ttl, exit)
+		}
+		want := "testdata/sniff/db.conf:3:11: fail: (root).port: Invalid type. Expected: integer, given: string"
+		if got := strings.Split(w.String(), "\n")[0]; got != want {
+			t.Errorf("%s: got %s, want %s", tt.ttl, got, want)
+		}
+		if fetched != tt.fetched || revalidated != tt.revalidated {
+			t.Errorf("%s: fetched %d and revalidated %d, want %d and %d", tt.ttl, fetched, revalidated, tt.fetched, tt.revalidated)
+		}
+		resetFlags()
+	}
+	// Each copy is written to a temporary file first and renamed into place
+	if files, _ := filepath.Glob(filepath.Join(cache, "*")); len(files) != 2 {
+		t.Errorf("cache files: got %q, want a .body and .json", files)
+	}
+
+	// Stale copies are still used when the server is unreachable
+	srv.Close()
+	var w strings.Builder
+	if exit := realMain([]string{"-cache-dir", cache, "-cache-ttl", "0s", "-s", schema, "testdata/sniff/db.conf"}, &w); exit != 1 {
+		t.Errorf("offline: exit: got %d, want 1", exit)
+	}
+}
+
+func TestWriteCacheFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.body")
+	a, b := bytes.Repeat([]byte("a"), 1<<20), bytes.Repeat([]byte("b"), 1<<20)
+	if err := writeCacheFile(path, a); err != nil {
+		t.Fatal(err)
+	}
+
+	// Readers only ever see a whole copy while others are replacing it
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(buf []byte) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				writeCacheFile(path, buf)
+			}
+		}([][]byte{a, b}[i%2])
+	}
+	for i := 0; i < 100; i++ {
+		got, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, a) && !bytes.Equal(got, b) {
+			t.Fatalf("read a partial copy of %d bytes", len(got))
+		}
+	}
+	wg.Wait()
+}
+
+func TestSchemaCache(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	dir := t.TempDir()
+	cache := filepath.Join(dir, "cache")
+	write := func(name, content string) {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("schemas/schema.yaml", "properties:\n  port:\n    $ref: defs.yaml#/port\n")
+	write("schemas/defs.yaml", "port:\n  type: integer\n")
+	write("refs/a.json", `{"$id": "https://example.com/a.json"}`)
+	write("data.json", `{"port": 80}`)
+	args := []string{
+		"-schema-cache", "-cache-dir", cache, "-r", filepath.Join(dir, "refs"),
+		"-s", filepath.Join(dir, "schemas", "schema.yaml"), filepath.Join(dir, "data.json"),
+	}
+	run := func(args []string, want int) {
+		t.Helper()
+		resetFlags()
+		if exit := realMain(args, ioutil.Discard); exit != want {
+			t.Errorf("%s: exit: got %d, want %d", args, exit, want)
+		}
+	}
+
+	// The cached sources are used rather than loading the files again, which
+	// shows once those of defs.yaml are changed to reject the port
+	tamper := func(typ string) {
+		t.Helper()
+		entries, err := filepath.Glob(filepath.Join(cache, "sources", "*.json"))
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("entries: got %v, %v", entries, err)
+		}
+		buf, err := ioutil.ReadFile(entries[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf = bytes.Replace(buf, []byte(`"type":"`+typ+`"`), []byte(`"type":"string"`), 1)
+		if err := ioutil.WriteFile(entries[0], buf, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run(args, 0)
+	tamper("integer")
+	run(args, 1)
+	run(args[1:], 0)
+
+	// Changing any file, or the files -r matches, loads them all again
+	write("schemas/defs.yaml", "port:\n  type: number\n")
+	run(args, 0)
+	tamper("number")
+	run(args, 1)
+	write("refs/b.json", `{"$id": "https://example.com/b.json"}`)
+	run(args, 0)
+}
+
+func TestOffline(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"definitions": {"port": {"type": "integer"}}}`)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	schema := filepath.Join(dir, "schema.json")
+	ioutil.WriteFile(schema, []byte(`{"properties": {"port": {"$ref": "`+srv.URL+`/defs.json#/definitions/port"}}}`), 0644)
+	cache := filepath.Join(dir, "cache")
+
+	var w strings.Builder
+	for _, tt := range []struct {
+		args []string
+		exit int
+	}{
+		{[]string{"-cache-dir", cache, "-s", schema, "testdata/sniff/db.conf"}, 5},                 // not cached
+		{[]string{"-s", schema, srv.URL + "/doc.json"}, 5},                                         // remote document
+		{[]string{"-s", schema, "gs://lake/configs/*.json"}, 5},                                    // remote glob
+		{[]string{"-cache-dir", cache, "-s", srv.URL + "/defs.json", "testdata/sniff/db.conf"}, 5}, // remote schema
+	} {
+		if exit := realMain(append([]string{"-offline"}, tt.args...), &w); exit != tt.exit {
+			t.Errorf("%v: exit: got %d, want %d", tt.args, exit, tt.exit)
+		}
+		resetFlags()
+	}
+	if requests != 0 {
+		t.Fatalf("got %d requests with -offline, want 0", requests)
+	}
+
+	// Cached schemas are used regardless of -cache-ttl
+	if exit := realMain([]string{"-cache-dir", cache, "-s", schema, "testdata/sniff/db.conf"}, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	resetFlags()
+	if exit := realMain([]string{"-offline", "-cache-ttl", "0s", "-cache-dir", cache, "-s", schema, "testdata/sniff/db.conf"}, &w); exit != 1 {
+		t.Errorf("cached: exit: got %d, want 1", exit)
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want 1", requests)
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	want, err := ioutil.ReadFile("testdata/defaults/defaulted.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var w strings.Builder
+	args := []string{"-apply-defaults", "-", "-s", "testdata/defaults/schema.json", "testdata/defaults/data-pass.yml", "testdata/defaults/data-fail.yml"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	if got := w.String(); got != string(want) {
+		t.Errorf("stdout: got\n%s\nwant\n%s", got, want)
+	}
+
+	resetFlags()
+	dir := t.TempDir()
+	args[1] = dir
+	if exit := realMain(args, ioutil.Discard); exit != 1 {
+		t.Fatalf("dir: exit: got %d, want 1", exit)
+	}
+	got, err := ioutil.ReadFile(filepath.Join(dir, "testdata/defaults/data-pass.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("dir: got\n%s\nwant\n%s", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "testdata/defaults/data-fail.json")); !os.IsNotExist(err) {
+		t.Errorf("dir: failing document written: %v", err)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		args []string
+		exit int
+	}{
+		{[]string{"help"}, 0},
+		{[]string{"help", "lint"}, 0},
+		{[]string{"help", "convert"}, 4},
+		{[]string{"validate", "-s", "testdata/utf-8/schema.json", "testdata/utf-8/data-pass.json"}, 0},
+		{[]string{"validate", "-s", "testdata/utf-8/schema.json", "testdata/utf-8/data-fail.json"}, 1},
+		{[]string{"lint", "-o", "json", "testdata/utf-8/schema.json"}, 0},
+		{[]string{"lint", "-s", "testdata/utf-8/schema.json", "testdata/utf-8/schema.json"}, 4},
+		{[]string{"bundle", "-r", "testdata/utf-8/schema.json", "testdata/bundle/schema.json"}, 0},
+		{[]string{"bundle", "-o", "json", "testdata/bundle/schema.json"}, 4},
+		{[]string{"bundle", "-strict-json", "testdata/bundle/schema.json"}, 4},
+		{[]string{"lint", "-strict-yaml", "testdata/utf-8/schema.json"}, 4},
+	}
+	for _, tt := range tests {
+		resetFlags()
+		if exit := realMain(tt.args, ioutil.Discard); exit != tt.exit {
+			t.Errorf("%s: exit: got %d, want %d", tt.args, exit, tt.exit)
+		}
+	}
+	resetFlags()
+}
+
+func TestBundle(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var w strings.Builder
+	if exit := realMain([]string{"bundle", "testdata/bundle/schema.json"}, &w); exit != 0 {
+		t.Fatalf("exit: got %d, want 0", exit)
+	}
+	want, err := ioutil.ReadFile("testdata/bundle/bundled.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := w.String(); got != string(want) {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// Remote refs are inlined too, and unresolved ones are schema errors
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"definitions": {"port": {"type": "integer"}}}`)
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	schema := filepath.Join(dir, "schema.json")
+	ioutil.WriteFile(schema, []byte(`{"properties": {"port": {"$ref": "`+srv.URL+`/defs.json#/definitions/port"}}}`), 0644)
+	resetFlags()
+	w.Reset()
+	if exit := realMain([]string{"bundle", "-cache-dir", filepath.Join(dir, "cache"), schema}, &w); exit != 0 {
+		t.Fatalf("remote: exit: got %d, want 0", exit)
+	}
+	var got interface{}
+	if err := json.Unmarshal([]byte(w.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	wantDoc := map[string]interface{}{
+		"definitions": map[string]interface{}{
+			"defs": map[string]interface{}{"definitions": map[string]interface{}{"port": map[string]interface{}{"type": "integer"}}},
+		},
+		"properties": map[string]interface{}{"port": map[string]interface{}{"$ref": "#/definitions/defs/definitions/port"}},
+	}
+	if !reflect.DeepEqual(got, wantDoc) {
+		t.Errorf("remote: got %v, want %v", got, wantDoc)
+	}
+
+	ioutil.WriteFile(schema, []byte(`{"properties": {"port": {"$ref": "missing.json"}}}`), 0644)
+	resetFlags()
+	if exit := realMain([]string{"bundle", schema}, &w); exit != 5 {
+		t.Errorf("missing: exit: got %d, want 5", exit)
+	}
+}
+
+func TestFormatPlugin(t *testing.T) {
+	if testing.Short() {
+		t.Skip("building a plugin is slow")
+	}
+	resetFlags()
+	defer resetFlags()
+	if !formatPlugins {
+		var w strings.Builder
+		if exit := realMain([]string{"-format-plugin", "formats.so", "-s", "testdata/format-plugin/schema.json", "testdata/format-plugin/data-pass.json"}, &w); exit != 5 {
+			t.Errorf("unsupported: exit: got %d, want 5", exit)
+		}
+		t.Skip("plugins unsupported by this build")
+	}
+
+	so := filepath.Join(t.TempDir(), "formats.so")
+	args := []string{"build", "-buildmode=plugin", "-o", so}
+	if raceEnabled {
+		args = append(args, "-race")
+	}
+	cmd := exec.Command("go", append(args, "plugin.go")...)
+	cmd.Dir = "testdata/format-plugin"
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("plugins unsupported: %s\n%s", err, out)
+	}
+
+	var w strings.Builder
+	args = []string{"-format-plugin", so, "-s", "testdata/format-plugin/schema.json", "testdata/format-plugin/data-pass.json", "testdata/format-plugin/data-fail.json"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	want := strings.Join([]string{
+		"testdata/format-plugin/data-fail.json:2:13: fail: (root).ticket: Does not match format 'ticket'",
+		"testdata/format-plugin/data-pass.json: pass",
+		"1 of 2 failed validation",
+	}, "\n")
+	if got := strings.TrimSpace(w.String()); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	resetFlags()
+	if exit := realMain([]string{"-format-plugin", "testdata/format-plugin/plugin.go", "-s", "testdata/format-plugin/schema.json", "testdata/format-plugin/data-pass.json"}, &w); exit != 5 {
+		t.Errorf("not a plugin: exit: got %d, want 5", exit)
+	}
+}
+
+func TestFormatCommand(t *testing.T) {
+	if _, err := exec.LookPath("grep"); err != nil {
+		t.Skip("no grep to check formats with")
+	}
+	resetFlags()
+	defer resetFlags()
+
+	var w strings.Builder
+	args := []string{"-format-command", "upper-code=grep -qx [A-Z]*", "-s", "testdata/format-command/schema.json", "testdata/format-command/data-pass.json", "testdata/format-command/data-fail.json"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	want := strings.Join([]string{
+		"testdata/format-command/data-fail.json:2:11: fail: (root).code: Does not match format 'upper-code'",
+		"testdata/format-command/data-pass.json: pass",
+		"1 of 2 failed validation",
+	}, "\n")
+	if got := strings.TrimSpace(w.String()); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	for _, arg := range []string{"upper-code", "=grep", "upper-code=no-such-command-yajsv"} {
+		resetFlags()
+		if exit := realMain([]string{"-format-command", arg, "-s", "testdata/format-command/schema.json", "testdata/format-command/data-pass.json"}, &w); exit != 4 {
+			t.Errorf("%s: exit: got %d, want 4", arg, exit)
+		}
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/catalog.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"schemas": [
+			{"name": "GitHub Workflow", "url": "%[1]s/workflow.json", "fileMatch": ["**/.github/workflows/*.{yml,yaml}"]},
+			{"name": "package.json", "url": "%[1]s/package.json", "fileMatch": ["package.json"]},
+			{"name": "Anything", "url": "%[1]s/missing.json", "fileMatch": ["*.json", "!package.json"]}
+		]}`, "http://"+r.Host)
+	})
+	mux.HandleFunc("/workflow.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"required": ["name", "jobs"], "properties": {"jobs": {"type": "object"}}}`)
+	})
+	mux.HandleFunc("/package.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"properties": {"name": {"type": "string"}, "version": {"type": "string"}}}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var w strings.Builder
+	cache := t.TempDir()
+	args := []string{"-catalog", "-catalog-url", srv.URL + "/catalog.json", "-cache-dir", cache, "testdata/catalog/.github/workflows/*", "testdata/catalog/package.json", "testdata/catalog/other.yml"}
+	exit := realMain(args, &w)
+	if exit != 3 {
+		t.Fatalf("exit: got %d, want 3", exit)
+	}
+	want := strings.Join([]string{
+		"testdata/catalog/.github/workflows/broken.yaml:2:7: fail: (root).jobs: Invalid type. Expected: object, given: array",
+		"testdata/catalog/.github/workflows/ci.yml: pass",
+		"testdata/catalog/other.yml: error: no -s schema and no entry of " + srv.URL + "/catalog.json matches",
+		"testdata/catalog/package.json:3:14: fail: (root).version: Invalid type. Expected: string, given: integer",
+		"2 of 4 failed validation",
+		"1 of 4 malformed documents",
+	}, "\n")
+	if got := strings.TrimSpace(w.String()); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// The catalog and its schemas are cached
+	srv.Close()
+	resetFlags()
+	w.Reset()
+	args = []string{"-offline", "-catalog", "-catalog-url", srv.URL + "/catalog.json", "-cache-dir", cache, "testdata/catalog/package.json"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Errorf("offline: exit: got %d, want 1", exit)
+	}
+}
+
+func TestCatalogGlob(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		match         bool
+	}{
+		{"package.json", "/src/app/package.json", true},
+		{"package.json", "/src/app/package.json5", false},
+		{"*.yml", "/src/ci.yml", true},
+		{"tsconfig.*.json", "/src/tsconfig.base.json", true},
+		{".github/workflows/*.yml", "/src/.github/workflows/ci.yml", true},
+		{".github/workflows/*.yml", "/src/.github/workflows/sub/ci.yml", false},
+		{"**/.github/workflows/*.{yml,yaml}", "/src/.github/workflows/ci.yaml", true},
+		{"**/openapi.json", "/openapi.json", true},
+		{"/.gitlab-ci.yml", "/src/.gitlab-ci.yml", true},
+		{"docker-compose.y?ml", "/src/docker-compose.yaml", true},
+		{"chart/**/values.yaml", "/src/chart/a/b/values.yaml", true},
+		{"*.json", "/src/a.json5", false},
+	}
+	for _, tt := range tests {
+		if got := catalogGlob(tt.pattern).MatchString(tt.path); got != tt.match {
+			t.Errorf("%s: %s: got %v, want %v", tt.pattern, tt.path, got, tt.match)
+		}
+	}
+}
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		match         bool
+	}{
+		{"data[0-9].json", "data1.json", true},
+		{"data[0-9].json", "dataa.json", false},
+		{"data[!0-9].json", "dataa.json", true},
+		{"data[!0-9].json", "data1.json", false},
+		{"data[^0-9].json", "dataa.json", true},
+		{"[ab]/*.json", "b/c.json", true},
+		{"[ab]/*.json", "c/c.json", false},
+		{"a[!x]b", "a/b", false},
+		{"[]]", "]", true},
+		{"[.]json", ".json", true},
+		{"[.]json", "xjson", false},
+		{"[\\]", "\\", true},
+		{"[[:alpha:]]", "a]", true},
+		{"data[.json", "data[.json", true},
+		{"**/[a-c]*.yaml", "x/y/b.yaml", true},
+		{"**/[a-c]*.yaml", "x/y/d.yaml", false},
+		{"{[ab],c}.json", "a.json", true},
+	}
+	for _, tt := range tests {
+		re := regexp.MustCompile("^" + globRegexp(tt.pattern) + "$")
+		if got := re.MatchString(tt.path); got != tt.match {
+			t.Errorf("%s: %s: got %v, want %v", tt.pattern, tt.path, got, tt.match)
+		}
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"*.json", []string{"*.json"}},
+		{"*.{json,yml}", []string{"*.json", "*.yml"}},
+		{"{a,b}/*.y{a,}ml", []string{"a/*.yaml", "a/*.yml", "b/*.yaml", "b/*.yml"}},
+		{"*.{json,y{a,}ml}", []string{"*.json", "*.yaml", "*.yml"}},
+		{"a}{b", []string{"a}{b"}},
+	}
+	for _, tt := range tests {
+		if got := expandBraces(tt.pattern); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestObjectURI(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	objects := map[string]string{
+		"configs/app.yaml":  "name: web\nport: http\n",
+		"configs/db.json":   `{"name": "db", "port": 5432}`,
+		"configs/README.md": "# not a config",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		const prefix = "/storage/v1/b/lake/o"
+		if r.URL.Path == prefix {
+			var items []map[string]string
+			for k := range objects {
+				if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+					items = append(items, map[string]string{"name": k})
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+			return
+		}
+		obj, ok := objects[strings.TrimPrefix(r.URL.Path, prefix+"/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, obj)
+	}))
+	defer srv.Close()
+
+	store := &gcsStore{endpoint: srv.URL, client: srv.Client()}
+	store.once.Do(func() {})
+	defer func(gs objectStore) { objectStores["gs"] = gs }(objectStores["gs"])
+	objectStores["gs"] = store
+
+	var w strings.Builder
+	exit := realMain([]string{"-s", "testdata/sniff/schema", "gs://lake/configs/*.[jy]*", "gs://lake/missing.json"}, &w)
+	if exit != 3 {
+		t.Fatalf("exit: got %d, want 3", exit)
+	}
+	want := strings.Join([]string{
+		"gs://lake/configs/app.yaml:2:7: fail: (root).port: Invalid type. Expected: integer, given: string",
+		"gs://lake/configs/db.json: pass",
+		"gs://lake/missing.json: error: load doc: GET " + srv.URL + "/storage/v1/b/lake/o/missing.json?alt=media: 404 Not Found",
+		"1 of 3 failed validation",
+		"1 of 3 malformed documents",
+	}, "\n")
+	if got := strings.TrimSpace(w.String()); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// Globs without matches follow the same rules as those of files
+	for _, tt := range []struct {
+		args []string
+		want string
+		exit int
+	}{
+		{nil, "gs://lake/configs/db.json: pass\ngs://lake/none/*.json: error: load doc: gs://lake/none/*.json: no such file or directory\n1 of 2 malformed documents", 2},
+		{[]string{"-allow-empty-glob"}, "gs://lake/configs/db.json: pass", 0},
+		{[]string{"-allow-empty-glob", "-max-file-size", "20"}, "gs://lake/configs/db.json: error: load doc: 28 bytes exceeds -max-file-size of 20\n1 of 1 malformed documents", 2},
+	} {
+		resetFlags()
+		w.Reset()
+		args := append(tt.args, "-s", "testdata/sniff/schema", "gs://lake/none/*.json", "gs://lake/configs/db.json")
+		if exit := realMain(args, &w); exit != tt.exit {
+			t.Errorf("%s: exit: got %d, want %d", tt.args, exit, tt.exit)
+		}
+		if got := strings.TrimSpace(w.String()); got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPRetries(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	defer func(d time.Duration) { httpRetryDelay = d }(httpRetryDelay)
+	httpRetryDelay = time.Millisecond
+
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests%3 != 0 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"type": "object", "required": ["name"]}`)
+	}))
+	defer srv.Close()
+
+	args := []string{"-cache-dir", t.TempDir(), "-s", srv.URL + "/schema.json", "testdata/sniff/db.conf"}
+	if exit := realMain(args, ioutil.Discard); exit != 5 {
+		t.Fatalf("no retries: exit: got %d, want 5", exit)
+	}
+
+	resetFlags()
+	requests = 0
+	if exit := realMain(append([]string{"-http-retries", "2"}, args...), ioutil.Discard); exit != 0 {
+		t.Fatalf("retries: exit: got %d, want 0", exit)
+	}
+	if requests != 3 {
+		t.Errorf("retries: got %d requests, want 3", requests)
+	}
+
+	// Requests go through the proxy rather than to the unresolvable host
+	resetFlags()
+	requests = 2
+	args[3] = "http://schemas.invalid/schema.json"
+	if exit := realMain(append([]string{"-http-proxy", srv.URL}, args...), ioutil.Discard); exit != 0 {
+		t.Fatalf("proxy: exit: got %d, want 0", exit)
+	}
+	resetFlags()
+	if exit := realMain(append([]string{"-http-proxy", "::"}, args...), ioutil.Discard); exit != 4 {
+		t.Fatalf("invalid proxy: exit: got %d, want 4", exit)
+	}
+}
+
+func TestSchemaHeader(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	os.Setenv("YAJSV_TEST_TOKEN", "t0ken")
+	defer os.Unsetenv("YAJSV_TEST_TOKEN")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/schema.json":
+			fmt.Fprint(w, `{"properties": {"port": {"$ref": "port.json"}}}`)
+		case "/port.json":
+			fmt.Fprint(w, `{"type": "integer"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	for _, tt := range []struct {
+		header string
+		exit   int
+	}{
+		{host + "=Authorization: Bearer $YAJSV_TEST_TOKEN", 1},
+		{"authorization:Bearer t0ken", 5}, // only sent over HTTPS without a host
+		{"example.com=Authorization: Bearer t0ken", 5},
+		{"Authorization", 4},
+	} {
+		resetFlags()
+		args := []string{"-schema-header", tt.header, "-cache-dir", t.TempDir(), "-s", srv.URL + "/schema.json", "testdata/sniff/db.conf"}
+		if exit := realMain(args, ioutil.Discard); exit != tt.exit {
+			t.Errorf("%s: exit: got %d, want %d", tt.header, exit, tt.exit)
+		}
+	}
+}
+
+func TestSchemaHeaderRedirect(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var leaked string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = r.Header.Get("X-Api-Key")
+		fmt.Fprint(w, `{"type": "object"}`)
+	}))
+	defer other.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/moved.json":
+			http.Redirect(w, r, "/schema.json", http.StatusFound)
+		case r.URL.Path == "/elsewhere.json":
+			http.Redirect(w, r, other.URL+"/schema.json", http.StatusFound)
+		case r.Header.Get("X-Api-Key") != "s3cret":
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		default:
+			fmt.Fprint(w, `{"type": "object"}`)
+		}
+	}))
+	defer srv.Close()
+	header := strings.TrimPrefix(srv.URL, "http://") + "=X-Api-Key: s3cret"
+
+	// Redirects within the host keep the header, others drop it
+	for _, path := range []string{"/moved.json", "/elsewhere.json"} {
+		resetFlags()
+		args := []string{"-schema-header", header, "-cache-dir", t.TempDir(), "-s", srv.URL + path, "testdata/sniff/db.conf"}
+		if exit := realMain(args, ioutil.Discard); exit != 0 {
+			t.Errorf("%s: exit: got %d, want 0", path, exit)
+		}
+	}
+	if leaked != "" {
+		t.Errorf("sent X-Api-Key: %s to %s", leaked, other.URL)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	os.Setenv("YAJSV_TEST_REGISTRY_SECRET", "s3cret")
+	defer os.Unsetenv("YAJSV_TEST_REGISTRY_SECRET")
+
+	subjects := map[string]string{
+		"/subjects/config-value/versions/latest": `{"subject": "config-value", "version": 2, "schemaType": "JSON",
+			"schema": "{\"type\": \"object\", \"properties\": {\"port\": {\"$ref\": \"port.json\"}}}",
+			"references": [{"name": "port.json", "subject": "port", "version": 1}]}`,
+		"/subjects/port/versions/1": `{"subject": "port", "version": 1, "schemaType": "JSON",
+			"schema": "{\"type\": \"integer\", \"maximum\": 65535}"}`,
+		"/subjects/orders-value/versions/1": `{"subject": "orders-value", "version": 1, "schema": "{\"type\": \"record\"}"}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "key" || password != "s3cret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, ok := subjects[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	var w strings.Builder
+	args := []string{"-registry-url", srv.URL, "-registry-auth", "key:$YAJSV_TEST_REGISTRY_SECRET", "-s", "registry://config-value", "testdata/sniff/app.conf", "testdata/sniff/db.conf"}
+	exit := realMain(args, &w)
+	if exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	want := strings.Join([]string{
+		"testdata/sniff/app.conf: pass",
+		"testdata/sniff/db.conf:3:11: fail: (root).port: Invalid type. Expected: integer, given: string",
+		"1 of 2 failed validation",
+	}, "\n")
+	if got := strings.TrimSpace(w.String()); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	for _, args := range [][]string{
+		{"-registry-url", srv.URL, "-s", "registry://config-value"},
+		{"-registry-url", srv.URL, "-registry-auth", "key:s3cret", "-s", "registry://orders-value:1"},
+		{"-registry-url", srv.URL, "-registry-auth", "key:s3cret", "-s", "registry://config-value:next"},
+	} {
+		resetFlags()
+		if exit := realMain(append(args, "testdata/sniff/app.conf"), ioutil.Discard); exit != 5 {
+			t.Errorf("%v: exit: got %d, want 5", args, exit)
+		}
+	}
+}
+
+func TestOutputJSON(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var w strings.Builder
+	args := []string{"-o", "json", "-s", "testdata/utf-8/schema.json", "testdata/utf-8/data-pass.json", "testdata/utf-8/data-fail.json", "testdata/utf-8/data-error.json"}
+	exit := realMain(args, &w)
+	if exit != 3 {
+		t.Fatalf("exit: got %d, want 3", exit)
+	}
+
+	var report struct {
+		Documents []result
+		Summary   summary
+	}
+	if err := json.Unmarshal([]byte(w.String()), &report); err != nil {
+		t.Fatalf("unmarshal: %s\n%s", err, w.String())
+	}
+	if want := (summary{Total: 3, Pass: 1, Fail: 1, Error: 1}); report.Summary != want {
+		t.Errorf("summary: got %+v, want %+v", report.Summary, want)
+	}
+	if len(report.Documents) != 3 {
+		t.Fatalf("documents: got %d, want 3", len(report.Documents))
+	}
+	fail := report.Documents[1]
+	if fail.Status != statusFail || len(fail.Failures) != 1 || fail.Failures[0].Code != "YJ1002" {
+		t.Errorf("fail: got %+v", fail)
+	}
+}
+
+func TestReportJUnit(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	dir, err := ioutil.TempDir("", "yajsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	report := filepath.Join(dir, "report.xml")
+
+	var w strings.Builder
+	args := []string{"-report", "junit=" + report, "-s", "testdata/utf-8/schema.json", "testdata/utf-8/data-pass.json", "testdata/utf-8/data-fail.json"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	if !strings.Contains(w.String(), "data-pass.json: pass") {
+		t.Errorf("missing console output, got\n%s", w.String())
+	}
+
+	buf, err := ioutil.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got junitTestSuites
+	if err := xml.Unmarshal(buf, &got); err != nil {
+		t.Fatalf("unmarshal: %s\n%s", err, buf)
+	}
+	if got.Tests != 2 || got.Failures != 1 || got.Errors != 0 {
+		t.Errorf("counts: got %d tests, %d failures, %d errors", got.Tests, got.Failures, got.Errors)
+	}
+	cases := got.Suites[0].Cases
+	if len(cases) != 2 || cases[0].Failure == nil || cases[1].Failure != nil {
+		t.Errorf("cases: got %+v", cases)
+	}
+}
+
+func TestOutputTAP(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var w strings.Builder
+	args := []string{"-o", "tap", "-s", "testdata/utf-8/schema.json", "testdata/utf-8/data-pass.json", "testdata/utf-8/data-fail.json"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	want := `TAP version 13
+1..2
+not ok 1 - testdata/utf-8/data-fail.json
+  ---
+  status: fail
+  failures:
+    - "(root): foo is required"
+  ...
+ok 2 - testdata/utf-8/data-pass.json
+`
+	if got := w.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestOutputCheckstyle(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var w strings.Builder
+	args := []string{"-o", "checkstyle", "-s", "testdata/utf-8/schema.json", "testdata/utf-8/data-pass.json", "testdata/utf-8/data-fail.json", "testdata/utf-8/data-error.json"}
+	if exit := realMain(args, &w); exit != 3 {
+		t.Fatalf("exit: got %d, want 3", exit)
+	}
+
+	var got checkstyleReport
+	if err := xml.Unmarshal([]byte(w.String()), &got); err != nil {
+		t.Fatalf("unmarshal: %s\n%s", err, w.String())
+	}
+	if len(got.Files) != 3 {
+		t.Fatalf("files: got %d, want 3", len(got.Files))
+	}
+	if errs := got.Files[0].Errors; len(errs) != 1 || errs[0].Source != "yajsv.error" {
+		t.Errorf("error: got %+v", errs)
+	}
+	if errs := got.Files[1].Errors; len(errs) != 1 || errs[0].Source != "yajsv.fail.required" {
+		t.Errorf("fail: got %+v", errs)
+	}
+	if errs := got.Files[2].Errors; len(errs) != 0 {
+		t.Errorf("pass: got %+v", errs)
+	}
+}
+
+func TestOutputTeamCity(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var w strings.Builder
+	args := []string{"-format", "teamcity", "-s", "testdata/utf-8/schema.json", "testdata/utf-8/data-pass.json", "testdata/utf-8/data-fail.json"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	want := `##teamcity[testSuiteStarted name='yajsv']
+##teamcity[testStarted name='testdata/utf-8/data-fail.json']
+##teamcity[testFailed name='testdata/utf-8/data-fail.json' message='1 validation failure(s)' details='testdata/utf-8/data-fail.json:1:1: fail: (root): foo is required']
+##teamcity[testFinished name='testdata/utf-8/data-fail.json']
+##teamcity[testStarted name='testdata/utf-8/data-pass.json']
+##teamcity[testFinished name='testdata/utf-8/data-pass.json']
+##teamcity[testSuiteFinished name='yajsv']
+`
+	if got := w.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestColor(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var w strings.Builder
+	args := []string{"-color", "always", "-s", "testdata/utf-8/schema.json", "testdata/utf-8/data-pass.json"}
+	if exit := realMain(args, &w); exit != 0 {
+		t.Fatalf("exit: got %d, want 0", exit)
+	}
+	if got, want := w.String(), "\x1b[32mtestdata/utf-8/data-pass.json: pass\x1b[0m\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	os.Setenv("NO_COLOR", "1")
+	defer os.Unsetenv("NO_COLOR")
+	if color, err := useColor("auto", os.Stdout); err != nil || color {
+		t.Errorf("NO_COLOR: got %t, %v", color, err)
+	}
+	if _, err := useColor("bogus", os.Stdout); err == nil {
+		t.Error("bogus: expected error")
+	}
+}
+
+func TestPointer(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var w strings.Builder
+	args := []string{"-pointer", "-o", "json", "-s", "testdata/utf-8/schema.json", "testdata/utf-8/data-fail.json"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	if !strings.Contains(w.String(), `"field": ""`) {
+		t.Errorf("expected root pointer field, got\n%s", w.String())
+	}
+
+	// Keys containing dots and slashes are unambiguous as pointers
+	resetFlags()
+	w.Reset()
+	args = []string{"-pointer", "-s", "testdata/pointer/schema.json", "testdata/pointer/data.json"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	want := "testdata/pointer/data.json:3:17: fail: /a.b/c~1d/0: Invalid type. Expected: string, given: integer\n1 of 1 failed validation\n"
+	if got := w.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestShowValue(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var w strings.Builder
+	args := []string{"-q", "-show-value", "-s", "testdata/pointer/schema.json", "testdata/pointer/data.json"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	want := "testdata/pointer/data.json:3:17: fail: (root).a.b.c/d.0: Invalid type. Expected: string, given: integer, got: 1 (integer)\n"
+	if got := w.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"none", ""},
+		{"counts", "1 of 2 failed validation\n"},
+		{"full", "1 of 2 failed validation\ntestdata/utf-8/data-fail.json:1:1: fail: (root): foo is required\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			resetFlags()
+			defer resetFlags()
+
+			var w strings.Builder
+			args := []string{"-summary", tt.mode, "-s", "testdata/utf-8/schema.json", "testdata/utf-8/data-fail.json", "testdata/utf-8/data-pass.json"}
+			if exit := realMain(args, &w); exit != 1 {
+				t.Fatalf("exit: got %d, want 1", exit)
+			}
+			// Strip the per-document lines that precede the summary
+			got := w.String()
+			got = strings.Replace(got, "testdata/utf-8/data-pass.json: pass\n", "", 1)
+			got = strings.Replace(got, "testdata/utf-8/data-fail.json:1:1: fail: (root): foo is required\n", "", 1)
+			if got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummaryOnly(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var w strings.Builder
+	args := []string{"-summary-only", "-s", "testdata/utf-8/schema.json", "testdata/utf-8/data-*.json"}
+	if exit := realMain(args, &w); exit != 3 {
+		t.Fatalf("exit: got %d, want 3", exit)
+	}
+	if got, want := w.String(), "3 documents: 1 pass, 1 fail, 1 error\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMaxErrors(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var w strings.Builder
+	args := []string{"-q", "-max-errors", "2", "-s", "testdata/max-errors/schema.json", "testdata/max-errors/data.json"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	want := `testdata/max-errors/data.json:1:2: fail: (root).0: Invalid type. Expected: string, given: integer
+testdata/max-errors/data.json:1:5: fail: (root).1: Invalid type. Expected: string, given: integer
+testdata/max-errors/data.json: fail: and 2 more
+`
+	if got := w.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFailFast(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	// Generate enough failing documents that some must be skipped
+	dir, err := ioutil.TempDir("", "yajsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	n := 10 * (runtime.GOMAXPROCS(0) + 10)
+	for i := 0; i < n; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("%04d.json", i))
+		if err := ioutil.WriteFile(path, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var w strings.Builder
+	args := []string{"-fail-fast", "-o", "json", "-s", "testdata/utf-8/schema.json", filepath.Join(dir, "*.json")}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	var report struct{ Summary summary }
+	if err := json.Unmarshal([]byte(w.String()), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Summary.Total == 0 || report.Summary.Total >= n {
+		t.Errorf("total: got %d, want between 1 and %d", report.Summary.Total, n)
+	}
+
+	// A single worker stops at the first document, in input order
+	resetFlags()
+	w.Reset()
+	args = []string{"-fail-fast", "-j", "1", "-s", "testdata/utf-8/schema.json", filepath.Join(dir, "0042.json"), filepath.Join(dir, "*.json")}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("-j 1: exit: got %d, want 1", exit)
+	}
+	want := filepath.Join(dir, "0042.json") + ":1:1: fail: (root): foo is required\n1 of 1 failed validation\n"
+	if got := w.String(); got != want {
+		t.Errorf("-j 1: got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestOutputFile(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	dir, err := ioutil.TempDir("", "yajsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out.json")
+	tap := filepath.Join(dir, "out.tap")
+	txt := filepath.Join(dir, "out.txt")
+
+	var w strings.Builder
+	args := []string{"-o", "json", "-output", out, "-report", "tap=" + tap, "-report", "text=" + txt, "-s", "testdata/utf-8/schema.json", "testdata/utf-8/data-fail.json"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+
+	// Console gets text progress while the reports go to their files
+	fail := "testdata/utf-8/data-fail.json:1:1: fail: (root): foo is required\n"
+	if got, want := w.String(), fail+"1 of 1 failed validation\n"; got != want {
+		t.Errorf("console: got\n%s\nwant\n%s", got, want)
+	}
+	buf, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(buf) {
+		t.Errorf("output: invalid JSON\n%s", buf)
+	}
+	buf, err = ioutil.ReadFile(tap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(buf), "TAP version 13\n") {
+		t.Errorf("tap: got\n%s", buf)
+	}
+	buf, err = ioutil.ReadFile(txt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf), fail+"1 of 1 failed validation\n"; got != want {
+		t.Errorf("text: got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCodes(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var w strings.Builder
+	args := []string{"-q", "-codes", "-s", "testdata/utf-8/schema.json", "testdata/utf-8/data-fail.json"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	want := "testdata/utf-8/data-fail.json:1:1: fail: [YJ1002] (root): foo is required\n"
+	if got := w.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestOutputUnit(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var w strings.Builder
+	args := []string{"-o", "json", "-output-unit", "basic", "-s", "testdata/output-unit/schema.json", "testdata/output-unit/data.json"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	var report struct{ Documents []result }
+	if err := json.Unmarshal([]byte(w.String()), &report); err != nil {
+		t.Fatal(err)
+	}
+	unit := report.Documents[0].Output
+	if unit == nil || unit.Valid || len(unit.Errors) != 3 {
+		t.Fatalf("got %+v", unit)
+	}
+	locs := make(map[string]string)
+	abs := make(map[string]string)
+	for _, e := range unit.Errors {
+		locs[*e.KeywordLocation] = *e.InstanceLocation
+		abs[*e.KeywordLocation] = e.AbsoluteKeywordLocation
+	}
+	want := map[string]string{
+		"/required":                       "",
+		"/properties/name/$ref/minLength": "/name",
+		"/properties/tags/items/type":     "/tags/1",
+	}
+	if !reflect.DeepEqual(locs, want) {
+		t.Errorf("got %v, want %v", locs, want)
+	}
+	uri, err := fileURI("testdata/output-unit/schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := abs["/properties/name/$ref/minLength"], uri.String()+"#/definitions/name/minLength"; got != want {
+		t.Errorf("absoluteKeywordLocation: got %q, want %q", got, want)
+	}
+
+	resetFlags()
+	if exit := realMain([]string{"-output-unit", "basic", "-s", "testdata/utf-8/schema.json", "testdata/utf-8/data-pass.json"}, &w); exit != 4 {
+		t.Errorf("without -o json: got exit %d, want 4", exit)
+	}
+}
+
+func TestStats(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var w strings.Builder
+	args := []string{"-stats", "-s", "testdata/utf-8/schema.json", "testdata/utf-8/data-pass.json", "testdata/utf-8/data-fail.json"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	got := w.String()
+	for _, want := range []string{
+		"stats: 2 documents, ",
+		"stats: slowest: testdata/utf-8/data-pass.json: ",
+		"stats: slowest: testdata/utf-8/data-fail.json: ",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in\n%s", want, got)
+		}
+	}
+}
+
+func TestOutputQuickfix(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var w strings.Builder
+	args := []string{"-format", "quickfix", "-s", "testdata/utf-8/schema.json", "testdata/utf-8/data-pass.yml", "testdata/utf-8/data-fail.yml", "testdata/utf-8/data-error.yml"}
+	if exit := realMain(args, &w); exit != 3 {
+		t.Fatalf("exit: got %d, want 3", exit)
+	}
+	want := `testdata/utf-8/data-error.yml:1:1: error: load doc: yaml: found unexpected end of stream
+testdata/utf-8/data-fail.yml:2:1: (root): foo is required
+`
+	if got := w.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFailureOrder(t *testing.T) {
+	defer resetFlags()
+	want := []string{
+		"testdata/failure-order/data.json:1:7: fail: (root).e: Invalid type. Expected: string, given: integer",
+		"testdata/failure-order/data.json:1:15: fail: (root).d: Invalid type. Expected: string, given: integer",
+		"testdata/failure-order/data.json:1:23: fail: (root).c: Invalid type. Expected: string, given: integer",
+		"testdata/failure-order/data.json:1:31: fail: (root).b: Invalid type. Expected: string, given: integer",
+		"testdata/failure-order/data.json:1:39: fail: (root).a: Invalid type. Expected: string, given: integer",
+		"testdata/failure-order/data.json: fail: and 2 more",
+		"1 of 1 failed validation",
+	}
+	// Property failures come from map iteration, so repeat to catch any
+	// dependence on it
+	for i := 0; i < 20; i++ {
+		resetFlags()
+		var w strings.Builder
+		realMain([]string{"-max-errors", "5", "-s", "testdata/failure-order/schema.json", "testdata/failure-order/data.json"}, &w)
+		if got := strings.Split(strings.TrimSpace(w.String()), "\n"); !reflect.DeepEqual(got, want) {
+			t.Fatalf("run %d: got\n%s\nwant\n%s", i, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
+
+func TestOutputAJV(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var w strings.Builder
+	args := []string{"-o", "ajv", "-s", "testdata/output-unit/schema.json", "testdata/output-unit/data.json"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	var got []struct {
+		Path   string
+		Valid  bool
+		Errors []ajvError
+	}
+	if err := json.Unmarshal([]byte(w.String()), &got); err != nil {
+		t.Fatalf("unmarshal: %s\n%s", err, w.String())
+	}
+	if len(got) != 1 || got[0].Valid || len(got[0].Errors) != 3 {
+		t.Fatalf("got %+v", got)
+	}
+	want := []struct{ instancePath, schemaPath, keyword, param, message string }{
+		{"", "#/required", "required", "missingProperty", "must have required property 'id'"},
+		{"/name", "#/definitions/name/minLength", "minLength", "limit", "must NOT have fewer than 2 characters"},
+		{"/tags/1", "#/properties/tags/items/type", "type", "type", "must be string"},
+	}
+	for i, tt := range want {
+		e := got[0].Errors[i]
+		if e.InstancePath != tt.instancePath || e.SchemaPath != tt.schemaPath || e.Keyword != tt.keyword || e.Message != tt.message {
+			t.Errorf("errors[%d]: got %+v, want %+v", i, e, tt)
+		}
+		if _, ok := e.Params[tt.param]; !ok {
+			t.Errorf("errors[%d]: missing param %q in %v", i, tt.param, e.Params)
+		}
+	}
+
+	// Enums report their allowed values as an array
+	resetFlags()
+	w.Reset()
+	args = []string{"-o", "ajv", "-s", "testdata/xml/schema.json", "testdata/xml/data-fail.xml"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("enum: exit: got %d, want 1", exit)
+	}
+	got = nil
+	if err := json.Unmarshal([]byte(w.String()), &got); err != nil {
+		t.Fatalf("unmarshal: %s\n%s", err, w.String())
+	}
+	for _, e := range got[0].Errors {
+		if e.Keyword != "enum" {
+			continue
+		}
+		if want := []interface{}{"1", "2"}; !reflect.DeepEqual(e.Params["allowedValues"], want) {
+			t.Errorf("enum: allowedValues: got %#v, want %#v", e.Params["allowedValues"], want)
+		}
+		if want := "must be equal to one of the allowed values"; e.Message != want {
+			t.Errorf("enum: message: got %q, want %q", e.Message, want)
+		}
+		return
+	}
+	t.Errorf("enum: no error in %+v", got)
+}
+
+func TestSortedOutput(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var w strings.Builder
+	args := []string{"-s", "testdata/utf-8/schema.json", "testdata/utf-8/data-pass.json", "testdata/utf-8/data-fail.yml", "testdata/utf-8/data-pass.yml"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	want := `testdata/utf-8/data-fail.yml:2:1: fail: (root): foo is required
+testdata/utf-8/data-pass.json: pass
+testdata/utf-8/data-pass.yml: pass
+1 of 3 failed validation
+`
+	if got := w.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestByKeyword(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	var w strings.Builder
+	args := []string{"-summary", "none", "-by-keyword", "-s", "testdata/output-unit/schema.json", "testdata/output-unit/data.json", "testdata/utf-8/data-fail.json"}
+	if exit := realMain(args, &w); exit != 1 {
+		t.Fatalf("exit: got %d, want 1", exit)
+	}
+	want := `required: 2 occurrences across 2 files
+minLength: 1 occurrences across 1 files
+type: 1 occurrences across 1 files
+`
+	if got := w.String(); !strings.HasSuffix(got, want) {
+		t.Errorf("got\n%s\nwant suffix\n%s", got, want)
+	}
+}
+
+func TestQuietLevels(t *testing.T) {
+	pass := "testdata/utf-8/data-pass.json: pass\n"
+	fail := "testdata/utf-8/data-fail.json:1:1: fail: (root): foo is required\n"
+	errs := "testdata/utf-8/data-error.json: error: validate: invalid character 'o' in literal null (expecting 'u')\n"
+	counts := "1 of 3 failed validation\n1 of 3 malformed documents\n"
+
+	tests := []struct {
+		flags []string
+		want  string
+	}{
+		{nil, errs + fail + pass + counts},
+		{[]string{"-q"}, errs + fail},
+		{[]string{"-qq"}, errs},
+		{[]string{"-q", "-q"}, errs},
+		{[]string{"-qqq"}, ""},
+		{[]string{"-q", "-qq", "-q"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.flags, " "), func(t *testing.T) {
+			resetFlags()
+			defer resetFlags()
+
+			var w strings.Builder
+			args := append(tt.flags, "-s", "testdata/utf-8/schema.json", "testdata/utf-8/data-*.json")
+			if exit := realMain(args, &w); exit != 3 {
+				t.Fatalf("exit: got %d, want 3", exit)
+			}
+			if got := w.String(); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvFlags(t *testing.T) {
+	errs := "testdata/utf-8/data-error.json: error: validate: invalid character 'o' in literal null (expecting 'u')\n"
+	fail := "testdata/utf-8/data-fail.json:1:1: fail: (root): foo is required\n"
+
+	tests := []struct {
+		env  map[string]string
+		args []string
+		want string
+		exit int
+	}{
+		{map[string]string{"YAJSV_SCHEMA": "testdata/utf-8/schema.json", "YAJSV_QUIET": "true"}, nil, errs + fail, 3},
+		{map[string]string{"YAJSV_S": "testdata/utf-8/schema.json", "YAJSV_Q": "2"}, nil, errs, 3},
+		{map[string]string{"YAJSV_SCHEMA": "testdata/missing.json", "YAJSV_FORMAT": "json", "YAJSV_QQ": "1"}, []string{"-o", "text", "-s", "testdata/utf-8/schema.json"}, errs, 3},
+		{map[string]string{"YAJSV_MAX_ERRORS": "lots"}, []string{"-s", "testdata/utf-8/schema.json"}, "", 4},
+		{map[string]string{"YAJSV_FORMAT_COMMAND": "foo=true", "YAJSV_Q": "2"}, []string{"-s", "testdata/utf-8/schema.json"}, errs, 3},
+		{map[string]string{"YAJSV_CONFIG": "testdata/config/format-command.yaml", "YAJSV_Q": "2"}, []string{"-s", "testdata/utf-8/schema.json"}, errs, 3},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.env), func(t *testing.T) {
+			resetFlags()
+			defer resetFlags()
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+
+			var w strings.Builder
+			args := append(tt.args, "testdata/utf-8/data-*.json")
+			if exit := realMain(args, &w); exit != tt.exit {
+				t.Fatalf("exit: got %d, want %d", exit, tt.exit)
+			}
+			if got := w.String(); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustedConfig(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+	schema, err := filepath.Abs("testdata/utf-8/schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile("doc.json", []byte(`{"foo": "bar"}`), 0644)
+	config := fmt.Sprintf("schema: %q\ndocuments: [doc.json]\n", schema)
+
+	for _, key := range []string{
+		"format-command: foo=touch pwned", "format-plugin: evil.so", "output: pwned", "report: junit=pwned",
+		"registry-url: https://attacker.example", "http-proxy: http://attacker.example:3128",
+	} {
+		resetFlags()
+		ioutil.WriteFile(configFile, []byte(config+key+"\n"), 0644)
+		var w strings.Builder
+		if exit := realMain(nil, &w); exit != 4 {
+			t.Errorf("%s: exit: got %d, want 4", key, exit)
+		}
+		if _, err := os.Stat("pwned"); err == nil {
+			t.Fatalf("%s: wrote pwned", key)
+		}
+	}
+
+	// Other commands don't take the documents as their schema arguments
+	ioutil.WriteFile(configFile, []byte(config), 0644)
+	for _, cmd := range []string{"lint", "bundle"} {
+		resetFlags()
+		if exit := realMain([]string{cmd}, ioutil.Discard); exit != 4 {
+			t.Errorf("%s: exit: got %d, want 4", cmd, exit)
+		}
+	}
+	os.Remove(configFile)
+
+	// An explicit -config is trusted like the command line
+	ioutil.WriteFile("trusted.yaml", []byte(config+"output: out.txt\n"), 0644)
+	resetFlags()
+	var w strings.Builder
+	if exit := realMain([]string{"-config", "trusted.yaml"}, &w); exit != 0 {
+		t.Errorf("-config: exit: got %d, want 0", exit)
+	}
+	if _, err := os.Stat("out.txt"); err != nil {
+		t.Errorf("-config: %s", err)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// msgpackToJSON converts a MessagePack encoded document to JSON text. Map
+// keys are formatted as strings, binary data is base64 encoded and
+// timestamps are RFC 3339 strings.
+func msgpackToJSON(buf []byte) ([]byte, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(buf))
+	dec.SetMapDecoder(func(d *msgpack.Decoder) (interface{}, error) {
+		return d.DecodeUntypedMap()
+	})
+	v, err := dec.DecodeInterface()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(stringKeys(v))
+}
+
+// stringKeys converts maps within v with non-string keys, as decoded from
+// binary formats, to maps with the keys formatted as strings.
+func stringKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = stringKeys(e)
+		}
+		return m
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = stringKeys(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = stringKeys(e)
+		}
+	}
+	return v
+}
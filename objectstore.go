@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/oauth2/google"
+)
+
+// objectStore lists and downloads the objects of a cloud storage bucket
+type objectStore interface {
+	list(ctx context.Context, bucket, prefix string) ([]string, error)
+	get(ctx context.Context, bucket, key string) ([]byte, error)
+}
+
+// objectStores map the schemes of object URIs to the store of the provider,
+// replaced for testing
+var objectStores = map[string]objectStore{
+	"s3": &s3Store{},
+	"gs": &gcsStore{endpoint: "https://storage.googleapis.com"},
+}
+
+// parseObjectURI splits an object URI like `s3://bucket/key` into the
+// store, bucket and key, or returns false if it isn't one.
+func parseObjectURI(uri string) (objectStore, string, string, bool) {
+	i := strings.Index(uri, "://")
+	if i < 0 {
+		return nil, "", "", false
+	}
+	store, ok := objectStores[uri[:i]]
+	if !ok {
+		return nil, "", "", false
+	}
+	bucket, key, _ := strings.Cut(uri[i+3:], "/")
+	return store, bucket, key, true
+}
+
+// isObjectURI reports if the document argument is an S3 or GCS object URI
+// rather than a local path or glob.
+func isObjectURI(arg string) bool {
+	_, _, _, ok := parseObjectURI(arg)
+	return ok
+}
+
+// globObjects expands the object URI pattern, e.g. `s3://bucket/logs/*.json`,
+// by listing the keys with the prefix before the first glob character and
+// matching the rest with path.Match. URIs without glob characters are
+// returned as is without listing the bucket.
+func globObjects(pattern string) ([]string, error) {
+	store, bucket, key, _ := parseObjectURI(pattern)
+	i := strings.IndexAny(key, "*?[\\")
+	if i < 0 {
+		return []string{pattern}, nil
+	}
+	if _, err := path.Match(key, ""); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeoutFlag)
+	defer cancel()
+	keys, err := store.list(ctx, bucket, key[:i])
+	if err != nil {
+		return nil, err
+	}
+	var uris []string
+	scheme := pattern[:strings.Index(pattern, "://")]
+	for _, k := range keys {
+		if ok, _ := path.Match(key, k); ok {
+			uris = append(uris, fmt.Sprintf("%s://%s/%s", scheme, bucket, k))
+		}
+	}
+	if len(uris) == 0 {
+		return nil, fmt.Errorf("%s: %w", pattern, errNoMatches)
+	}
+	return uris, nil
+}
+
+// fetchObject downloads the object at uri within `-timeout`, returning the
+// contents along with the key for detecting the format.
+func fetchObject(uri string) ([]byte, string, error) {
+	store, bucket, key, _ := parseObjectURI(uri)
+	ctx, cancel := context.WithTimeout(context.Background(), *timeoutFlag)
+	defer cancel()
+	buf, err := store.get(ctx, bucket, key)
+	return buf, key, err
+}
+
+// s3Store reads objects from S3 with the default AWS credentials and region
+// of the environment, e.g. `AWS_PROFILE` or `AWS_REGION`.
+type s3Store struct {
+	once   sync.Once
+	client *s3.Client
+	err    error
+}
+
+func (s *s3Store) init(ctx context.Context) error {
+	s.once.Do(func() {
+		var cfg aws.Config
+		cfg, s.err = config.LoadDefaultConfig(ctx)
+		s.client = s3.NewFromConfig(cfg)
+	})
+	return s.err
+}
+
+func (s *s3Store) list(ctx context.Context, bucket, prefix string) ([]string, error) {
+	if err := s.init(ctx); err != nil {
+		return nil, err
+	}
+	var keys []string
+	pages := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+	}
+	return keys, nil
+}
+
+func (s *s3Store) get(ctx context.Context, bucket, key string) ([]byte, error) {
+	if err := s.init(ctx); err != nil {
+		return nil, err
+	}
+	obj, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Body.Close()
+	if err := tooLarge(aws.ToInt64(obj.ContentLength)); err != nil {
+		return nil, err
+	}
+	return readDoc(obj.Body)
+}
+
+// gcsStore reads objects from Google Cloud Storage through the JSON API with
+// the application default credentials, e.g. from `gcloud auth application-default login`.
+type gcsStore struct {
+	endpoint string
+	once     sync.Once
+	client   *http.Client
+	err      error
+}
+
+func (s *gcsStore) init() error {
+	s.once.Do(func() {
+		// The token source outlives the context of any single request
+		s.client, s.err = google.DefaultClient(context.Background(), "https://www.googleapis.com/auth/devstorage.read_only")
+	})
+	return s.err
+}
+
+// open GETs the JSON API url, returning the response for the caller to read
+// and close.
+func (s *gcsStore) open(ctx context.Context, u string) (*http.Response, error) {
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	return resp, nil
+}
+
+// call GETs the JSON API url, returning the response body.
+func (s *gcsStore) call(ctx context.Context, u string) ([]byte, error) {
+	resp, err := s.open(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (s *gcsStore) list(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		q := url.Values{"prefix": {prefix}, "fields": {"items/name,nextPageToken"}}
+		if token != "" {
+			q.Set("pageToken", token)
+		}
+		buf, err := s.call(ctx, fmt.Sprintf("%s/storage/v1/b/%s/o?%s", s.endpoint, url.PathEscape(bucket), q.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := json.Unmarshal(buf, &page); err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			keys = append(keys, item.Name)
+		}
+		if token = page.NextPageToken; token == "" {
+			return keys, nil
+		}
+	}
+}
+
+func (s *gcsStore) get(ctx context.Context, bucket, key string) ([]byte, error) {
+	resp, err := s.open(ctx, fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", s.endpoint, url.PathEscape(bucket), url.PathEscape(key)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := tooLarge(resp.ContentLength); err != nil {
+		return nil, err
+	}
+	return readDoc(resp.Body)
+}
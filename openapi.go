@@ -0,0 +1,143 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// isOpenAPI reports if doc is an OpenAPI 3.x document rather than a schema
+func isOpenAPI(doc interface{}) bool {
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	v, _ := m["openapi"].(string)
+	return strings.HasPrefix(v, "3.")
+}
+
+// convertOpenAPI rewrites the schema objects of an OpenAPI document, under
+// `components/schemas` and any `schema` of a parameter, header or media
+// type, in place as JSON Schema. See convertOpenAPISchema.
+func convertOpenAPI(doc interface{}) {
+	for _, schema := range openAPISchemas(doc, "") {
+		walkSchema(schema, &url.URL{}, convertOpenAPISchema)
+	}
+}
+
+// openAPISchemas returns the root schema objects within v, a value of an
+// OpenAPI document under key. Examples are skipped since their contents are
+// arbitrary.
+func openAPISchemas(v interface{}, key string) []interface{} {
+	var schemas []interface{}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			switch {
+			case k == "example" || k == "examples":
+			case k == "schema":
+				schemas = append(schemas, child)
+			case k == "schemas" && key == "components":
+				if m, ok := child.(map[string]interface{}); ok {
+					for _, s := range m {
+						schemas = append(schemas, s)
+					}
+				}
+			default:
+				schemas = append(schemas, openAPISchemas(child, k)...)
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			schemas = append(schemas, openAPISchemas(child, key)...)
+		}
+	}
+	return schemas
+}
+
+// convertOpenAPISchema rewrites the OpenAPI specific keywords of a schema
+// object. `nullable` adds null to its type and enum. A `discriminator` of
+// `oneOf` or `anyOf` refs is replaced by an `if`/`then` for each value of the
+// property, mapped or named after the schema, so that documents are only
+// validated against the schema they select rather than failing `oneOf` when
+// several match, e.g. subtypes sharing a base with `allOf`.
+func convertOpenAPISchema(ptr string, base *url.URL, schema map[string]interface{}) {
+	if nullable, _ := schema["nullable"].(bool); nullable {
+		if t, ok := schema["type"].(string); ok {
+			schema["type"] = []interface{}{t, "null"}
+		}
+		if enum, ok := schema["enum"].([]interface{}); ok {
+			schema["enum"] = append(enum, nil)
+		}
+	}
+	delete(schema, "nullable")
+
+	d, _ := schema["discriminator"].(map[string]interface{})
+	prop, _ := d["propertyName"].(string)
+	if prop == "" {
+		return
+	}
+	var kw string
+	var refs []string
+	for _, k := range []string{"oneOf", "anyOf"} {
+		if subs, ok := schema[k].([]interface{}); ok {
+			kw = k
+			for _, sub := range subs {
+				m, _ := sub.(map[string]interface{})
+				ref, ok := m["$ref"].(string)
+				if !ok {
+					return // inline alternatives can't be selected
+				}
+				refs = append(refs, ref)
+			}
+			break
+		}
+	}
+	if kw == "" {
+		return
+	}
+
+	// Mapped values come first, then the implicit names of unmapped refs
+	var values []string
+	targets := make(map[string]string)
+	mapped := make(map[string]bool)
+	mapping, _ := d["mapping"].(map[string]interface{})
+	for value, ref := range mapping {
+		if ref, ok := ref.(string); ok {
+			if !strings.ContainsAny(ref, "#/") {
+				ref = "#/components/schemas/" + ref
+			}
+			values = append(values, value)
+			targets[value] = ref
+			mapped[ref] = true
+		}
+	}
+	sort.Strings(values)
+	for _, ref := range refs {
+		if !mapped[ref] {
+			name := path.Base(ref)
+			values = append(values, name)
+			targets[name] = ref
+		}
+	}
+
+	enum := make([]interface{}, len(values))
+	allOf, _ := schema["allOf"].([]interface{})
+	for i, value := range values {
+		enum[i] = value
+		allOf = append(allOf, map[string]interface{}{
+			"if": map[string]interface{}{
+				"required":   []interface{}{prop},
+				"properties": map[string]interface{}{prop: map[string]interface{}{"const": value}},
+			},
+			"then": map[string]interface{}{"$ref": targets[value]},
+		})
+	}
+	schema["allOf"] = append([]interface{}{map[string]interface{}{
+		"required":   []interface{}{prop},
+		"properties": map[string]interface{}{prop: map[string]interface{}{"enum": enum}},
+	}}, allOf...)
+	delete(schema, kw)
+	delete(schema, "discriminator")
+}
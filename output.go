@@ -0,0 +1,714 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Document status values, see printUsage for descriptions
+const (
+	statusPass  = "pass"
+	statusFail  = "fail"
+	statusError = "error"
+)
+
+// result is the outcome of validating a single document
+type result struct {
+	Path     string    `json:"path"`
+	Status   string    `json:"status"`
+	Failures []failure `json:"failures,omitempty"`
+	Error    string    `json:"error,omitempty"`
+
+	// Warnings are non-fatal findings that don't affect the status or exit
+	// code, e.g. a passing document is reported as `pass with warnings`
+	Warnings []failure `json:"warnings,omitempty"`
+
+	// Truncated is the number of failures omitted due to `-max-errors`
+	Truncated int `json:"truncated,omitempty"`
+
+	// Output is the standardized output unit selected by `-output-unit`
+	Output *outputUnit `json:"output,omitempty"`
+
+	// Stats are the measurements for the document when `-stats` is set
+	Stats *docStats `json:"stats,omitempty"`
+
+	// defaulted is the passing document with the defaults of its schemas
+	// applied when `-apply-defaults` is set
+	defaulted []byte
+
+	// file is the path of the file containing the document when it holds
+	// several, e.g. `data.ndjson` for a Path of `data.ndjson:3`, and line
+	// is where the document starts within it
+	file string
+	line int
+}
+
+// filePath returns the path of the file containing the document.
+func (r result) filePath() string {
+	if r.file != "" {
+		return r.file
+	}
+	return r.Path
+}
+
+// location returns the location of f within the file containing the
+// document, falling back to the document path if the position is unknown.
+func (r result) location(f failure) string {
+	if f.Line == 0 {
+		return r.Path
+	}
+	return f.location(r.filePath())
+}
+
+// failure is a single schema validation failure within a document
+type failure struct {
+	Field       string `json:"field"`
+	Type        string `json:"type"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	Line        int    `json:"line,omitempty"`
+	Column      int    `json:"column,omitempty"`
+	Value       string `json:"value,omitempty"`
+	Schema      string `json:"schema,omitempty"` // with multiple `-s` schemas
+
+	message                 string // pre-formatted gojsonschema message for text output
+	instanceLocation        string // JSON pointer to the failing value
+	keywordLocation         string // JSON pointer to the failing schema keyword
+	schemaLocation          string // keywordLocation with $refs resolved in the schema document
+	absoluteKeywordLocation string // schemaLocation as a URI, if the schema has one
+	details                 gojsonschema.ErrorDetails
+}
+
+// sortFailures orders fs by their position in the document, then by the
+// failing value and schema keyword, since gojsonschema reports the failures
+// of object properties in map order.
+func sortFailures(fs []failure) {
+	sort.SliceStable(fs, func(i, j int) bool {
+		a, b := fs[i], fs[j]
+		switch {
+		case a.Line != b.Line:
+			return a.Line < b.Line
+		case a.Column != b.Column:
+			return a.Column < b.Column
+		case a.instanceLocation != b.instanceLocation:
+			return a.instanceLocation < b.instanceLocation
+		case a.keywordLocation != b.keywordLocation:
+			return a.keywordLocation < b.keywordLocation
+		}
+		return a.Description < b.Description
+	})
+}
+
+// location returns path suffixed with the line and column of the failure
+// when known, e.g. `path:line:col`
+func (f failure) location(path string) string {
+	if f.Line == 0 {
+		return path
+	}
+	return fmt.Sprintf("%s:%d:%d", path, f.Line, f.Column)
+}
+
+// maxSnippetLen is the length that value snippets are truncated to
+const maxSnippetLen = 64
+
+// snippet formats v as truncated JSON text followed by its JSON type,
+// e.g. `"abc" (string)`.
+func snippet(v interface{}) string {
+	typ := "null"
+	switch v := v.(type) {
+	case bool:
+		typ = "boolean"
+	case json.Number:
+		typ = "number"
+		if _, err := v.Int64(); err == nil {
+			typ = "integer"
+		}
+	case float64, int, int64:
+		typ = "number"
+	case string:
+		typ = "string"
+	case []interface{}:
+		typ = "array"
+	case map[string]interface{}:
+		typ = "object"
+	}
+
+	buf, err := json.Marshal(v)
+	if err != nil {
+		buf = []byte(fmt.Sprint(v))
+	}
+	text := string(buf)
+	if utf8.RuneCountInString(text) > maxSnippetLen {
+		text = string([]rune(text)[:maxSnippetLen-3]) + "..."
+	}
+	return fmt.Sprintf("%s (%s)", text, typ)
+}
+
+// outputFormats maps `-o` values to writers that render the results once
+// every document is validated. Text is special-cased to also stream each
+// document as it completes, see writeTextResult.
+var outputFormats = map[string]func(io.Writer, []result) error{
+	"text":       writeTextSummary,
+	"json":       writeJSON,
+	"tap":        writeTAP,
+	"checkstyle": writeCheckstyle,
+	"teamcity":   writeTeamCity,
+	"quickfix":   writeQuickfix,
+	"ajv":        writeAJV,
+}
+
+// reportFormats maps `-report` and `-output` formats to writers for the
+// report files that are produced in addition to the console output.
+var reportFormats = map[string]func(io.Writer, []result) error{
+	"text":       writeText,
+	"json":       writeJSON,
+	"junit":      writeJUnit,
+	"tap":        writeTAP,
+	"checkstyle": writeCheckstyle,
+	"teamcity":   writeTeamCity,
+	"quickfix":   writeQuickfix,
+	"ajv":        writeAJV,
+}
+
+// writeReport creates the file at path and renders the results to it.
+func writeReport(path string, report func(io.Writer, []result) error, results []result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := report(f, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ANSI escape sequences for colorized text output
+const (
+	ansiReset  = "\x1b[0m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+)
+
+var statusColors = map[string]string{
+	statusPass:  ansiGreen,
+	statusFail:  ansiRed,
+	statusError: ansiYellow,
+}
+
+// colorText is set when text output should be colorized, see useColor
+var colorText bool
+
+// useColor resolves the `-color` mode for output to w. In auto mode color
+// is only used for terminals and when NO_COLOR is unset or empty, see
+// https://no-color.org/
+func useColor(mode string, w io.Writer) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		if os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		f, ok := w.(*os.File)
+		if !ok {
+			return false, nil
+		}
+		fi, err := f.Stat()
+		return err == nil && fi.Mode()&os.ModeCharDevice != 0, nil
+	}
+	return false, fmt.Errorf("unknown -color mode: %s", mode)
+}
+
+// textLines returns the lines for r, colorized by status if requested.
+// Warnings are always colored yellow.
+func (r result) textLines(color bool) string {
+	lines := r.lines()
+	if color {
+		warnings := len(lines) - len(r.Warnings)
+		for i, l := range lines {
+			c := statusColors[r.Status]
+			if i >= warnings {
+				c = ansiYellow
+			}
+			lines[i] = c + l + ansiReset
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// quiet reports if the result is suppressed by the `-q` level. Passing
+// documents are hidden first, then failures and warnings and finally errors.
+func (r result) quiet() bool {
+	switch r.Status {
+	case statusPass:
+		if len(r.Warnings) > 0 {
+			return quietFlag >= 2
+		}
+		return quietFlag >= 1
+	case statusFail:
+		return quietFlag >= 2
+	}
+	return quietFlag >= 3
+}
+
+// lines returns the text output lines for the result, one per failure,
+// followed by one per warning.
+func (r result) lines() []string {
+	var lines []string
+	switch r.Status {
+	case statusFail:
+		for _, f := range r.Failures {
+			lines = append(lines, fmt.Sprintf("%s: fail: %s", r.location(f), f.message))
+		}
+		if r.Truncated > 0 {
+			lines = append(lines, fmt.Sprintf("%s: fail: and %d more", r.Path, r.Truncated))
+		}
+	case statusError:
+		lines = append(lines, fmt.Sprintf("%s: error: %s", r.Path, r.Error))
+	default:
+		if len(r.Warnings) > 0 {
+			lines = append(lines, fmt.Sprintf("%s: pass with warnings", r.Path))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s: pass", r.Path))
+		}
+	}
+	for _, f := range r.Warnings {
+		lines = append(lines, fmt.Sprintf("%s: warning: %s", r.location(f), f.message))
+	}
+	return lines
+}
+
+// writeTextResult prints the status line(s) for a single document.
+func writeTextResult(w io.Writer, r result, color bool) {
+	if *summaryOnlyFlag || r.quiet() {
+		return
+	}
+	fmt.Fprintln(w, r.textLines(color))
+}
+
+// writeText prints the status lines for every document followed by the
+// summary, never colorized. Used when text is written to a report file
+// rather than streamed to the console.
+func writeText(w io.Writer, results []result) error {
+	for _, r := range results {
+		writeTextResult(w, r, false)
+	}
+	return textSummary(w, results, false)
+}
+
+// writeTextSummary prints the console summary after the streamed results.
+func writeTextSummary(w io.Writer, results []result) error {
+	return textSummary(w, results, colorText)
+}
+
+// textSummary prints the counts of failures and errors, if any, after all
+// the per-document results. The `-summary` flag controls whether the
+// failure and error lines are repeated beneath the counts.
+func textSummary(w io.Writer, results []result, color bool) error {
+	if *summaryOnlyFlag {
+		s := summarize(results)
+		_, err := fmt.Fprintf(w, "%d documents: %d pass, %d fail, %d error\n", s.Total, s.Pass, s.Fail, s.Error)
+		if err == nil && s.Warn > 0 {
+			_, err = fmt.Fprintf(w, "%d passed with warnings\n", s.Warn)
+		}
+		return err
+	}
+	if quietFlag > 0 || *summaryFlag == "none" {
+		return nil
+	}
+	failures := make([]string, 0)
+	errors := make([]string, 0)
+	warnings := make([]string, 0)
+	for _, r := range results {
+		switch r.Status {
+		case statusFail:
+			failures = append(failures, r.textLines(color))
+		case statusError:
+			errors = append(errors, r.textLines(color))
+		default:
+			if len(r.Warnings) > 0 {
+				warnings = append(warnings, r.textLines(color))
+			}
+		}
+	}
+	full := *summaryFlag == "full"
+	if len(failures) > 0 {
+		fmt.Fprintf(w, "%d of %d failed validation\n", len(failures), len(results))
+		if full {
+			fmt.Fprintln(w, strings.Join(failures, "\n"))
+		}
+	}
+	if len(errors) > 0 {
+		fmt.Fprintf(w, "%d of %d malformed documents\n", len(errors), len(results))
+		if full {
+			fmt.Fprintln(w, strings.Join(errors, "\n"))
+		}
+	}
+	if len(warnings) > 0 {
+		fmt.Fprintf(w, "%d of %d passed with warnings\n", len(warnings), len(results))
+		if full {
+			fmt.Fprintln(w, strings.Join(warnings, "\n"))
+		}
+	}
+	return nil
+}
+
+// writeKeywordSummary prints the number of failures for each schema keyword
+// along with the number of documents they occur in, most frequent first.
+func writeKeywordSummary(w io.Writer, results []result) {
+	type group struct {
+		keyword string
+		count   int
+		docs    int
+	}
+	groups := make(map[string]*group)
+	for _, r := range results {
+		seen := make(map[string]bool)
+		for _, f := range r.Failures {
+			kw := errorKeywords[f.Type]
+			if kw == "" {
+				kw = f.Type
+			}
+			g := groups[kw]
+			if g == nil {
+				g = &group{keyword: kw}
+				groups[kw] = g
+			}
+			g.count++
+			if !seen[kw] {
+				seen[kw] = true
+				g.docs++
+			}
+		}
+	}
+
+	sorted := make([]*group, 0, len(groups))
+	for _, g := range groups {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].keyword < sorted[j].keyword
+	})
+	for _, g := range sorted {
+		fmt.Fprintf(w, "%s: %d occurrences across %d files\n", g.keyword, g.count, g.docs)
+	}
+}
+
+// summary holds the aggregate counts over all validated documents
+type summary struct {
+	Total int `json:"total"`
+	Pass  int `json:"pass"`
+	Fail  int `json:"fail"`
+	Error int `json:"error"`
+
+	// Warn is the number of passing documents with warnings
+	Warn int `json:"warn,omitempty"`
+}
+
+func summarize(results []result) summary {
+	s := summary{Total: len(results)}
+	for _, r := range results {
+		switch r.Status {
+		case statusPass:
+			s.Pass++
+			if len(r.Warnings) > 0 {
+				s.Warn++
+			}
+		case statusFail:
+			s.Fail++
+		case statusError:
+			s.Error++
+		}
+	}
+	return s
+}
+
+// writeJSON renders all the results as a single JSON document. Documents
+// are omitted per the `-q` level and entirely for `-summary-only`.
+func writeJSON(w io.Writer, results []result) error {
+	report := struct {
+		Documents []result `json:"documents"`
+		Summary   summary  `json:"summary"`
+	}{
+		Documents: make([]result, 0, len(results)),
+		Summary:   summarize(results),
+	}
+	for _, r := range results {
+		if *summaryOnlyFlag || r.quiet() {
+			continue
+		}
+		report.Documents = append(report.Documents, r)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// JUnit XML elements, see https://llg.cubic.org/docs/junit/
+type (
+	junitTestSuites struct {
+		XMLName  xml.Name         `xml:"testsuites"`
+		Tests    int              `xml:"tests,attr"`
+		Failures int              `xml:"failures,attr"`
+		Errors   int              `xml:"errors,attr"`
+		Suites   []junitTestSuite `xml:"testsuite"`
+	}
+	junitTestSuite struct {
+		Name     string          `xml:"name,attr"`
+		Tests    int             `xml:"tests,attr"`
+		Failures int             `xml:"failures,attr"`
+		Errors   int             `xml:"errors,attr"`
+		Cases    []junitTestCase `xml:"testcase"`
+	}
+	junitTestCase struct {
+		Name      string        `xml:"name,attr"`
+		ClassName string        `xml:"classname,attr"`
+		Failure   *junitMessage `xml:"failure,omitempty"`
+		Error     *junitMessage `xml:"error,omitempty"`
+	}
+	junitMessage struct {
+		Message string `xml:"message,attr"`
+		Type    string `xml:"type,attr"`
+		Text    string `xml:",chardata"`
+	}
+)
+
+// writeJUnit renders the results as a JUnit XML report with a test case
+// per document.
+func writeJUnit(w io.Writer, results []result) error {
+	sum := summarize(results)
+	suite := junitTestSuite{
+		Name:     "yajsv",
+		Tests:    sum.Total,
+		Failures: sum.Fail,
+		Errors:   sum.Error,
+		Cases:    make([]junitTestCase, len(results)),
+	}
+	for i, r := range results {
+		tc := junitTestCase{Name: r.Path, ClassName: strings.Join(schemaFlags, " ")}
+		switch r.Status {
+		case statusFail:
+			tc.Failure = &junitMessage{
+				Message: fmt.Sprintf("%d validation failure(s)", len(r.Failures)),
+				Type:    statusFail,
+				Text:    strings.Join(r.lines(), "\n"),
+			}
+		case statusError:
+			tc.Error = &junitMessage{
+				Message: r.Error,
+				Type:    statusError,
+				Text:    strings.Join(r.lines(), "\n"),
+			}
+		}
+		suite.Cases[i] = tc
+	}
+	report := junitTestSuites{
+		Tests:    sum.Total,
+		Failures: sum.Fail,
+		Errors:   sum.Error,
+		Suites:   []junitTestSuite{suite},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// writeTAP renders the results as a TAP version 13 stream with a test point
+// per document. Failures and errors are attached as a YAML diagnostic block.
+func writeTAP(w io.Writer, results []result) error {
+	fmt.Fprintln(w, "TAP version 13")
+	fmt.Fprintf(w, "1..%d\n", len(results))
+	for i, r := range results {
+		if r.Status == statusPass {
+			fmt.Fprintf(w, "ok %d - %s\n", i+1, r.Path)
+			for _, f := range r.Warnings {
+				fmt.Fprintf(w, "# warning: %s\n", f.message)
+			}
+			continue
+		}
+		fmt.Fprintf(w, "not ok %d - %s\n", i+1, r.Path)
+		fmt.Fprintln(w, "  ---")
+		fmt.Fprintf(w, "  status: %s\n", r.Status)
+		if r.Status == statusError {
+			fmt.Fprintf(w, "  message: %q\n", r.Error)
+		}
+		if len(r.Failures) > 0 {
+			fmt.Fprintln(w, "  failures:")
+			for _, f := range r.Failures {
+				fmt.Fprintf(w, "    - %q\n", f.message)
+			}
+		}
+		fmt.Fprintln(w, "  ...")
+	}
+	return nil
+}
+
+// Checkstyle XML elements, see https://checkstyle.org/
+type (
+	checkstyleReport struct {
+		XMLName xml.Name         `xml:"checkstyle"`
+		Version string           `xml:"version,attr"`
+		Files   []checkstyleFile `xml:"file"`
+	}
+	checkstyleFile struct {
+		Name   string            `xml:"name,attr"`
+		Errors []checkstyleError `xml:"error"`
+	}
+	checkstyleError struct {
+		Line     int    `xml:"line,attr"`
+		Column   int    `xml:"column,attr,omitempty"`
+		Severity string `xml:"severity,attr"`
+		Message  string `xml:"message,attr"`
+		Source   string `xml:"source,attr"`
+	}
+)
+
+// writeCheckstyle renders the results as a checkstyle XML report, grouping
+// the failures and errors by document. Passing documents are included as
+// empty file elements unless omitted by `-q`.
+func writeCheckstyle(w io.Writer, results []result) error {
+	report := checkstyleReport{Version: "4.3"}
+	for _, r := range results {
+		if r.quiet() {
+			continue
+		}
+		file := checkstyleFile{Name: r.filePath()}
+		for _, f := range r.Failures {
+			file.Errors = append(file.Errors, checkstyleError{
+				Line:     f.Line,
+				Column:   f.Column,
+				Severity: "error",
+				Message:  f.message,
+				Source:   "yajsv.fail." + f.Type,
+			})
+		}
+		if r.Status == statusError {
+			file.Errors = append(file.Errors, checkstyleError{
+				Severity: "error",
+				Message:  r.Error,
+				Source:   "yajsv.error",
+			})
+		}
+		for _, f := range r.Warnings {
+			file.Errors = append(file.Errors, checkstyleError{
+				Line:     f.Line,
+				Column:   f.Column,
+				Severity: "warning",
+				Message:  f.message,
+				Source:   "yajsv.warning." + f.Type,
+			})
+		}
+		report.Files = append(report.Files, file)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// teamcityEscaper escapes values in TeamCity service messages, see
+// https://www.jetbrains.com/help/teamcity/service-messages.html#Escaped+Values
+var teamcityEscaper = strings.NewReplacer(
+	"|", "||",
+	"'", "|'",
+	"\n", "|n",
+	"\r", "|r",
+	"[", "|[",
+	"]", "|]",
+)
+
+// writeTeamCity renders the results as TeamCity service messages, reporting
+// each document as a test within a yajsv suite.
+func writeTeamCity(w io.Writer, results []result) error {
+	msg := func(format string, args ...string) {
+		escaped := make([]interface{}, len(args))
+		for i, a := range args {
+			escaped[i] = teamcityEscaper.Replace(a)
+		}
+		fmt.Fprintf(w, "##teamcity["+format+"]\n", escaped...)
+	}
+
+	msg("testSuiteStarted name='%s'", "yajsv")
+	for _, r := range results {
+		msg("testStarted name='%s'", r.Path)
+		switch r.Status {
+		case statusFail:
+			msg("testFailed name='%s' message='%s' details='%s'", r.Path,
+				fmt.Sprintf("%d validation failure(s)", len(r.Failures)),
+				strings.Join(r.lines(), "\n"))
+		case statusError:
+			msg("testFailed name='%s' message='%s' details='%s'", r.Path, r.Error,
+				strings.Join(r.lines(), "\n"))
+		}
+		msg("testFinished name='%s'", r.Path)
+	}
+	msg("testSuiteFinished name='%s'", "yajsv")
+	return nil
+}
+
+// errorLine extracts the line number from parse errors like `yaml: line 3: ...`
+var errorLine = regexp.MustCompile(`\bline (\d+)\b`)
+
+// writeQuickfix renders the failures and errors as `file:line:col: message`
+// lines, as understood by Vim's quickfix list and Emacs compilation-mode.
+// Locations default to the start of the document when unknown.
+func writeQuickfix(w io.Writer, results []result) error {
+	for _, r := range results {
+		for _, f := range r.Failures {
+			line, col := f.Line, f.Column
+			if line == 0 {
+				line, col = 1, 1
+			}
+			fmt.Fprintf(w, "%s:%d:%d: %s\n", r.filePath(), line, col, f.message)
+		}
+		if r.Status == statusError {
+			line := "1"
+			if r.line > 0 {
+				line = strconv.Itoa(r.line)
+			} else if m := errorLine.FindStringSubmatch(r.Error); m != nil {
+				line = m[1]
+			}
+			fmt.Fprintf(w, "%s:%s:1: error: %s\n", r.filePath(), line, r.Error)
+		}
+		for _, f := range r.Warnings {
+			line, col := f.Line, f.Column
+			if line == 0 {
+				line, col = 1, 1
+			}
+			fmt.Fprintf(w, "%s:%d:%d: warning: %s\n", r.filePath(), line, col, f.message)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{nil, "null (null)"},
+		{true, "true (boolean)"},
+		{json.Number("42"), "42 (integer)"},
+		{json.Number("4.2"), "4.2 (number)"},
+		{"abc", `"abc" (string)`},
+		{[]interface{}{json.Number("1")}, "[1] (array)"},
+		{map[string]interface{}{"a": "b"}, `{"a":"b"} (object)`},
+		{strings.Repeat("x", 100), `"` + strings.Repeat("x", 60) + `... (string)`},
+	}
+	for _, tt := range tests {
+		if got := snippet(tt.in); got != tt.want {
+			t.Errorf("snippet(%v): got %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	resetFlags()
+	defer resetFlags()
+
+	warn := failure{Type: "deprecated", Line: 2, Column: 3, message: "foo: deprecated"}
+	results := []result{
+		{Path: "a.json", Status: statusPass, Warnings: []failure{warn}},
+		{Path: "b.json", Status: statusFail, Failures: []failure{{message: "(root): bar is required"}}, Warnings: []failure{warn}},
+		{Path: "c.json", Status: statusPass},
+	}
+	var w strings.Builder
+	if err := writeText(&w, results); err != nil {
+		t.Fatal(err)
+	}
+	want := `a.json: pass with warnings
+a.json:2:3: warning: foo: deprecated
+b.json: fail: (root): bar is required
+b.json:2:3: warning: foo: deprecated
+c.json: pass
+1 of 3 failed validation
+1 of 3 passed with warnings
+`
+	if got := w.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	quietFlag = 1
+	if results[0].quiet() {
+		t.Errorf("pass with warnings hidden by -q")
+	}
+	quietFlag = 2
+	if !results[0].quiet() {
+		t.Errorf("pass with warnings shown by -qq")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Output units from the JSON Schema standardized output formats, see
+// https://json-schema.org/draft/2020-12/json-schema-core.html#section-12.4
+type outputUnit struct {
+	Valid                   bool          `json:"valid"`
+	KeywordLocation         *string       `json:"keywordLocation,omitempty"`
+	AbsoluteKeywordLocation string        `json:"absoluteKeywordLocation,omitempty"`
+	InstanceLocation        *string       `json:"instanceLocation,omitempty"`
+	Error                   string        `json:"error,omitempty"`
+	Errors                  []*outputUnit `json:"errors,omitempty"`
+}
+
+// outputUnits maps the `-output-unit` formats to their builders
+var outputUnits = map[string]func(result) *outputUnit{
+	"flag":     flagUnit,
+	"basic":    basicUnit,
+	"detailed": func(r result) *outputUnit { return hierarchicalUnit(r, true) },
+	"verbose":  func(r result) *outputUnit { return hierarchicalUnit(r, false) },
+}
+
+func flagUnit(r result) *outputUnit {
+	return &outputUnit{Valid: r.Status == statusPass}
+}
+
+// basicUnit is a flat list of the failing keywords.
+func basicUnit(r result) *outputUnit {
+	u := flagUnit(r)
+	for _, f := range r.Failures {
+		u.Errors = append(u.Errors, failureUnit(f))
+	}
+	return u
+}
+
+// hierarchicalUnit nests the failing keywords beneath units for each of
+// the schema locations leading to them. The detailed format condenses
+// locations with a single child into that child.
+//
+// Note that gojsonschema only reports failures, so unlike other validators
+// the verbose format doesn't include the passing keywords.
+func hierarchicalUnit(r result, condense bool) *outputUnit {
+	root := flagUnit(r)
+	if r.Status == statusPass {
+		return root
+	}
+	root.KeywordLocation, root.InstanceLocation = new(string), new(string)
+
+	// Index units by schema location, creating the parent units as needed
+	units := map[string]*outputUnit{"": root}
+	var parent func(loc, inst string) *outputUnit
+	parent = func(loc, inst string) *outputUnit {
+		if u, ok := units[loc]; ok {
+			return u
+		}
+		u := &outputUnit{KeywordLocation: &loc, InstanceLocation: &inst}
+		units[loc] = u
+		i := strings.LastIndex(loc, "/")
+		pinst := inst
+		if j := strings.LastIndex(inst, "/"); j >= 0 && appliesToChild(loc) {
+			pinst = inst[:j]
+		}
+		p := parent(loc[:i], pinst)
+		p.Errors = append(p.Errors, u)
+		return u
+	}
+	for _, f := range r.Failures {
+		u := failureUnit(f)
+		loc := f.keywordLocation
+		i := strings.LastIndex(loc, "/")
+		if i < 0 {
+			root.Errors = append(root.Errors, u)
+			continue
+		}
+		p := parent(loc[:i], f.instanceLocation)
+		p.Errors = append(p.Errors, u)
+	}
+
+	if condense {
+		condenseUnit(root)
+	}
+	return root
+}
+
+// appliesToChild reports if the subschema at loc is applied to a child of
+// the instance its parent schema is applied to, e.g. `/properties/foo`.
+func appliesToChild(loc string) bool {
+	i := strings.LastIndex(loc, "/")
+	last, parent := loc[i+1:], loc[:i]
+	switch last {
+	case "items", "additionalItems", "additionalProperties":
+		return true
+	}
+	if strings.HasSuffix(parent, "/properties") || strings.HasSuffix(parent, "/patternProperties") {
+		return true
+	}
+	_, err := strconv.Atoi(last)
+	return err == nil && strings.HasSuffix(parent, "/items")
+}
+
+func condenseUnit(u *outputUnit) {
+	for i, c := range u.Errors {
+		for len(c.Errors) == 1 {
+			c = c.Errors[0]
+		}
+		u.Errors[i] = c
+		condenseUnit(c)
+	}
+}
+
+func failureUnit(f failure) *outputUnit {
+	kw, inst := f.keywordLocation, f.instanceLocation
+	return &outputUnit{
+		KeywordLocation:         &kw,
+		AbsoluteKeywordLocation: f.absoluteKeywordLocation,
+		InstanceLocation:        &inst,
+		Error:                   f.Description,
+	}
+}
+
+// keywordLocation approximates the schema location of keyword that failed
+// for the instance at ptr by walking the schema at the root pointer of doc
+// along the instance path.
+// Both the dynamic path, including any `$ref`s, and the absolute location
+// within the schema document are returned. gojsonschema doesn't expose
+// evaluation paths so only local `$ref`s and the properties,
+// patternProperties, additionalProperties and items applicators are
+// followed, e.g. not the individual anyOf branches.
+func keywordLocation(doc interface{}, root, ptr, keyword string) (string, string) {
+	schema, _ := resolvePointer(doc, root)
+	loc, abs := "", root
+	step := func(seg string, sub interface{}) {
+		loc += seg
+		abs += seg
+		schema = sub
+	}
+	deref := func() {
+		for i := 0; i < 32; i++ { // bound $ref cycles
+			m, _ := schema.(map[string]interface{})
+			ref, _ := m["$ref"].(string)
+			if !strings.HasPrefix(ref, "#") {
+				return
+			}
+			target, ok := resolvePointer(doc, ref[1:])
+			if !ok {
+				return
+			}
+			step("/$ref", target)
+			abs = ref[1:]
+		}
+	}
+
+	tokens := strings.Split(ptr, "/")[1:]
+walk:
+	for _, tok := range tokens {
+		deref()
+		m, _ := schema.(map[string]interface{})
+		if m == nil {
+			break
+		}
+		name := unescapePointer(tok)
+		if props, ok := m["properties"].(map[string]interface{}); ok {
+			if s, ok := props[name]; ok {
+				step("/properties/"+tok, s)
+				continue
+			}
+		}
+		if props, ok := m["patternProperties"].(map[string]interface{}); ok {
+			for pattern, s := range props {
+				if re, err := regexp.Compile(pattern); err == nil && re.MatchString(name) {
+					step("/patternProperties/"+pointerEscaper.Replace(pattern), s)
+					continue walk
+				}
+			}
+		}
+		if s, ok := m["additionalProperties"].(map[string]interface{}); ok {
+			step("/additionalProperties", s)
+			continue
+		}
+		idx, err := strconv.Atoi(name)
+		if err != nil {
+			break
+		}
+		switch items := m["items"].(type) {
+		case map[string]interface{}:
+			step("/items", items)
+			continue
+		case []interface{}:
+			if idx < len(items) {
+				step(fmt.Sprintf("/items/%d", idx), items[idx])
+				continue
+			}
+			if s, ok := m["additionalItems"].(map[string]interface{}); ok {
+				step("/additionalItems", s)
+				continue
+			}
+		}
+		break
+	}
+
+	deref()
+	if keyword != "" {
+		step("/"+keyword, schema)
+	}
+	return loc, abs
+}
+
+// resolvePointer returns the value at the JSON pointer ptr within doc.
+func resolvePointer(doc interface{}, ptr string) (interface{}, bool) {
+	if ptr == "" {
+		return doc, true
+	}
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, false
+	}
+	for _, tok := range strings.Split(ptr[1:], "/") {
+		tok = unescapePointer(tok)
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if doc, ok = v[tok]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(tok)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			doc = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// pointerUnescaper reverses pointerEscaper
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+func unescapePointer(tok string) string {
+	return pointerUnescaper.Replace(tok)
+}
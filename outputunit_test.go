@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestKeywordLocation(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/output-unit/schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema interface{}
+	if err := json.Unmarshal(buf, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		root, ptr, keyword, want, abs string
+	}{
+		{"", "", "required", "/required", "/required"},
+		{"", "/name", "minLength", "/properties/name/$ref/minLength", "/definitions/name/minLength"},
+		{"", "/tags/1", "type", "/properties/tags/items/type", "/properties/tags/items/type"},
+		{"", "/unknown/0", "type", "/type", "/type"},
+		{"", "", "", "", ""},
+		{"/properties/tags", "/1", "type", "/items/type", "/properties/tags/items/type"},
+		{"/properties/name", "", "minLength", "/$ref/minLength", "/definitions/name/minLength"},
+	}
+	for _, tt := range tests {
+		got, abs := keywordLocation(schema, tt.root, tt.ptr, tt.keyword)
+		if got != tt.want || abs != tt.abs {
+			t.Errorf("keywordLocation(%q, %q, %q): got %q, %q, want %q, %q", tt.root, tt.ptr, tt.keyword, got, abs, tt.want, tt.abs)
+		}
+	}
+}
+
+func TestHierarchicalUnit(t *testing.T) {
+	r := result{Status: statusFail, Failures: []failure{
+		{Description: "a", instanceLocation: "/a", keywordLocation: "/properties/a/type"},
+		{Description: "b", instanceLocation: "/a", keywordLocation: "/properties/a/minLength"},
+		{Description: "c", instanceLocation: "/b/0", keywordLocation: "/properties/b/items/type"},
+	}}
+	str := func(s string) *string { return &s }
+	leaf := func(kw, inst, err string) *outputUnit {
+		return &outputUnit{KeywordLocation: str(kw), InstanceLocation: str(inst), Error: err}
+	}
+
+	want := &outputUnit{KeywordLocation: str(""), InstanceLocation: str(""), Errors: []*outputUnit{
+		{KeywordLocation: str("/properties"), InstanceLocation: str(""), Errors: []*outputUnit{
+			{KeywordLocation: str("/properties/a"), InstanceLocation: str("/a"), Errors: []*outputUnit{
+				leaf("/properties/a/type", "/a", "a"),
+				leaf("/properties/a/minLength", "/a", "b"),
+			}},
+			{KeywordLocation: str("/properties/b"), InstanceLocation: str("/b"), Errors: []*outputUnit{
+				{KeywordLocation: str("/properties/b/items"), InstanceLocation: str("/b/0"), Errors: []*outputUnit{
+					leaf("/properties/b/items/type", "/b/0", "c"),
+				}},
+			}},
+		}},
+	}}
+	if got := hierarchicalUnit(r, false); !reflect.DeepEqual(got, want) {
+		g, _ := json.MarshalIndent(got, "", "  ")
+		t.Errorf("verbose: got\n%s", g)
+	}
+
+	// Single child chains are collapsed in the detailed format
+	want.Errors[0].Errors[1] = leaf("/properties/b/items/type", "/b/0", "c")
+	if got := hierarchicalUnit(r, true); !reflect.DeepEqual(got, want) {
+		g, _ := json.MarshalIndent(got, "", "  ")
+		t.Errorf("detailed: got\n%s", g)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/schema"
+)
+
+// parquetBatchSize is the number of rows decoded from Parquet files at once
+const parquetBatchSize = 1024
+
+// validateParquet validates each row of the Parquet file src as an object
+// keyed by the column names, up to `-limit` rows when set. Like Avro, rows
+// are reported by index, e.g. `data.parquet[row3]`, unless there is only one.
+func validateParquet(schema schemaSet, path string, src []byte) []result {
+	pr, err := reader.NewParquetReader(buffer.NewBufferFileFromBytes(src), nil, 1)
+	if err != nil {
+		return []result{{Path: path, Status: statusError, Error: fmt.Sprintf("load doc: %s", err)}}
+	}
+	defer pr.ReadStop()
+
+	columns := parquetColumns(pr.SchemaHandler)
+	rows := int(pr.GetNumRows())
+	if *limitFlag > 0 && rows > *limitFlag {
+		rows = *limitFlag
+	}
+
+	var results []result
+	for i := 0; i < rows; {
+		start := time.Now()
+		n := rows - i
+		if n > parquetBatchSize {
+			n = parquetBatchSize
+		}
+		batch, err := pr.ReadByNumber(n)
+		if err != nil {
+			results = append(results, result{Path: path, Status: statusError, Error: fmt.Sprintf("load doc: %s", err)})
+			break
+		}
+		for _, row := range batch {
+			i++
+			name := fmt.Sprintf("%s[row%d]", path, i)
+			buf, err := json.Marshal(parquetValue(reflect.ValueOf(row), columns))
+			if err != nil {
+				results = append(results, result{Path: name, Status: statusError, Error: fmt.Sprintf("load doc: %s", err), file: path})
+				continue
+			}
+			r := validateJSON(schema, name, buf, len(buf), start, func() map[string]position { return nil })
+			r.file = path
+			results = append(results, r)
+			start = time.Now()
+		}
+		if len(batch) < n {
+			break
+		}
+	}
+
+	if len(results) == 1 && results[0].file != "" {
+		results[0].Path, results[0].file = path, ""
+	}
+	return results
+}
+
+// parquetColumn is the original name and schema of a Parquet field, keyed
+// by the exported Go field name used for it when reading rows.
+type parquetColumn struct {
+	name    string
+	element *parquet.SchemaElement
+}
+
+func parquetColumns(sh *schema.SchemaHandler) map[string]parquetColumn {
+	columns := make(map[string]parquetColumn)
+	for i, info := range sh.Infos {
+		columns[info.InName] = parquetColumn{info.ExName, sh.SchemaElements[i]}
+	}
+	return columns
+}
+
+// parquetValue converts a row, or part of a row, read from a Parquet file to
+// a JSON value. Dates and timestamps are converted to strings matching the
+// `date` and `date-time` formats and decimals are scaled to exact numbers.
+func parquetValue(v reflect.Value, columns map[string]parquetColumn) interface{} {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return parquetValue(v.Elem(), columns)
+	case reflect.Struct:
+		obj := make(map[string]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i).Name
+			col, ok := columns[field]
+			if !ok {
+				col.name = field
+			}
+			obj[col.name] = parquetLogical(parquetValue(v.Field(i), columns), col.element)
+		}
+		return obj
+	case reflect.Slice:
+		arr := make([]interface{}, v.Len())
+		for i := range arr {
+			arr[i] = parquetValue(v.Index(i), columns)
+		}
+		return arr
+	case reflect.Map:
+		obj := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			obj[fmt.Sprint(parquetValue(iter.Key(), columns))] = parquetValue(iter.Value(), columns)
+		}
+		return obj
+	}
+	return v.Interface()
+}
+
+// parquetLogical converts the primitive value v of a leaf column to its
+// logical type, e.g. the days since the epoch of a DATE column to a date.
+func parquetLogical(v interface{}, elem *parquet.SchemaElement) interface{} {
+	if elem == nil || elem.ConvertedType == nil {
+		return v
+	}
+	switch *elem.ConvertedType {
+	case parquet.ConvertedType_DATE, parquet.ConvertedType_TIMESTAMP_MILLIS, parquet.ConvertedType_TIMESTAMP_MICROS, parquet.ConvertedType_DECIMAL:
+	default:
+		return v
+	}
+
+	var n *big.Int
+	switch v := v.(type) {
+	case int32:
+		n = big.NewInt(int64(v))
+	case int64:
+		n = big.NewInt(v)
+	case string:
+		// Big-endian two's complement for BYTE_ARRAY and FIXED_LEN_BYTE_ARRAY
+		n = new(big.Int).SetBytes([]byte(v))
+		if len(v) > 0 && v[0]&0x80 != 0 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(v)*8)))
+		}
+	default:
+		return v
+	}
+
+	switch *elem.ConvertedType {
+	case parquet.ConvertedType_DATE:
+		return time.Unix(n.Int64()*24*60*60, 0).UTC().Format("2006-01-02")
+	case parquet.ConvertedType_TIMESTAMP_MILLIS:
+		return time.UnixMilli(n.Int64()).UTC().Format(time.RFC3339Nano)
+	case parquet.ConvertedType_TIMESTAMP_MICROS:
+		return time.UnixMicro(n.Int64()).UTC().Format(time.RFC3339Nano)
+	}
+	scale := int(elem.GetScale())
+	r := new(big.Rat).SetFrac(n, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil))
+	return json.Number(r.FloatString(scale))
+}
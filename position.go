@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// position is a 1-based line and column (in characters) within a document
+type position struct {
+	Line, Column int
+}
+
+// pointerEscaper escapes reference tokens per RFC 6901
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// contextPointer converts a gojsonschema context, e.g. `(root).foo.0`, to
+// the equivalent JSON pointer, e.g. `/foo/0`.
+func contextPointer(ctx *gojsonschema.JsonContext) string {
+	// Use a delimiter that can't be confused with characters in keys
+	tokens := strings.Split(ctx.String("\x00"), "\x00")[1:]
+	var sb strings.Builder
+	for _, t := range tokens {
+		sb.WriteByte('/')
+		sb.WriteString(pointerEscaper.Replace(t))
+	}
+	return sb.String()
+}
+
+// yamlPositions returns the source position of every value in the YAML
+// text buf keyed by JSON pointer. Returns nil if buf isn't valid YAML.
+func yamlPositions(buf []byte) map[string]position {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return nil
+	}
+	if err := yamlAliases(&doc); err != nil {
+		return nil
+	}
+	pos := make(map[string]position)
+	yamlNodePositions(&doc, "", pos)
+	return pos
+}
+
+func yamlNodePositions(n *yaml.Node, ptr string, pos map[string]position) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) > 0 {
+			yamlNodePositions(n.Content[0], ptr, pos)
+		}
+		return
+	case yaml.AliasNode:
+		// The values within an alias are at their anchor, the alias itself
+		// is positioned where it's used
+		yamlNodePositions(n.Alias, ptr, pos)
+	case yaml.MappingNode:
+		yamlMappingPositions(n, ptr, pos, nil)
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			yamlNodePositions(c, fmt.Sprintf("%s/%d", ptr, i), pos)
+		}
+	}
+	pos[ptr] = position{n.Line, n.Column}
+}
+
+// yamlMappingPositions adds the positions of the entries of the mapping n,
+// along with those of its `<<` merge keys at the position they're merged
+// from, which never override explicit entries. Entries already positioned
+// are kept when merging into the mapping at ptr, i.e. seen isn't nil.
+func yamlMappingPositions(n *yaml.Node, ptr string, pos map[string]position, seen map[string]bool) {
+	merged := seen != nil
+	if !merged {
+		seen = make(map[string]bool)
+	}
+	var merges []*yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key := n.Content[i]
+		if key.Tag == "!!merge" {
+			merges = append(merges, n.Content[i+1])
+			continue
+		}
+		if key.Kind == yaml.AliasNode {
+			key = key.Alias
+		}
+		if seen[key.Value] && merged {
+			continue
+		}
+		seen[key.Value] = true
+		yamlNodePositions(n.Content[i+1], ptr+"/"+pointerEscaper.Replace(key.Value), pos)
+	}
+	for _, m := range merges {
+		for _, src := range yamlMergeSources(m) {
+			yamlMappingPositions(src, ptr, pos, seen)
+		}
+	}
+}
+
+// jsonPositions returns the source position of every value in the JSON
+// text buf keyed by JSON pointer. Malformed JSON yields partial results.
+func jsonPositions(buf []byte) map[string]position {
+	return scanJSON(buf).pos
+}
+
+// jsonDuplicateKeys returns the members of objects in the JSON text buf
+// that repeat an earlier key, which encoding/json silently overwrites.
+func jsonDuplicateKeys(buf []byte) []duplicateKey {
+	return scanJSON(buf).dups
+}
+
+// scanJSON runs a jsonScanner over the JSON text buf.
+func scanJSON(buf []byte) *jsonScanner {
+	s := &jsonScanner{buf: buf, pos: make(map[string]position)}
+	s.lines = append(s.lines, 0)
+	for i, b := range buf {
+		if b == '\n' {
+			s.lines = append(s.lines, i+1)
+		}
+	}
+	s.value("")
+	return s
+}
+
+// duplicateKey is an object member repeating a key of an earlier member
+type duplicateKey struct {
+	object string   // JSON pointer to the object
+	key    string   // the repeated key
+	pos    position // of the repeated key
+}
+
+// jsonScanner is a minimal JSON parser that only tracks the offsets of
+// values and duplicate keys, relying on encoding/json for the decoding.
+type jsonScanner struct {
+	buf   []byte
+	i     int
+	lines []int // offsets of the start of each line
+	pos   map[string]position
+	dups  []duplicateKey
+}
+
+func (s *jsonScanner) position(offset int) position {
+	line := sort.Search(len(s.lines), func(i int) bool { return s.lines[i] > offset }) - 1
+	col := utf8.RuneCount(s.buf[s.lines[line]:offset]) + 1
+	return position{line + 1, col}
+}
+
+func (s *jsonScanner) skipSpace() {
+	for s.i < len(s.buf) && strings.IndexByte(" \t\r\n", s.buf[s.i]) >= 0 {
+		s.i++
+	}
+}
+
+// peek skips whitespace and returns the next byte or 0 at EOF
+func (s *jsonScanner) peek() byte {
+	s.skipSpace()
+	if s.i >= len(s.buf) {
+		return 0
+	}
+	return s.buf[s.i]
+}
+
+func (s *jsonScanner) value(ptr string) bool {
+	c := s.peek()
+	if c == 0 {
+		return false
+	}
+	s.pos[ptr] = s.position(s.i)
+
+	switch c {
+	case '{':
+		s.i++
+		seen := make(map[string]bool)
+		for {
+			if s.peek() == '}' {
+				s.i++
+				return true
+			}
+			start := s.i
+			if !s.string() {
+				return false
+			}
+			var key string
+			if err := json.Unmarshal(s.buf[start:s.i], &key); err != nil {
+				return false
+			}
+			if seen[key] {
+				s.dups = append(s.dups, duplicateKey{ptr, key, s.position(start)})
+			}
+			seen[key] = true
+			if s.peek() != ':' {
+				return false
+			}
+			s.i++
+			if !s.value(ptr + "/" + pointerEscaper.Replace(key)) {
+				return false
+			}
+			if s.peek() == ',' {
+				s.i++
+			}
+		}
+	case '[':
+		s.i++
+		for n := 0; ; n++ {
+			if s.peek() == ']' {
+				s.i++
+				return true
+			}
+			if !s.value(fmt.Sprintf("%s/%d", ptr, n)) {
+				return false
+			}
+			if s.peek() == ',' {
+				s.i++
+			}
+		}
+	case '"':
+		return s.string()
+	}
+
+	// Numbers and literals
+	start := s.i
+	for s.i < len(s.buf) && strings.IndexByte(" \t\r\n,:]}", s.buf[s.i]) < 0 {
+		s.i++
+	}
+	return s.i > start
+}
+
+func (s *jsonScanner) string() bool {
+	if s.i >= len(s.buf) || s.buf[s.i] != '"' {
+		return false
+	}
+	for s.i++; s.i < len(s.buf); s.i++ {
+		switch s.buf[s.i] {
+		case '\\':
+			s.i++
+		case '"':
+			s.i++
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJSONPositions(t *testing.T) {
+	src := `{
+  "a": [1, {"b/c": true}],
+  "ü": "x", "d~": null
+}`
+	want := map[string]position{
+		"":          {1, 1},
+		"/a":        {2, 8},
+		"/a/0":      {2, 9},
+		"/a/1":      {2, 12},
+		"/a/1/b~1c": {2, 20},
+		"/ü":        {3, 8},
+		"/d~0":      {3, 19},
+	}
+	got := jsonPositions([]byte(src))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+
+	// Malformed input shouldn't loop forever
+	jsonPositions([]byte(`[1, }`))
+	jsonPositions([]byte(`{"a" 1}`))
+}
+
+func TestJSONDuplicateKeys(t *testing.T) {
+	src := `{
+  "a": {"b": 1, "c": 2, "b": 3},
+  "d": [{"a": 1}, {"a": 2}],
+  "a": null
+}`
+	want := []duplicateKey{
+		{"/a", "b", position{2, 25}},
+		{"", "a", position{4, 3}},
+	}
+	got := jsonDuplicateKeys([]byte(src))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
+
+func TestYAMLPositions(t *testing.T) {
+	src := `---
+a:
+  - 1
+  - b/c: true
+d: &anchor x
+e: *anchor
+f: &map
+  g: 1
+h: *map
+i:
+  <<: *map
+  g: 2
+  j: 3
+`
+	want := map[string]position{
+		"":          {2, 1},
+		"/a":        {3, 3},
+		"/a/0":      {3, 5},
+		"/a/1":      {4, 5},
+		"/a/1/b~1c": {4, 10},
+		"/d":        {5, 4},
+		"/e":        {6, 4},
+		"/f":        {7, 4},
+		"/f/g":      {8, 6},
+		"/h":        {9, 4},
+		"/h/g":      {8, 6},
+		"/i":        {11, 3},
+		"/i/g":      {12, 6},
+		"/i/j":      {13, 6},
+	}
+	got := yamlPositions([]byte(src))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+	if got := yamlPositions([]byte(`a: 'unterminated`)); got != nil {
+		t.Errorf("malformed: got %v", got)
+	}
+	if got := yamlPositions([]byte("a: &a\n  b: *a\n")); got != nil {
+		t.Errorf("recursive alias: got %v", got)
+	}
+}
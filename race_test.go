@@ -0,0 +1,7 @@
+//go:build race
+
+package main
+
+// raceEnabled is whether the tests are built with -race, which plugins they
+// build must match to load
+const raceEnabled = true
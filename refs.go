@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// schemaSource is a loaded schema document and the path it was loaded from
+type schemaSource struct {
+	path     string
+	doc      interface{}
+	base     *url.URL // retrieval URI the schema is registered under, if any
+	fragment string   // JSON pointer of the root subschema, if not the doc
+
+	// refs are the schemas the document was fetched along with, e.g. the
+	// references of a schema registry subject
+	refs []schemaSource
+
+	// sum is the sha256 of the local file the document was read from with
+	// `-schema-cache`, which checks it's unchanged before reusing the source
+	sum string
+}
+
+// baseURI returns the initial base URI for resolving refs in the source
+func (src schemaSource) baseURI() *url.URL {
+	if src.base == nil {
+		return &url.URL{}
+	}
+	return src.base
+}
+
+// debugRefs prints where every `$id` and `$ref` in the sources resolves to
+// mirroring how gojsonschema registers and looks up schemas. Since `-r`
+// schemas are only registered by the `$id`s they declare, refs to other
+// documents that aren't covered by those show up as unresolved, except for
+// HTTP(S) URLs which are fetched while compiling and show up as remote.
+func debugRefs(w io.Writer, sources []schemaSource) {
+	resources := indexResources(sources, func(src schemaSource, ptr, id string, base *url.URL) {
+		fmt.Fprintf(w, "debug-refs: %s#%s: $id %q -> %s\n", src.path, ptr, id, base)
+	})
+
+	for _, src := range sources {
+		walkSchema(src.doc, src.baseURI(), func(ptr string, base *url.URL, schema map[string]interface{}) {
+			ref, ok := schema["$ref"].(string)
+			if !ok {
+				return
+			}
+			u, err := url.Parse(ref)
+			if err != nil {
+				fmt.Fprintf(w, "debug-refs: %s#%s: $ref %q -> invalid: %s\n", src.path, ptr, ref, err)
+				return
+			}
+			target := base.ResolveReference(u)
+			fmt.Fprintf(w, "debug-refs: %s#%s: $ref %q -> %s (%s)\n", src.path, ptr, ref, target, describeRef(resources, target))
+		})
+	}
+}
+
+// indexResources indexes every schema resource in the sources by its
+// absolute URI, sans fragment, calling fn for each `$id` if not nil.
+func indexResources(sources []schemaSource, fn func(src schemaSource, ptr, id string, base *url.URL)) map[string]schemaSource {
+	resources := make(map[string]schemaSource)
+	for _, src := range sources {
+		if src.base != nil {
+			resources[src.base.String()] = src
+		}
+		walkSchema(src.doc, src.baseURI(), func(ptr string, base *url.URL, schema map[string]interface{}) {
+			id := schemaID(schema)
+			if id == "" {
+				return
+			}
+			if fn != nil {
+				fn(src, ptr, id, base)
+			}
+			if strings.HasPrefix(id, "#") {
+				return // location-independent identifier, not a new resource
+			}
+			resources[stripFragment(base)] = schemaSource{path: src.path + "#" + ptr, doc: schema, base: base}
+		})
+	}
+	return resources
+}
+
+// describeRef reports the source of the schema that target resolves to.
+func describeRef(resources map[string]schemaSource, target *url.URL) string {
+	res, found, ok := lookupRef(resources, target)
+	switch {
+	case !found && isURL(target.String()):
+		return "remote"
+	case !found:
+		return "unresolved"
+	case !ok && strings.HasPrefix(target.Fragment, "/"):
+		return res.path + ", pointer not found"
+	case !ok:
+		return res.path + ", anchor not found"
+	}
+	return res.path
+}
+
+// lookupRef returns the schema resource that target resolves to, if found,
+// and whether its fragment, a JSON pointer or anchor name, exists within it.
+func lookupRef(resources map[string]schemaSource, target *url.URL) (schemaSource, bool, bool) {
+	res, ok := resources[stripFragment(target)]
+	if !ok {
+		return res, false, false
+	}
+	frag := target.Fragment
+	if frag == "" {
+		return res, true, true
+	}
+	if strings.HasPrefix(frag, "/") {
+		_, ok := resolvePointer(res.doc, frag)
+		return res, true, ok
+	}
+	found := false
+	uri := stripFragment(res.baseURI())
+	walkSchema(res.doc, res.baseURI(), func(ptr string, base *url.URL, schema map[string]interface{}) {
+		if stripFragment(base) != uri {
+			return // anchor of an embedded resource
+		}
+		if schema["$anchor"] == frag || schema["$dynamicAnchor"] == frag || schemaID(schema) == "#"+frag {
+			found = true
+		}
+	})
+	return res, true, found
+}
+
+func stripFragment(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	c := *u
+	c.Fragment = ""
+	c.RawFragment = ""
+	return c.String()
+}
+
+// loadRelativeRefs loads the local schema files that the relative refs of
+// the sources point to, along with those of the files they point to in turn,
+// for a primary schema read from the file at uri. Without a base URI, the
+// refs of the schema resolve to rooted paths like `/common/address.json`,
+// clamping any `..` at the root, so they're resolved against uri instead and
+// rewritten to the `file://` URIs the files are registered under. Refs
+// covered by the sources, or to files that don't exist, are left for
+// gojsonschema to report.
+func loadRelativeRefs(sources []schemaSource, uri *url.URL) ([]schemaSource, error) {
+	resources := indexResources(sources, nil)
+	queue := append([]schemaSource(nil), sources...)
+	var err error
+	for i := 0; i < len(queue) && err == nil; i++ {
+		src := queue[i]
+		walkSchema(src.doc, src.baseURI(), func(ptr string, base *url.URL, schema map[string]interface{}) {
+			ref, ok := schema["$ref"].(string)
+			if !ok || err != nil {
+				return
+			}
+			u, perr := url.Parse(ref)
+			if perr != nil {
+				return
+			}
+			if _, found, _ := lookupRef(resources, base.ResolveReference(u)); found {
+				return
+			}
+			target, path, ok := localRefFile(uri, base, u)
+			if !ok {
+				return
+			}
+			if base.Scheme == "" {
+				schema["$ref"] = target.String()
+			}
+			if _, found, _ := lookupRef(resources, target); found {
+				return
+			}
+			loader, lerr := jsonLoader(path)
+			if lerr == nil {
+				var doc interface{}
+				if doc, lerr = loader.LoadJSON(); lerr == nil {
+					res := schemaSource{path: path, doc: doc, base: &url.URL{Scheme: "file", Path: target.Path}, sum: fileSum(loader)}
+					resources[stripFragment(res.base)] = res
+					queue = append(queue, res)
+					return
+				}
+			}
+			err = invalidSchemaError{src.path, fmt.Errorf("unable to load $ref %s: %s", ref, lerr)}
+		})
+	}
+	return queue[len(sources):], err
+}
+
+// localRefFile returns the `file://` URI and path of the existing local file
+// that the ref u points to, where base is the URI it resolves against and
+// uri that of the file the schema was read from. Refs resolving against an
+// empty base are relative to uri, e.g. `../shared/a.json` or, within a
+// relative `$id` like `sub/`, `sub/b.json`.
+func localRefFile(uri, base, u *url.URL) (*url.URL, string, bool) {
+	target := base.ResolveReference(u)
+	switch {
+	case target.Host != "" || target.Opaque != "" || target.Path == "":
+		return nil, "", false
+	case target.Scheme == "file":
+	case target.Scheme != "":
+		return nil, "", false
+	case stripFragment(base) == "":
+		target = uri.ResolveReference(u)
+	default:
+		target = uri.ResolveReference(&url.URL{Path: "./" + strings.TrimPrefix(target.Path, "/"), Fragment: target.Fragment})
+	}
+	path := uriPath(target)
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return nil, "", false
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+	}
+	return target, path, true
+}
+
+// fileURI returns the absolute `file://` URI of the local path
+func fileURI(path string) (*url.URL, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	slashed := filepath.ToSlash(abs)
+	if !strings.HasPrefix(slashed, "/") {
+		slashed = "/" + slashed // e.g. /C:/schemas on Windows
+	}
+	return &url.URL{Scheme: "file", Path: slashed}, nil
+}
+
+// uriPath returns the local path of the `file://` URI u
+func uriPath(u *url.URL) string {
+	path := u.Path
+	if len(path) > 2 && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDebugRefs(t *testing.T) {
+	var sources []schemaSource
+	for _, p := range []string{"testdata/refs/defs.json", "testdata/refs/schema.json"} {
+		loader, err := jsonLoader(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := loader.LoadJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources = append(sources, schemaSource{path: p, doc: doc})
+	}
+	// Primary schema is registered under the empty URI
+	sources[1].base = &url.URL{}
+
+	var w strings.Builder
+	debugRefs(&w, sources)
+	want := `debug-refs: testdata/refs/defs.json#: $id "defs.json" -> /defs.json
+debug-refs: testdata/refs/schema.json#/properties/age: $ref "defs.json#/definitions/missing" -> /defs.json#/definitions/missing (testdata/refs/defs.json#, pointer not found)
+debug-refs: testdata/refs/schema.json#/properties/name: $ref "defs.json#/definitions/name" -> /defs.json#/definitions/name (testdata/refs/defs.json#)
+debug-refs: testdata/refs/schema.json#/properties/other: $ref "other.json" -> /other.json (unresolved)
+debug-refs: testdata/refs/schema.json#/properties/self: $ref "#/properties/name" -> #/properties/name (testdata/refs/schema.json)
+`
+	if got := w.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dlclark/regexp2"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// ecmaTimeout bounds the backtracking of an ECMA-262 regex match
+const ecmaTimeout = time.Second
+
+// useRegexEngine selects the engine for `pattern` keywords and `regex`
+// formats, "re2" for Go's, which lacks lookarounds and backreferences, or
+// "ecma" for the ECMA-262 dialect of the spec. Under ecma, patterns are
+// checked as custom keywords, see bindKeywords. Schemas with
+// `patternProperties` are rejected, see ecmaPatternProperties.
+func useRegexEngine(engine string) {
+	if engine == "ecma" {
+		if customKeywords == nil {
+			customKeywords = make(map[string]string)
+		}
+		customKeywords["pattern"] = "ecmaPattern"
+		assertedFormats["regex"] = formatFunc(isECMARegex)
+	} else {
+		assertedFormats["regex"] = gojsonschema.RegexFormatChecker{}
+	}
+}
+
+// compileECMA compiles an ECMA-262 regular expression
+func compileECMA(pattern string) (*regexp2.Regexp, error) {
+	re, err := regexp2.Compile(pattern, regexp2.ECMAScript)
+	if err != nil {
+		return nil, err
+	}
+	re.MatchTimeout = ecmaTimeout
+	return re, nil
+}
+
+// isECMARegex is the `regex` format checker of the ecma engine
+func isECMARegex(input interface{}) bool {
+	s, ok := input.(string)
+	if !ok {
+		return true
+	}
+	_, err := compileECMA(s)
+	return err == nil
+}
+
+// ecmaPatternTemplate matches strings anywhere against an ECMA-262 regex
+// argument, as for `pattern`. Matches that time out fail.
+func ecmaPatternTemplate(arg interface{}) (func(input interface{}) bool, error) {
+	pattern, ok := arg.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected a string")
+	}
+	re, err := compileECMA(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return func(input interface{}) bool {
+		s, ok := input.(string)
+		if !ok {
+			return true
+		}
+		matched, err := re.MatchString(s)
+		return err == nil && matched
+	}, nil
+}
+
+// ecmaPatternProperties returns an error for each `patternProperties` in
+// doc, rather than letting its keys silently match with RE2, which differs
+// from ECMA-262 on lookarounds, backreferences, `\s` and `.` among others.
+// gojsonschema matches the keys itself and no other keyword applies a
+// subschema to properties by name, so they can't be rebound like `pattern`.
+func ecmaPatternProperties(doc interface{}, base *url.URL) error {
+	var errs []string
+	walkSchema(doc, base, func(ptr string, base *url.URL, schema map[string]interface{}) {
+		if _, ok := schema["patternProperties"]; ok {
+			errs = append(errs, fmt.Sprintf("#%s/patternProperties: unsupported with -regex-engine ecma, its keys can only be matched with RE2", ptr))
+		}
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// registryScheme prefixes the `-s` schemas fetched from the `-registry-url`
+const registryScheme = "registry://"
+
+// registrySchema is a version of a subject in a Confluent-compatible schema
+// registry, as returned by `GET /subjects/{subject}/versions/{version}`
+type registrySchema struct {
+	Subject    string              `json:"subject"`
+	Version    int                 `json:"version"`
+	SchemaType string              `json:"schemaType"`
+	Schema     string              `json:"schema"`
+	References []registryReference `json:"references"`
+}
+
+// registryReference is a schema a registered schema refers to, by the name
+// used in its `$ref`s
+type registryReference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// isRegistryURI reports if the schema argument is a `registry://` URI
+func isRegistryURI(arg string) bool {
+	return strings.HasPrefix(arg, registryScheme)
+}
+
+// parseRegistryURI splits a URI like `registry://orders-value:3` into the
+// subject and version, which defaults to `latest`.
+func parseRegistryURI(uri string) (string, string, error) {
+	subject, version := strings.TrimPrefix(uri, registryScheme), "latest"
+	if i := strings.LastIndex(subject, ":"); i >= 0 {
+		subject, version = subject[:i], subject[i+1:]
+		if _, err := strconv.Atoi(version); err != nil && version != "latest" {
+			return "", "", fmt.Errorf("invalid version %s, expected a number or latest", version)
+		}
+	}
+	if subject == "" {
+		return "", "", fmt.Errorf("expected registry://subject[:version]")
+	}
+	return subject, version, nil
+}
+
+// registryLoader fetches the JSON schema registered for the subject version
+// of uri, along with the schemas it references in turn. The schema's base
+// URI is the URL it's fetched from, and the references are registered under
+// their names resolved against it, as the registry's serializers do.
+func registryLoader(uri string) (gojsonschema.JSONLoader, *url.URL, []schemaSource, error) {
+	subject, version, err := parseRegistryURI(uri)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	s, base, err := fetchRegistrySchema(subject, version)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	loader := gojsonschema.NewStringLoader(s.Schema)
+	doc, err := loader.LoadJSON()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s version %d: %s", subject, s.Version, err)
+	}
+
+	type pending struct {
+		base *url.URL
+		doc  interface{}
+		refs []registryReference
+	}
+	queue := []pending{{base, doc, s.References}}
+	seen := map[string]bool{stripFragment(base): true}
+	var refs []schemaSource
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, ref := range p.refs {
+			u, err := url.Parse(ref.Name)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("reference %s: %s", ref.Name, err)
+			}
+			key := schemaBase(p.doc, p.base).ResolveReference(u)
+			if seen[stripFragment(key)] {
+				continue
+			}
+			seen[stripFragment(key)] = true
+			s, _, err := fetchRegistrySchema(ref.Subject, strconv.Itoa(ref.Version))
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("reference %s: %s", ref.Name, err)
+			}
+			doc, err := gojsonschema.NewStringLoader(s.Schema).LoadJSON()
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("reference %s: %s version %d: %s", ref.Name, ref.Subject, s.Version, err)
+			}
+			path := fmt.Sprintf("%s%s:%d", registryScheme, ref.Subject, s.Version)
+			refs = append(refs, schemaSource{path: path, doc: doc, base: key})
+			queue = append(queue, pending{key, doc, s.References})
+		}
+	}
+	return loader, base, refs, nil
+}
+
+// schemaBase returns the base URI of the schema doc retrieved from base,
+// i.e. after applying any root `$id`.
+func schemaBase(doc interface{}, base *url.URL) *url.URL {
+	if m, ok := doc.(map[string]interface{}); ok {
+		if u, err := url.Parse(schemaID(m)); err == nil {
+			return base.ResolveReference(u)
+		}
+	}
+	return base
+}
+
+// fetchRegistrySchema gets a version of the subject from the `-registry-url`
+// within `-http-timeout`, authenticating with any `-registry-auth`,
+// returning it along with the URL it was fetched from.
+func fetchRegistrySchema(subject, version string) (registrySchema, *url.URL, error) {
+	var s registrySchema
+	if *registryURLFlag == "" {
+		return s, nil, fmt.Errorf("no -registry-url to fetch %s from", subject)
+	}
+	if *offlineFlag {
+		return s, nil, fmt.Errorf("%s can't be fetched with -offline", subject)
+	}
+	rawurl := fmt.Sprintf("%s/subjects/%s/versions/%s", strings.TrimSuffix(*registryURLFlag, "/"), url.PathEscape(subject), version)
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return s, nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, rawurl, nil)
+	if err != nil {
+		return s, nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json, application/json")
+	if *registryAuthFlag != "" {
+		user, password, _ := strings.Cut(os.ExpandEnv(*registryAuthFlag), ":")
+		req.SetBasicAuth(user, password)
+	}
+	resp, err := doHTTP(req, schemaTimeout())
+	if err != nil {
+		return s, nil, err
+	}
+	defer resp.Body.Close()
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return s, nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return s, nil, fmt.Errorf("GET %s: %s", rawurl, resp.Status)
+	}
+	if err := json.Unmarshal(buf, &s); err != nil {
+		return s, nil, fmt.Errorf("GET %s: %s", rawurl, err)
+	}
+	// Schemas without a type are Avro, the registry's original format
+	if s.SchemaType != "JSON" {
+		typ := s.SchemaType
+		if typ == "" {
+			typ = "AVRO"
+		}
+		return s, nil, fmt.Errorf("%s version %d is a %s schema, not JSON", subject, s.Version, typ)
+	}
+	return s, u, nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xeipuuv/gojsonreference"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// isURL reports if the document argument is an HTTP(S) URL rather than a
+// local path or glob.
+func isURL(arg string) bool {
+	return strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://")
+}
+
+// fetchURL downloads the document at rawurl within the timeout, returning
+// the body along with the path of the URL for detecting the format, e.g.
+// `/v1/users.json` without any query string.
+func fetchURL(rawurl string, timeout time.Duration) ([]byte, string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, rawurl, err
+	}
+	req, err := http.NewRequest(http.MethodGet, rawurl, nil)
+	if err != nil {
+		return nil, u.Path, err
+	}
+	resp, err := doHTTP(req, timeout)
+	if err != nil {
+		return nil, u.Path, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, u.Path, fmt.Errorf("GET %s: %s", rawurl, resp.Status)
+	}
+	if err := tooLarge(resp.ContentLength); err != nil {
+		return nil, u.Path, err
+	}
+	buf, err := readDoc(resp.Body)
+	return buf, u.Path, err
+}
+
+// getSchema downloads the schema at rawurl without caching it
+func getSchema(rawurl string) ([]byte, error) {
+	req, err := newSchemaRequest(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := doHTTP(req, schemaTimeout())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("GET %s: %s", rawurl, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// newSchemaRequest returns the GET request for the remote schema at rawurl
+// with the `-schema-header`s that apply to it. Headers without a host are
+// only sent over HTTPS, so credentials aren't leaked in plain text.
+func newSchemaRequest(rawurl string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range headerFlags {
+		host, name, value, err := parseSchemaHeader(h)
+		if err != nil {
+			return nil, err
+		}
+		if host == req.URL.Host || host == "" && req.URL.Scheme == "https" {
+			req.Header.Add(name, value)
+		}
+	}
+	return req, nil
+}
+
+// parseSchemaHeader splits a `-schema-header` like `Authorization: Bearer
+// $TOKEN`, optionally prefixed with `host=`, into the host, the header name
+// and its value with environment variables expanded.
+func parseSchemaHeader(h string) (string, string, string, error) {
+	var host string
+	if eq := strings.Index(h, "="); eq > 0 && !strings.ContainsAny(h[:eq], " \t") {
+		// Header names can't contain `=`, so one before the name ends the
+		// host, which may have a port, e.g. `localhost:8080=Name: value`
+		if colon := strings.Index(h[eq+1:], ":"); colon > 0 && !strings.ContainsAny(h[eq+1:eq+1+colon], " \t") {
+			host, h = h[:eq], h[eq+1:]
+		}
+	}
+	colon := strings.Index(h, ":")
+	if colon < 0 {
+		return "", "", "", fmt.Errorf("expected Name: value")
+	}
+	name, value := strings.TrimSpace(h[:colon]), strings.TrimSpace(h[colon+1:])
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", "", fmt.Errorf("expected Name: value")
+	}
+	return host, http.CanonicalHeaderKey(name), os.ExpandEnv(value), nil
+}
+
+// checkRedirect drops the `-schema-header`s from a redirect to another host
+// than the original request's or from HTTPS to plain HTTP, since
+// http.Client only drops a few standard credential headers itself, and
+// otherwise stops after 10 redirects like its default policy.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	orig := via[0].URL
+	if req.URL.Host != orig.Host || orig.Scheme == "https" && req.URL.Scheme != "https" {
+		for _, h := range headerFlags {
+			if _, name, _, err := parseSchemaHeader(h); err == nil {
+				req.Header.Del(name)
+			}
+		}
+	}
+	return nil
+}
+
+// httpRetryDelay is the delay before the first retry of a failed request,
+// doubling for each one after, shortened for testing
+var httpRetryDelay = 500 * time.Millisecond
+
+// schemaTimeout returns the `-http-timeout` for fetching remote schemas,
+// which defaults to the `-timeout` of documents
+func schemaTimeout() time.Duration {
+	if *httpTimeoutFlag > 0 {
+		return *httpTimeoutFlag
+	}
+	return *timeoutFlag
+}
+
+// doHTTP sends the GET request req, with the timeout for each attempt, via
+// any `-http-proxy`. Network errors and 429 or 5xx responses are retried up
+// to `-http-retries` times, after which the last response is returned for
+// the caller to report. Errors name the URL that failed.
+func doHTTP(req *http.Request, timeout time.Duration) (*http.Response, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if *httpProxyFlag != "" {
+		proxy, err := url.Parse(*httpProxyFlag)
+		if err != nil {
+			return nil, fmt.Errorf("GET %s: invalid -http-proxy: %s", req.URL, err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	client := http.Client{Timeout: timeout, Transport: transport, CheckRedirect: checkRedirect}
+	for attempt := 0; ; attempt++ {
+		resp, err := client.Do(req)
+		if err == nil && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return resp, nil
+		}
+		if attempt >= *httpRetriesFlag {
+			if err == nil {
+				return resp, nil
+			}
+			if ue, ok := err.(*url.Error); ok {
+				err = ue.Err // already naming the URL
+			}
+			if attempt > 0 {
+				return nil, fmt.Errorf("GET %s: %s, after %d attempts", req.URL, err, attempt+1)
+			}
+			return nil, fmt.Errorf("GET %s: %s", req.URL, err)
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		time.Sleep(httpRetryDelay << attempt)
+	}
+}
+
+// urlLoader fetches the schema at rawurl through the cache, converting it to
+// JSON based on the extension of the URL path or the contents.
+func urlLoader(rawurl string) (gojsonschema.JSONLoader, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := fetchSchema(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	buf, err = toJSON(u.Path, buf)
+	if err != nil {
+		return nil, err
+	}
+	return gojsonschema.NewBytesLoader(buf), nil
+}
+
+// remoteLoader loads the schema at an HTTP(S) URL, e.g. the target of a
+// `$ref`, with urlLoader rather than gojsonschema fetching it directly.
+type remoteLoader string
+
+func (l remoteLoader) JsonSource() interface{} {
+	return string(l)
+}
+
+func (l remoteLoader) LoadJSON() (interface{}, error) {
+	loader, err := urlLoader(string(l))
+	if err != nil {
+		return nil, err
+	}
+	doc, err := loader.LoadJSON()
+	if err != nil {
+		return nil, err
+	}
+	base, _ := url.Parse(string(l))
+	if err := prepareSchema(doc, base); err != nil {
+		return nil, err
+	}
+	if *strictDataFlag {
+		closeObjects([]schemaSource{{path: string(l), doc: doc, base: base}})
+	}
+	return doc, nil
+}
+
+func (l remoteLoader) JsonReference() (gojsonreference.JsonReference, error) {
+	return gojsonreference.NewJsonReference(string(l))
+}
+
+func (l remoteLoader) LoaderFactory() gojsonschema.JSONLoaderFactory {
+	return remoteLoaderFactory{}
+}
+
+// remoteLoaderFactory creates the loaders for any schema gojsonschema can't
+// find while compiling, using a remoteLoader for HTTP(S) URLs.
+type remoteLoaderFactory struct{}
+
+func (remoteLoaderFactory) New(source string) gojsonschema.JSONLoader {
+	if isURL(source) {
+		return remoteLoader(source)
+	}
+	return gojsonschema.DefaultJSONLoaderFactory{}.New(source)
+}
+
+// remoteRefs wraps the loader of the primary schema so that the remote
+// schemas it refers to are loaded by a remoteLoaderFactory.
+type remoteRefs struct {
+	gojsonschema.JSONLoader
+}
+
+func (remoteRefs) LoaderFactory() gojsonschema.JSONLoaderFactory {
+	return remoteLoaderFactory{}
+}
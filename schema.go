@@ -0,0 +1,415 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Keywords whose values are subschemas, arrays of subschemas or objects
+// with subschema values across the supported drafts
+var (
+	schemaKeywords = []string{
+		"additionalItems", "additionalProperties", "contains", "contentSchema",
+		"else", "if", "items", "not", "propertyNames", "then",
+		"unevaluatedItems", "unevaluatedProperties",
+	}
+	schemaArrayKeywords = []string{"allOf", "anyOf", "items", "oneOf", "prefixItems"}
+	schemaMapKeywords   = []string{
+		"$defs", "definitions", "dependencies", "dependentSchemas",
+		"patternProperties", "properties",
+	}
+)
+
+// schemaVisitor is called for each schema object by walkSchema with its JSON
+// pointer and base URI, i.e. after applying any `$id` it declares.
+type schemaVisitor func(ptr string, base *url.URL, schema map[string]interface{})
+
+// walkSchema calls fn for the schema doc and every subschema beneath it
+// in a stable order. Boolean schemas are skipped.
+func walkSchema(doc interface{}, base *url.URL, fn schemaVisitor) {
+	walkSubschema(doc, "", base, fn)
+}
+
+func walkSubschema(doc interface{}, ptr string, base *url.URL, fn schemaVisitor) {
+	schema, ok := doc.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if id := schemaID(schema); id != "" {
+		if u, err := url.Parse(id); err == nil {
+			base = base.ResolveReference(u)
+		}
+	}
+	fn(ptr, base, schema)
+
+	for _, kw := range schemaKeywords {
+		if sub, ok := schema[kw].(map[string]interface{}); ok {
+			walkSubschema(sub, ptr+"/"+kw, base, fn)
+		}
+	}
+	for _, kw := range schemaArrayKeywords {
+		if subs, ok := schema[kw].([]interface{}); ok {
+			for i, sub := range subs {
+				walkSubschema(sub, fmt.Sprintf("%s/%s/%d", ptr, kw, i), base, fn)
+			}
+		}
+	}
+	for _, kw := range schemaMapKeywords {
+		subs, ok := schema[kw].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		keys := make([]string, 0, len(subs))
+		for k := range subs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			walkSubschema(subs[k], ptr+"/"+kw+"/"+pointerEscaper.Replace(k), base, fn)
+		}
+	}
+}
+
+// schemaID returns the `$id` of the schema, or `id` for draft-04 schemas.
+func schemaID(schema map[string]interface{}) string {
+	if id, ok := schema["$id"].(string); ok {
+		return id
+	}
+	id, _ := schema["id"].(string)
+	return id
+}
+
+// loadRefs loads the `-r` schemas, skipping any of the primary schemas
+// that are also matched.
+func loadRefs(schemas []string) ([]schemaSource, error) {
+	paths, err := refPaths(schemas)
+	if err != nil {
+		return nil, err
+	}
+	var refs []schemaSource
+	for _, p := range paths {
+		absPath, err := filepath.Abs(p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: unable to convert to absolute path: %s", p, err)
+		}
+		loader, err := jsonLoader(absPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: unable to load schema ref: %s", p, err)
+		}
+		doc, err := loader.LoadJSON()
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid schema: %s", p, err)
+		}
+		refs = append(refs, schemaSource{path: p, doc: doc, sum: fileSum(loader)})
+	}
+	return refs, nil
+}
+
+// refPaths expands the `-r` patterns to the paths of the schema files they
+// match, skipping any of the primary schemas.
+func refPaths(schemas []string) ([]string, error) {
+	skip := make(map[string]bool)
+	for _, s := range schemas {
+		s, _ = splitFragment(s)
+		absPath, err := filepath.Abs(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: unable to convert to absolute path: %s", s, err)
+		}
+		skip[absPath] = true
+	}
+
+	var refs []string
+	for _, ref := range refFlags {
+		paths, err := globRefs(ref)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range paths {
+			absPath, err := filepath.Abs(p)
+			if err != nil {
+				return nil, fmt.Errorf("%s: unable to convert to absolute path: %s", p, err)
+			}
+
+			// Overlapping `-r` globs and directories only register schemas once
+			if skip[absPath] {
+				continue
+			}
+			skip[absPath] = true
+			refs = append(refs, p)
+		}
+	}
+	return refs, nil
+}
+
+// schemaFormats are the formats of the files loaded from `-r` directories
+var schemaFormats = map[string]bool{
+	formatJSON: true, formatJSONC: true, formatJSON5: true, formatYAML: true, formatTOML: true,
+}
+
+// globRefs expands the `-r` pattern to schema files. Directories stand for
+// every schema file beneath them, by extension, and `**` in a pattern also
+// matches any number of directories, e.g. `schemas/**/*.json`.
+func globRefs(pattern string) ([]string, error) {
+	matches, err := globPaths(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, p := range matches {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, p)
+			continue
+		}
+		err = walkTree(p, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && schemaFormats[formatExtensions[strings.ToLower(filepath.Ext(path))]] {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// loadSchema loads the primary schema from the path or URL arg. Schemas
+// fetched from a URL use it as their base URI so that relative refs resolve
+// against the URL rather than the working directory, as do those of a schema
+// registry, e.g. `registry://orders-value:3`. A JSON pointer fragment,
+// e.g. `schema.json#/definitions/foo`, selects a subschema as the root,
+// which is required for OpenAPI documents.
+func loadSchema(arg string) (schemaSource, error) {
+	var loader gojsonschema.JSONLoader
+	var err error
+	location, fragment := splitFragment(arg)
+	if fragment != "" && !strings.HasPrefix(fragment, "/") {
+		return schemaSource{}, fmt.Errorf("%s: unable to load schema: fragment isn't a JSON pointer, e.g. #/definitions/foo", arg)
+	}
+	base := &url.URL{}
+	var refs []schemaSource
+	switch {
+	case isRegistryURI(location):
+		loader, base, refs, err = registryLoader(location)
+	case isURL(location):
+		if base, err = url.Parse(location); err == nil {
+			loader, err = urlLoader(location)
+		}
+	default:
+		loader, err = jsonLoader(location)
+	}
+	if err != nil {
+		return schemaSource{}, fmt.Errorf("%s: unable to load schema: %s", arg, err)
+	}
+	doc, err := loader.LoadJSON()
+	if err != nil {
+		return schemaSource{}, fmt.Errorf("%s: unable to load schema: %s", arg, err)
+	}
+	// The schemas of OpenAPI documents are converted as a whole so that
+	// refs between them keep working
+	if isOpenAPI(doc) {
+		if fragment == "" {
+			return schemaSource{}, fmt.Errorf("%s: unable to load schema: OpenAPI documents need a fragment, e.g. #/components/schemas/foo", arg)
+		}
+		convertOpenAPI(doc)
+	}
+	if _, ok := resolvePointer(doc, fragment); !ok {
+		return schemaSource{}, fmt.Errorf("%s: unable to load schema: #%s not found", arg, fragment)
+	}
+	return schemaSource{path: arg, doc: doc, base: base, fragment: fragment, refs: refs, sum: fileSum(loader)}, nil
+}
+
+// splitFragment splits the path or URL of a schema from its fragment, if
+// any, which is unescaped.
+func splitFragment(arg string) (string, string) {
+	i := strings.Index(arg, "#")
+	if i < 0 {
+		return arg, ""
+	}
+	fragment, err := url.PathUnescape(arg[i+1:])
+	if err != nil {
+		fragment = arg[i+1:]
+	}
+	return arg[:i], fragment
+}
+
+// compileSchema compiles the primary schema src along with the referenced
+// schemas refs. Neither are modified, anchors are resolved in copies.
+func compileSchema(src schemaSource, refs []schemaSource) (compiledSchema, error) {
+	sources, err := resolveSources(src, refs)
+	if err != nil {
+		return compiledSchema{}, err
+	}
+	return compileSources(sources)
+}
+
+// resolveSources returns copies of the referenced schemas refs, and those of
+// src itself, followed by the local files their relative refs point to and
+// then src, the primary schema, as compileSources expects them.
+func resolveSources(src schemaSource, refs []schemaSource) ([]schemaSource, error) {
+	sources := make([]schemaSource, 0, len(refs)+len(src.refs)+1)
+	for _, s := range append(append(refs[:len(refs):len(refs)], src.refs...), src) {
+		s.doc = copyJSON(s.doc)
+		sources = append(sources, s)
+	}
+	src = sources[len(sources)-1]
+	// Relative refs to other files resolve against the schema's directory
+	if location, _ := splitFragment(src.path); stripFragment(src.base) == "" && location != stdinPath && !isObjectURI(location) {
+		uri, err := fileURI(location)
+		if err != nil {
+			return nil, invalidSchemaError{src.path, err}
+		}
+		files, err := loadRelativeRefs(sources, uri)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(append(sources[:len(sources)-1:len(sources)-1], files...), src)
+	}
+	return sources, nil
+}
+
+// compileSources compiles the last of the sources, the primary schema, with
+// the others registered for its refs to resolve to. The sources are modified
+// as anchors are resolved and custom keywords bound.
+func compileSources(sources []schemaSource) (compiledSchema, error) {
+	src := sources[len(sources)-1]
+	if *debugRefsFlag {
+		debugRefs(os.Stderr, sources)
+	}
+	resolveAnchors(sources)
+	for _, s := range sources {
+		if err := prepareSchema(s.doc, s.baseURI()); err != nil {
+			return compiledSchema{}, invalidSchemaError{s.path, err}
+		}
+	}
+	if *strictDataFlag {
+		closeObjects(sources)
+	}
+
+	sl := gojsonschema.NewSchemaLoader()
+	for _, ref := range sources[:len(sources)-1] {
+		var err error
+		if ref.base != nil {
+			err = sl.AddSchema(ref.base.String(), gojsonschema.NewGoLoader(ref.doc))
+		} else {
+			err = sl.AddSchemas(gojsonschema.NewGoLoader(ref.doc))
+		}
+		if err != nil {
+			return compiledSchema{}, invalidSchemaError{ref.path, err}
+		}
+	}
+	// A fragment root is compiled by reference to the document, registered
+	// under its URL, or `-base-uri`, or for files, the empty URI refs within
+	// it resolve to
+	var schema *gojsonschema.Schema
+	var err error
+	location := stripFragment(src.base)
+	root := "#" + (&url.URL{Fragment: src.fragment}).EscapedFragment()
+	switch {
+	case location != "":
+		if err = sl.AddSchema(location, gojsonschema.NewGoLoader(src.doc)); err == nil {
+			schema, err = sl.Compile(remoteLoader(location + root))
+		}
+	case src.fragment != "":
+		if err = sl.AddSchema("", gojsonschema.NewGoLoader(src.doc)); err == nil {
+			schema, err = sl.Compile(remoteLoader(root))
+		}
+	default:
+		schema, err = sl.Compile(remoteRefs{gojsonschema.NewGoLoader(src.doc)})
+	}
+	if err != nil {
+		return compiledSchema{}, invalidSchemaError{src.path, err}
+	}
+	return compiledSchema{schema, src.doc, src.fragment, src.path, schemaURI(src)}, nil
+}
+
+// schemaURI returns the absolute URI of the document of the primary schema
+// src, its `$id` resolved against the URL it was retrieved from or its
+// `file://` URI, or "" if it has neither, e.g. from stdin.
+func schemaURI(src schemaSource) string {
+	base := src.base
+	if stripFragment(base) == "" {
+		base = &url.URL{}
+		switch location, _ := splitFragment(src.path); {
+		case location == stdinPath:
+		case isObjectURI(location):
+			base, _ = url.Parse(location)
+		default:
+			if u, err := fileURI(location); err == nil {
+				base = u
+			}
+		}
+	}
+	if m, ok := src.doc.(map[string]interface{}); ok {
+		if u, err := url.Parse(schemaID(m)); err == nil {
+			base = base.ResolveReference(u)
+		}
+	}
+	if !base.IsAbs() {
+		return ""
+	}
+	return stripFragment(base)
+}
+
+// prepareSchema checks the schema doc for unknown keywords with
+// `-strict-schema`, and `patternProperties` the ecma engine can't apply,
+// and then binds its custom keywords in place.
+func prepareSchema(doc interface{}, base *url.URL) error {
+	if *strictSchemaFlag {
+		if ptrs := unknownKeywords(doc); len(ptrs) > 0 {
+			for i, ptr := range ptrs {
+				ptrs[i] = "#" + ptr + ": unknown keyword"
+			}
+			return fmt.Errorf("%s", strings.Join(ptrs, "\n"))
+		}
+	}
+	if *regexEngineFlag == "ecma" {
+		if err := ecmaPatternProperties(doc, base); err != nil {
+			return err
+		}
+	}
+	return bindKeywords(doc, base)
+}
+
+// invalidSchemaError is returned by compileSchema when the schema at path,
+// the primary or a reference, can't be compiled.
+type invalidSchemaError struct {
+	path string
+	err  error
+}
+
+func (e invalidSchemaError) Error() string {
+	return fmt.Sprintf("%s: invalid schema: %s", e.path, e.err)
+}
+
+// copyJSON returns a deep copy of the decoded JSON value v.
+func copyJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = copyJSON(e)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, e := range v {
+			a[i] = copyJSON(e)
+		}
+		return a
+	}
+	return v
+}
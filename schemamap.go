@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// schemaMapping assigns a schema to the documents matching a glob pattern
+type schemaMapping struct {
+	pattern string
+	schema  string
+}
+
+// readSchemaMap parses the `pattern -> schema` lines of a `-schema-map`
+// file, skipping blank lines and `#` comments. Relative patterns and schema
+// paths are relative to the directory of the file itself, like `-l` lists.
+func readSchemaMap(path string) ([]schemaMapping, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(path)
+	var mappings []schemaMapping
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "->", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected pattern -> schema: %s", n, line)
+		}
+		m := schemaMapping{strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])}
+		if m.pattern == "" || m.schema == "" {
+			return nil, fmt.Errorf("line %d: expected pattern -> schema: %s", n, line)
+		}
+		if _, err := filepath.Match(m.pattern, ""); err != nil {
+			return nil, fmt.Errorf("line %d: %s: %s", n, m.pattern, err)
+		}
+		if !filepath.IsAbs(m.pattern) {
+			m.pattern = filepath.Join(dir, m.pattern)
+		}
+		if !isURL(m.schema) && !filepath.IsAbs(m.schema) {
+			m.schema = filepath.Join(dir, m.schema)
+		}
+		mappings = append(mappings, m)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mappings, nil
+}
+
+// mappedSchema returns the schema of the first mapping whose pattern
+// matches the document at path, or "" if there's none.
+func mappedSchema(mappings []schemaMapping, path string) string {
+	if isURL(path) || isObjectURI(path) || path == stdinPath {
+		return ""
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	for _, m := range mappings {
+		pattern, err := filepath.Abs(m.pattern)
+		if err != nil {
+			continue
+		}
+		if ok, _ := filepath.Match(pattern, abs); ok {
+			return m.schema
+		}
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// byteSize is a flag for a number of bytes with an optional binary unit,
+// e.g. `512K`, `100MB` or `1GiB`
+type byteSize int64
+
+// byteUnits are the multipliers of the suffixes of a byteSize, longest first
+var byteUnits = []struct {
+	suffix string
+	n      int64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30},
+	{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+	{"B", 1},
+}
+
+func (s *byteSize) String() string {
+	n := int64(*s)
+	for i, unit := range "GMK" {
+		if mult := int64(1) << uint(30-10*i); n != 0 && n%mult == 0 {
+			return strconv.FormatInt(n/mult, 10) + string(unit)
+		}
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func (s *byteSize) Set(value string) error {
+	num, mult := strings.ToUpper(strings.TrimSpace(value)), int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(num, u.suffix) {
+			num, mult = strings.TrimSpace(strings.TrimSuffix(num, u.suffix)), u.n
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 || n > (1<<63-1)/mult {
+		return fmt.Errorf("invalid size %q", value)
+	}
+	*s = byteSize(n * mult)
+	return nil
+}
+
+// tooLarge returns the error for a document of size bytes if it's over the
+// `-max-file-size`, if any
+func tooLarge(size int64) error {
+	if maxFileSize > 0 && size > int64(maxFileSize) {
+		return fmt.Errorf("%d bytes exceeds -max-file-size of %s", size, &maxFileSize)
+	}
+	return nil
+}
+
+// readDoc reads all of r, stopping with an error once it's over the
+// `-max-file-size` rather than holding all of an oversized document.
+func readDoc(r io.Reader) ([]byte, error) {
+	if maxFileSize <= 0 {
+		return ioutil.ReadAll(r)
+	}
+	buf, err := ioutil.ReadAll(io.LimitReader(r, int64(maxFileSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(buf)) > int64(maxFileSize) {
+		return nil, fmt.Errorf("exceeds -max-file-size of %s", &maxFileSize)
+	}
+	return buf, nil
+}
+
+// readFile reads the file at path unless its size is over the
+// `-max-file-size`, which is checked before reading any of it.
+func readFile(path string) ([]byte, error) {
+	if maxFileSize > 0 {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := tooLarge(info.Size()); err != nil {
+			return nil, err
+		}
+	}
+	return ioutil.ReadFile(path)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// OpenDocument namespaces of the elements and attributes read from ODS files
+const (
+	odsOfficeNS = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	odsTableNS  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odsTextNS   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+)
+
+// validateSheet validates each row of the `-sheet` worksheet, or the first,
+// in the XLSX or ODS spreadsheet src as an object keyed by the names in the
+// header row. Like CSV, rows are reported by number, e.g. `data.xlsx:3`,
+// with failures located at the offending column and blank rows skipped.
+func validateSheet(schema schemaSet, path, format string, src []byte) []result {
+	var rows [][]string
+	var err error
+	if format == formatODS {
+		rows, err = odsRows(src, *sheetFlag)
+	} else {
+		rows, err = xlsxRows(src, *sheetFlag)
+	}
+	if err != nil {
+		return []result{{Path: path, Status: statusError, Error: fmt.Sprintf("load doc: %s", err)}}
+	}
+	if len(rows) == 0 {
+		return []result{{Path: path, Status: statusError, Error: "load doc: missing header row"}}
+	}
+
+	header := rows[0]
+	var results []result
+	for i, row := range rows[1:] {
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+		start := time.Now()
+		line := i + 2
+		name := fmt.Sprintf("%s:%d", path, line)
+
+		obj := make(map[string]interface{}, len(header))
+		pos := map[string]position{"": {line, 1}}
+		for col, key := range header {
+			if key == "" {
+				continue
+			}
+			field := ""
+			if col < len(row) {
+				field = row[col]
+			}
+			v, ok := csvValue(field)
+			if !ok {
+				continue
+			}
+			obj[key] = v
+			pos["/"+pointerEscaper.Replace(key)] = position{line, col + 1}
+		}
+		buf, err := json.Marshal(obj)
+		if err != nil {
+			results = append(results, result{Path: name, Status: statusError, Error: fmt.Sprintf("load doc: %s", err), file: path, line: line})
+			continue
+		}
+		r := validateJSON(schema, name, buf, len(buf), start, func() map[string]position { return pos })
+		r.file, r.line = path, line
+		results = append(results, r)
+	}
+	return results
+}
+
+// xlsxRows returns the formatted cell values of each row of the named sheet
+// in the XLSX workbook src, or the first sheet when name is empty.
+func xlsxRows(src []byte, name string) ([][]string, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if name == "" {
+		name = f.GetSheetName(0)
+	}
+	return f.GetRows(name)
+}
+
+// odsRows returns the cell values of each row of the named table in the ODS
+// spreadsheet src, or the first table when name is empty. Numbers, dates
+// and booleans are their underlying values rather than the displayed text.
+// Repeated empty rows and cells are only expanded when followed by content,
+// as spreadsheets often pad tables to the maximum size this way.
+func odsRows(src []byte, name string) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(src), int64(len(src)))
+	if err != nil {
+		return nil, err
+	}
+	content, err := zr.Open("content.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	attr := func(e xml.StartElement, space, local string) string {
+		for _, a := range e.Attr {
+			if a.Name.Space == space && a.Name.Local == local {
+				return a.Value
+			}
+		}
+		return ""
+	}
+	repeated := func(e xml.StartElement, local string) int {
+		n, err := strconv.Atoi(attr(e, odsTableNS, local))
+		if err != nil || n < 1 {
+			return 1
+		}
+		return n
+	}
+
+	var (
+		rows                  [][]string
+		row                   []string
+		text                  strings.Builder
+		cell                  string
+		inTable, found        bool
+		rowRepeat, cellRepeat int
+		emptyRows, emptyCells int
+		paragraphs            int
+	)
+	dec := xml.NewDecoder(content)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case tok.Name.Space == odsTableNS && tok.Name.Local == "table":
+				if !found && (name == "" || attr(tok, odsTableNS, "name") == name) {
+					inTable, found = true, true
+				}
+			case !inTable:
+			case tok.Name.Space == odsTableNS && tok.Name.Local == "table-row":
+				row, emptyCells = nil, 0
+				rowRepeat = repeated(tok, "number-rows-repeated")
+			case tok.Name.Space == odsTableNS && (tok.Name.Local == "table-cell" || tok.Name.Local == "covered-table-cell"):
+				text.Reset()
+				paragraphs = 0
+				cellRepeat = repeated(tok, "number-columns-repeated")
+				switch attr(tok, odsOfficeNS, "value-type") {
+				case "float", "percentage", "currency":
+					cell = attr(tok, odsOfficeNS, "value")
+				case "date":
+					cell = attr(tok, odsOfficeNS, "date-value")
+				case "time":
+					cell = attr(tok, odsOfficeNS, "time-value")
+				case "boolean":
+					cell = attr(tok, odsOfficeNS, "boolean-value")
+				default:
+					cell = ""
+				}
+			case tok.Name.Space == odsTextNS && tok.Name.Local == "p":
+				if paragraphs > 0 {
+					text.WriteByte('\n')
+				}
+				paragraphs++
+			case tok.Name.Space == odsTextNS && tok.Name.Local == "s":
+				n, err := strconv.Atoi(attr(tok, odsTextNS, "c"))
+				if err != nil || n < 1 {
+					n = 1
+				}
+				text.WriteString(strings.Repeat(" ", n))
+			case tok.Name.Space == odsTextNS && tok.Name.Local == "tab":
+				text.WriteByte('\t')
+			case tok.Name.Space == odsTextNS && tok.Name.Local == "line-break":
+				text.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inTable && paragraphs > 0 {
+				text.Write(tok)
+			}
+		case xml.EndElement:
+			switch {
+			case !inTable:
+			case tok.Name.Space == odsTableNS && tok.Name.Local == "table":
+				inTable = false
+			case tok.Name.Space == odsTableNS && tok.Name.Local == "table-row":
+				if len(row) == 0 {
+					emptyRows += rowRepeat
+					continue
+				}
+				for ; emptyRows > 0; emptyRows-- {
+					rows = append(rows, nil)
+				}
+				for i := 0; i < rowRepeat; i++ {
+					rows = append(rows, row)
+				}
+			case tok.Name.Space == odsTableNS && (tok.Name.Local == "table-cell" || tok.Name.Local == "covered-table-cell"):
+				if cell == "" {
+					cell = text.String()
+				}
+				if cell == "" {
+					emptyCells += cellRepeat
+					continue
+				}
+				for ; emptyCells > 0; emptyCells-- {
+					row = append(row, "")
+				}
+				for i := 0; i < cellRepeat; i++ {
+					row = append(row, cell)
+				}
+			}
+		}
+	}
+	if !found {
+		if name == "" {
+			return nil, fmt.Errorf("no sheets")
+		}
+		return nil, fmt.Errorf("sheet %s does not exist", name)
+	}
+	return rows, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// maxSlowest is the number of documents listed by writeStats
+const maxSlowest = 5
+
+// docStats are the `-stats` measurements for a single document
+type docStats struct {
+	Size     int           `json:"size"`
+	Parse    time.Duration `json:"parse_ns"`
+	Validate time.Duration `json:"validate_ns"`
+}
+
+// writeStats prints the aggregate statistics over all the results along
+// with the slowest documents. Elapsed is the wall-clock time of the run.
+func writeStats(w io.Writer, results []result, elapsed time.Duration) {
+	var size int
+	var parse, validate time.Duration
+	timed := make([]result, 0, len(results))
+	for _, r := range results {
+		if r.Stats == nil {
+			continue
+		}
+		size += r.Stats.Size
+		parse += r.Stats.Parse
+		validate += r.Stats.Validate
+		timed = append(timed, r)
+	}
+
+	secs := elapsed.Seconds()
+	if secs == 0 {
+		secs = 1e-9
+	}
+	fmt.Fprintf(w, "stats: %d documents, %d bytes in %s (parse %s, validate %s)\n",
+		len(results), size, elapsed.Round(time.Microsecond),
+		parse.Round(time.Microsecond), validate.Round(time.Microsecond))
+	fmt.Fprintf(w, "stats: %.1f documents/s, %.1f KiB/s\n",
+		float64(len(results))/secs, float64(size)/1024/secs)
+
+	sort.SliceStable(timed, func(i, j int) bool {
+		return timed[i].Stats.total() > timed[j].Stats.total()
+	})
+	if len(timed) > maxSlowest {
+		timed = timed[:maxSlowest]
+	}
+	for _, r := range timed {
+		fmt.Fprintf(w, "stats: slowest: %s: %s (%d bytes, parse %s, validate %s)\n",
+			r.Path, r.Stats.total().Round(time.Microsecond), r.Stats.Size,
+			r.Stats.Parse.Round(time.Microsecond), r.Stats.Validate.Round(time.Microsecond))
+	}
+}
+
+func (s *docStats) total() time.Duration {
+	return s.Parse + s.Validate
+}
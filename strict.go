@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// duplicateFailures converts the duplicate keys of a document to failures,
+// offsetting their positions by the lines preceding the document.
+func duplicateFailures(dups []duplicateKey, lines int) []failure {
+	var fs []failure
+	for _, d := range dups {
+		field, context := pointerContext(d.object)
+		desc := fmt.Sprintf("Duplicate property %s", d.key)
+		f := failure{
+			Field:       field,
+			Type:        "duplicate_key",
+			Code:        errorCode("duplicate_key"),
+			Description: desc,
+			Line:        d.pos.Line + lines,
+			Column:      d.pos.Column,
+			message:     fmt.Sprintf("%s: %s", context, desc),
+
+			instanceLocation: d.object,
+		}
+		if *codesFlag {
+			f.message = fmt.Sprintf("[%s] %s", f.Code, f.message)
+		}
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+// yamlDuplicateKeys returns the mapping entries in the YAML text buf that
+// repeat an earlier key, which are otherwise silently overwritten. Returns
+// nil if buf isn't valid YAML.
+func yamlDuplicateKeys(buf []byte) []duplicateKey {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return nil
+	}
+	var dups []duplicateKey
+	var walk func(n *yaml.Node, ptr string)
+	walk = func(n *yaml.Node, ptr string) {
+		switch n.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for i, c := range n.Content {
+				if n.Kind == yaml.DocumentNode {
+					walk(c, ptr)
+				} else {
+					walk(c, fmt.Sprintf("%s/%d", ptr, i))
+				}
+			}
+		case yaml.MappingNode:
+			seen := make(map[string]bool)
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key := n.Content[i]
+				if key.Tag == "!!merge" {
+					continue
+				}
+				if seen[key.Value] {
+					dups = append(dups, duplicateKey{ptr, key.Value, position{key.Line, key.Column}})
+				}
+				seen[key.Value] = true
+				walk(n.Content[i+1], ptr+"/"+pointerEscaper.Replace(key.Value))
+			}
+		}
+	}
+	walk(&doc, "")
+	return dups
+}
+
+// tabFailures returns a failure for each line of the YAML text buf that is
+// indented with a tab, offset by the lines preceding the document. Parsers
+// reject most of these but accept some, e.g. within flow collections.
+func tabFailures(buf []byte, lines int) []failure {
+	var fs []failure
+	for i, line := range bytes.Split(buf, []byte("\n")) {
+		if len(line) == 0 || line[0] != '\t' || len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		field, context := pointerContext("")
+		const desc = "Indentation contains a tab character"
+		f := failure{
+			Field:       field,
+			Type:        "tab_indentation",
+			Code:        errorCode("tab_indentation"),
+			Description: desc,
+			Line:        i + 1 + lines,
+			Column:      1,
+			message:     fmt.Sprintf("%s: %s", context, desc),
+		}
+		if *codesFlag {
+			f.message = fmt.Sprintf("[%s] %s", f.Code, f.message)
+		}
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+// pointerContext returns the field and context of the JSON pointer ptr for
+// reporting failures, e.g. `foo.0` and `(root).foo.0` for `/foo/0`, or the
+// pointer itself with `-pointer`.
+func pointerContext(ptr string) (string, string) {
+	if *pointerFlag {
+		if ptr == "" {
+			return ptr, "(root)"
+		}
+		return ptr, ptr
+	}
+	context := "(root)"
+	if ptr != "" {
+		unescaper := strings.NewReplacer("~1", "/", "~0", "~")
+		for _, t := range strings.Split(ptr[1:], "/") {
+			context += "." + unescaper.Replace(t)
+		}
+	}
+	return strings.TrimPrefix(context, "(root)."), context
+}
+
+// addFailures merges the failures fs, found outside of schema validation,
+// into those of the result r in order of position, re-applying `-max-errors`.
+// Malformed documents are left as is.
+func addFailures(r *result, fs []failure) {
+	if len(fs) == 0 || r.Status == statusError {
+		return
+	}
+	r.Status = statusFail
+	r.Failures = append(r.Failures, fs...)
+	sortFailures(r.Failures)
+	if *maxErrorsFlag > 0 && len(r.Failures) > *maxErrorsFlag {
+		r.Truncated += len(r.Failures) - *maxErrorsFlag
+		r.Failures = r.Failures[:*maxErrorsFlag]
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// compositionKeywords are the keywords whose subschemas apply to the same
+// instance as the schema declaring them, along with `dependencies`
+var compositionKeywords = []string{"allOf", "anyOf", "oneOf", "if", "then", "else"}
+
+// closeObjects rewrites the object schemas of the sources in place for
+// `-strict-data`, as if `additionalProperties: false` was set wherever it's
+// missing. Properties a schema declares through `allOf`, `anyOf`, `oneOf`
+// `if`/`then`/`else` or `dependencies`, directly or by `$ref`, are allowed
+// by a `propertyNames` of the combined declarations instead, and those
+// subschemas, like those of `not`, are left open. Unresolved, e.g. remote,
+// refs leave the schema combining them open too.
+func closeObjects(sources []schemaSource) {
+	c := objectCloser{
+		resources: indexResources(sources, nil),
+		composed:  make(map[uintptr]bool),
+	}
+	for _, src := range sources {
+		walkSchema(src.doc, src.baseURI(), func(ptr string, base *url.URL, schema map[string]interface{}) {
+			for _, sub := range branches(schema) {
+				c.markComposed(src, sub)
+			}
+			c.markComposed(src, schema["not"])
+		})
+	}
+	for _, src := range sources {
+		walkSchema(src.doc, src.baseURI(), func(ptr string, base *url.URL, schema map[string]interface{}) {
+			if c.composed[schemaKey(schema)] {
+				return
+			}
+			if _, ok := schema["additionalProperties"]; ok {
+				return
+			}
+			if !hasComposition(schema) {
+				if declaresProperties(schema) {
+					schema["additionalProperties"] = false
+				}
+				return
+			}
+			decl := propertyDecls{names: make(map[string]bool)}
+			if !c.collect(src, schema, &decl, make(map[uintptr]bool)) || !decl.any {
+				return
+			}
+			names := make([]string, 0, len(decl.names))
+			for name := range decl.names {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			enum := make([]interface{}, len(names))
+			for i, name := range names {
+				enum[i] = name
+			}
+			alts := []interface{}{map[string]interface{}{"enum": enum}}
+			for _, p := range decl.patterns {
+				alts = append(alts, map[string]interface{}{"pattern": p})
+			}
+			var propertyNames interface{} = alts[0]
+			if len(alts) > 1 {
+				propertyNames = map[string]interface{}{"anyOf": alts}
+			}
+			if _, ok := schema["propertyNames"]; ok {
+				allOf, _ := schema["allOf"].([]interface{})
+				schema["allOf"] = append(allOf, map[string]interface{}{"propertyNames": propertyNames})
+			} else {
+				schema["propertyNames"] = propertyNames
+			}
+		})
+	}
+}
+
+// objectCloser tracks the subschemas that only apply in combination with
+// another while closing objects
+type objectCloser struct {
+	resources map[string]schemaSource
+	composed  map[uintptr]bool
+}
+
+// propertyDecls are the property names and patterns declared for an object
+type propertyDecls struct {
+	names    map[string]bool
+	patterns []string
+	any      bool
+}
+
+// branches returns the subschemas of the schema's composition keywords, in
+// a stable order
+func branches(schema map[string]interface{}) []interface{} {
+	var subs []interface{}
+	for _, kw := range compositionKeywords {
+		switch v := schema[kw].(type) {
+		case []interface{}:
+			subs = append(subs, v...)
+		case map[string]interface{}:
+			subs = append(subs, v)
+		}
+	}
+	if deps, ok := schema["dependencies"].(map[string]interface{}); ok {
+		keys := make([]string, 0, len(deps))
+		for k := range deps {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if _, ok := deps[k].(map[string]interface{}); ok {
+				subs = append(subs, deps[k])
+			}
+		}
+	}
+	return subs
+}
+
+// markComposed marks the subschema v, the targets of its refs and its own
+// branches as applying in combination, so they're never closed.
+func (c objectCloser) markComposed(src schemaSource, v interface{}) {
+	schema, ok := v.(map[string]interface{})
+	if !ok || c.composed[schemaKey(schema)] {
+		return
+	}
+	c.composed[schemaKey(schema)] = true
+	if target, ok := c.resolveRef(src, schema); ok {
+		c.markComposed(src, target)
+	}
+	for _, sub := range branches(schema) {
+		c.markComposed(src, sub)
+	}
+}
+
+// collect adds the property declarations of the schema and its branches to
+// decl, reporting false if any of them allow other properties.
+func (c objectCloser) collect(src schemaSource, schema map[string]interface{}, decl *propertyDecls, seen map[uintptr]bool) bool {
+	if seen[schemaKey(schema)] {
+		return true
+	}
+	seen[schemaKey(schema)] = true
+	if ap, ok := schema["additionalProperties"]; ok && ap != false {
+		return false
+	}
+	if props, ok := schema["properties"].(map[string]interface{}); ok {
+		decl.any = true
+		for name := range props {
+			decl.names[name] = true
+		}
+	}
+	if props, ok := schema["patternProperties"].(map[string]interface{}); ok {
+		decl.any = true
+		patterns := make([]string, 0, len(props))
+		for p := range props {
+			patterns = append(patterns, p)
+		}
+		sort.Strings(patterns)
+		decl.patterns = append(decl.patterns, patterns...)
+	}
+	if _, ok := schema["$ref"]; ok {
+		target, ok := c.resolveRef(src, schema)
+		if !ok {
+			return false
+		}
+		if m, ok := target.(map[string]interface{}); ok && !c.collect(src, m, decl, seen) {
+			return false
+		}
+	}
+	for _, sub := range branches(schema) {
+		if m, ok := sub.(map[string]interface{}); ok && !c.collect(src, m, decl, seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveRef returns the subschema the `$ref` of schema points to within the
+// sources, if any. Refs are resolved against the base URI of the source
+// alone, so only pointers and the `$id`s of other resources are found.
+func (c objectCloser) resolveRef(src schemaSource, schema map[string]interface{}) (interface{}, bool) {
+	ref, ok := schema["$ref"].(string)
+	if !ok {
+		return nil, false
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, false
+	}
+	target := src.baseURI().ResolveReference(u)
+	if !strings.HasPrefix(target.Fragment, "/") && target.Fragment != "" {
+		return nil, false
+	}
+	if stripFragment(target) == stripFragment(src.baseURI()) {
+		return resolvePointer(src.doc, target.Fragment)
+	}
+	res, found, ok := lookupRef(c.resources, target)
+	if !found || !ok {
+		return nil, false
+	}
+	return resolvePointer(res.doc, target.Fragment)
+}
+
+// hasComposition reports if the schema combines any subschemas
+func hasComposition(schema map[string]interface{}) bool {
+	return len(branches(schema)) > 0
+}
+
+// declaresProperties reports if the schema describes the properties of an
+// object, which is then closed
+func declaresProperties(schema map[string]interface{}) bool {
+	_, props := schema["properties"]
+	_, patterns := schema["patternProperties"]
+	return props || patterns
+}
+
+// schemaKey identifies a schema object by reference
+func schemaKey(schema map[string]interface{}) uintptr {
+	return reflect.ValueOf(schema).Pointer()
+}
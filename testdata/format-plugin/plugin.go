@@ -0,0 +1,15 @@
+// Package main is a format plugin for tests, built with
+// `go build -buildmode=plugin`.
+package main
+
+import "regexp"
+
+var ticket = regexp.MustCompile(`^[A-Z]+-[0-9]+$`)
+
+// Formats are registered by yajsv with -format-plugin
+var Formats = map[string]func(interface{}) bool{
+	"ticket": func(input interface{}) bool {
+		s, ok := input.(string)
+		return !ok || ticket.MatchString(s)
+	},
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// tomlTimeLayouts are the formats of TOML local dates and times keyed by
+// the names of the fixed zones the toml package decodes them into.
+var tomlTimeLayouts = map[string]string{
+	"datetime-local": "2006-01-02T15:04:05.999999999",
+	"date-local":     "2006-01-02",
+	"time-local":     "15:04:05.999999999",
+}
+
+// tomlToJSON converts TOML text to JSON text. Datetimes are converted to
+// RFC 3339 strings and local dates and times keep their TOML form so they
+// can be checked with the date-time, date and time formats respectively.
+func tomlToJSON(buf []byte) ([]byte, error) {
+	var doc map[string]interface{}
+	if err := toml.Unmarshal(buf, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(tomlValue(doc))
+}
+
+// tomlValue replaces the time.Time values within v by their string forms.
+func tomlValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = tomlValue(e)
+		}
+	case []map[string]interface{}:
+		arr := make([]interface{}, len(v))
+		for i, e := range v {
+			arr[i] = tomlValue(e)
+		}
+		return arr
+	case []interface{}:
+		for i, e := range v {
+			v[i] = tomlValue(e)
+		}
+	case time.Time:
+		if layout, ok := tomlTimeLayouts[v.Location().String()]; ok {
+			return v.Format(layout)
+		}
+		return v.Format(time.RFC3339Nano)
+	}
+	return v
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// expandDirs replaces the directories among paths with the documents
+// beneath them, in lexical order, leaving other paths as is.
+func expandDirs(paths []string) ([]string, error) {
+	var docs []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil || !info.IsDir() {
+			docs = append(docs, p)
+			continue
+		}
+		found, err := walkDocs(p)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, found...)
+	}
+	return docs, nil
+}
+
+// walkDocs returns the files under dir with one of the `-ext` extensions,
+// after removing any compressed extension like `.gz`. Hidden directories,
+// e.g. `.git`, are skipped along with those matching an `-exclude` and the
+// paths ignored by the `.gitignore` and `.yajsvignore` files within dir.
+func walkDocs(dir string) ([]string, error) {
+	exts := docExtensions()
+	exclude := excludePatterns(true)
+	rules := make(map[string][]ignoreRule)
+	var docs []string
+	err := walkTree(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		path = filepath.Clean(path)
+		parent := rules[filepath.Dir(path)]
+		if info.IsDir() {
+			if path != filepath.Clean(dir) && (strings.HasPrefix(info.Name(), ".") || excluded(exclude, path) || ignored(parent, path, true)) {
+				return filepath.SkipDir
+			}
+			own, err := loadIgnoreRules(path)
+			if err != nil {
+				return err
+			}
+			rules[path] = append(parent[:len(parent):len(parent)], own...)
+			return nil
+		}
+		if ignored(parent, path, false) {
+			return nil
+		}
+		if exts[docExt(path)] {
+			docs = append(docs, path)
+		}
+		return nil
+	})
+	return docs, err
+}
+
+// docExt returns the lower case extension of the file at path, ignoring any
+// compressed extension, e.g. `.json` for `data.JSON.gz`.
+func docExt(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if _, ok := decompressors[ext]; ok {
+		ext = strings.ToLower(filepath.Ext(strings.TrimSuffix(path, filepath.Ext(path))))
+	}
+	return ext
+}
+
+// docExtensions returns the comma separated `-ext` extensions as a set of
+// lower case extensions with a leading dot.
+func docExtensions() map[string]bool {
+	exts := make(map[string]bool)
+	for _, ext := range strings.Split(*extFlag, ",") {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		exts[ext] = true
+	}
+	return exts
+}
+
+// expandBraces returns the patterns of each alternative of the `{a,b}`
+// groups in pattern, which may be nested, e.g. `*.{json,y{a,}ml}` expands to
+// `*.json`, `*.yaml` and `*.yml`. Unbalanced braces are left as is.
+func expandBraces(pattern string) []string {
+	start, depth := -1, 0
+	var alts []string
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case ',':
+			if depth == 1 {
+				alts = append(alts, pattern[start+1:i])
+				start = i
+			}
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			if depth--; depth > 0 {
+				continue
+			}
+			alts = append(alts, pattern[start+1:i])
+			var patterns []string
+			prefix := pattern[:strings.Index(pattern, "{")]
+			for _, alt := range alts {
+				patterns = append(patterns, expandBraces(prefix+alt+pattern[i+1:])...)
+			}
+			return patterns
+		}
+	}
+	return []string{pattern}
+}
+
+// globStar matches the files of a pattern with `**` by walking from the
+// longest directory prefix without any wildcards, or defers to filepath.Glob
+// otherwise, which only knows `[^...]` for negated classes.
+func globStar(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		return filepath.Glob(strings.ReplaceAll(pattern, "[!", "[^"))
+	}
+	slashed := filepath.ToSlash(filepath.Clean(pattern))
+	root := slashed[:strings.Index(slashed, "**")]
+	if i := strings.IndexAny(root, "*?["); i >= 0 {
+		root = root[:i]
+	}
+	root = root[:strings.LastIndex(root, "/")+1]
+	re, err := regexp.Compile("^" + globRegexp(slashed[len(root):]) + "$")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", pattern, err)
+	}
+	dir := filepath.FromSlash(root)
+	if dir == "" {
+		dir = "."
+	}
+	var paths []string
+	err = walkTree(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && !info.IsDir() && re.MatchString(filepath.ToSlash(rel)) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// walkTree walks the files under root like filepath.Walk, in lexical order,
+// but resolving root if it's a symbolic link. Links to directories beneath it
+// are only walked with `-follow-symlinks`, once for each directory they
+// resolve to so that cycles of links end.
+func walkTree(root string, fn filepath.WalkFunc) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+	err = walkLinks(root, info, make(map[string]bool), fn)
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+// walkLinks calls fn for path and the files under it, skipping the
+// directories that were already visited and links to directories unless
+// following them.
+func walkLinks(path string, info os.FileInfo, visited map[string]bool, fn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+	real, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		real, err = filepath.Abs(real)
+	}
+	if err != nil {
+		return fn(path, info, err)
+	}
+	if visited[real] {
+		return nil
+	}
+	visited[real] = true
+
+	if err := fn(path, info, nil); err != nil {
+		return err
+	}
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return fn(path, info, err)
+	}
+	for _, entry := range entries {
+		name := filepath.Join(path, entry.Name())
+		// Broken links are left to fail when they're read
+		if entry.Mode()&os.ModeSymlink != 0 {
+			if target, err := os.Stat(name); err == nil {
+				if target.IsDir() && !*followSymlinksFlag {
+					continue
+				}
+				entry = target
+			}
+		}
+		err := walkLinks(name, entry, visited, fn)
+		if err == filepath.SkipDir {
+			if entry.IsDir() {
+				continue
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// catalogGlob compiles a `fileMatch` glob to a regexp. Patterns without a
+// `/` match base names and others match any trailing directories of a path,
+// e.g. a workflow under `.github/workflows` of the working directory.
+func catalogGlob(pattern string) *regexp.Regexp {
+	pattern = strings.TrimPrefix(pattern, "/")
+	return regexp.MustCompile("(^|/)" + globRegexp(pattern) + "$")
+}
+
+// globRegexp translates a glob of slash separated paths to a regexp, where
+// `**` also matches across directories, `{a,b}` either alternative and
+// `[...]` or its negation `[!...]` a class of characters other than `/`.
+func globRegexp(pattern string) string {
+	var re strings.Builder
+	braces := 0
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '{':
+			braces++
+			re.WriteString("(")
+		case c == '}' && braces > 0:
+			braces--
+			re.WriteString(")")
+		case c == ',' && braces > 0:
+			re.WriteString("|")
+		case c == '[' && globClass(pattern[i:]) > 0:
+			n := globClass(pattern[i:])
+			class, negate := pattern[i+1:i+n-1], false
+			if class[0] == '!' || class[0] == '^' {
+				class, negate = class[1:], true
+			}
+			re.WriteString("[")
+			if negate {
+				re.WriteString("^/")
+			}
+			for _, r := range class {
+				if r == '-' {
+					re.WriteRune(r)
+				} else {
+					re.WriteString(regexp.QuoteMeta(string(r)))
+				}
+			}
+			re.WriteString("]")
+			i += n - 1
+		default:
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	for ; braces > 0; braces-- {
+		re.WriteString(")")
+	}
+	return re.String()
+}
+
+// globClass returns the length of the character class at the start of the
+// glob pattern, including its brackets, or 0 when it isn't closed. As in
+// shells, a `]` leading the class is one of its characters.
+func globClass(pattern string) int {
+	i := 1
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		i++
+	}
+	if i < len(pattern) && pattern[i] == ']' {
+		i++
+	}
+	for ; i < len(pattern); i++ {
+		switch pattern[i] {
+		case ']':
+			return i + 1
+		case '/':
+			return 0
+		}
+	}
+	return 0
+}
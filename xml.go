@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// xmlToJSON converts XML text to JSON text with the root element as the
+// only property of an object. Elements with neither attributes nor child
+// elements map to their text content, or null when empty. Otherwise an
+// element is an object with attributes keyed by `-xml-attr-prefix` and the
+// name, child elements by name and any text by `-xml-text-key`. Repeated
+// child elements are collected into an array. All values are strings as
+// XML has no types. Namespaces are ignored.
+func xmlToJSON(buf []byte) ([]byte, error) {
+	type element struct {
+		name     string
+		children map[string]interface{}
+		text     strings.Builder
+	}
+	var root map[string]interface{}
+	var stack []*element
+
+	dec := xml.NewDecoder(bytes.NewReader(buf))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			e := &element{name: tok.Name.Local, children: make(map[string]interface{})}
+			for _, a := range tok.Attr {
+				if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+					continue
+				}
+				e.children[*xmlAttrPrefixFlag+a.Name.Local] = a.Value
+			}
+			stack = append(stack, e)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(tok)
+			}
+		case xml.EndElement:
+			e := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			var v interface{}
+			text := strings.TrimSpace(e.text.String())
+			if len(e.children) == 0 {
+				if text != "" {
+					v = text
+				}
+			} else {
+				if text != "" {
+					e.children[*xmlTextKeyFlag] = text
+				}
+				v = e.children
+			}
+
+			if len(stack) == 0 {
+				root = map[string]interface{}{e.name: v}
+				continue
+			}
+			parent := stack[len(stack)-1].children
+			switch prev := parent[e.name].(type) {
+			case nil:
+				if _, ok := parent[e.name]; ok {
+					parent[e.name] = []interface{}{nil, v}
+				} else {
+					parent[e.name] = v
+				}
+			case []interface{}:
+				parent[e.name] = append(prev, v)
+			default:
+				parent[e.name] = []interface{}{prev, v}
+			}
+		}
+	}
+	if root == nil {
+		return nil, errors.New("xml: missing root element")
+	}
+	return json.Marshal(root)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlDocument is a single document within a YAML stream
+type yamlDocument struct {
+	src  []byte
+	line int // number of lines preceding src in the stream
+}
+
+// splitYAML splits a YAML stream into its `---` separated documents.
+// Document markers can't occur within scalars at the start of a line so
+// splitting the text keeps the source of each document intact for
+// reporting failure positions. Documents without any content, e.g. the
+// empty one before a leading `---`, are dropped.
+func splitYAML(buf []byte) []yamlDocument {
+	var docs []yamlDocument
+	add := func(src []byte, line int) {
+		var n yaml.Node
+		if err := yaml.Unmarshal(src, &n); err == nil && n.Kind == 0 {
+			return // empty document, malformed ones are kept to report
+		}
+		docs = append(docs, yamlDocument{src, line})
+	}
+
+	start, line := 0, 0
+	for i, n := 0, 0; i < len(buf); n++ {
+		end := bytes.IndexByte(buf[i:], '\n') + i + 1
+		if end == i {
+			end = len(buf)
+		}
+		if isDocumentMarker(buf[i:end]) && i > start {
+			add(buf[start:i], line)
+			start, line = i, n
+		}
+		i = end
+	}
+	add(buf[start:], line)
+	return docs
+}
+
+// isDocumentMarker reports if l is a `---` line, optionally followed by
+// content after whitespace, e.g. `--- !tag`.
+func isDocumentMarker(l []byte) bool {
+	if !bytes.HasPrefix(l, []byte("---")) {
+		return false
+	}
+	return len(l) == 3 || l[3] == ' ' || l[3] == '\t' || l[3] == '\r' || l[3] == '\n'
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlFloat matches the plain scalars that are floats in both YAML 1.1 and
+// the YAML 1.2 core schema, ignoring the special infinity and NaN values
+var yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+
+// YAML 1.2 core schema patterns for plain scalars, anything else is a string
+var (
+	yamlCoreNull = regexp.MustCompile(`^(null|Null|NULL|~|)$`)
+	yamlCoreBool = regexp.MustCompile(`^(true|True|TRUE|false|False|FALSE)$`)
+	yamlCoreInt  = regexp.MustCompile(`^([-+]?[0-9]+|0o[0-7]+|0x[0-9a-fA-F]+)$`)
+	yamlCoreInf  = regexp.MustCompile(`^([-+]?\.(inf|Inf|INF)|\.(nan|NaN|NAN))$`)
+)
+
+// yaml11Values are the YAML 1.1 plain scalars resolved by name
+var yaml11Values = map[string]interface{}{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"true": true, "True": true, "TRUE": true,
+	"on": true, "On": true, "ON": true,
+	"n": false, "N": false, "no": false, "No": false, "NO": false,
+	"false": false, "False": false, "FALSE": false,
+	"off": false, "Off": false, "OFF": false,
+	"": nil, "~": nil, "null": nil, "Null": nil, "NULL": nil,
+	".nan": yamlNonFinite{}, ".NaN": yamlNonFinite{}, ".NAN": yamlNonFinite{},
+	".inf": yamlNonFinite{}, ".Inf": yamlNonFinite{}, ".INF": yamlNonFinite{},
+	"+.inf": yamlNonFinite{}, "+.Inf": yamlNonFinite{}, "+.INF": yamlNonFinite{},
+	"-.inf": yamlNonFinite{}, "-.Inf": yamlNonFinite{}, "-.INF": yamlNonFinite{},
+}
+
+// yamlNonFinite is a resolved infinity or NaN, which JSON can't represent
+type yamlNonFinite struct{}
+
+// yamlToJSON converts YAML text to JSON text, resolving plain scalars per
+// the given YAML version. Numbers are kept as written, rather than parsed
+// as float64, so that large integers and precise decimals are validated
+// exactly.
+//
+// In YAML 1.1 `yes`, `no`, `on` and `off` are booleans, integers with a
+// leading zero are octal and timestamps are strings. The YAML 1.2 core
+// schema only has `true` and `false` booleans and leading zeros are decimal.
+func yamlToJSON(buf []byte, version string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return []byte("null"), nil
+	}
+	if err := yamlAliases(&doc); err != nil {
+		return nil, err
+	}
+	resolve := yaml11Resolve
+	if version == "1.2" {
+		resolve = yamlCoreResolve
+	}
+	v, err := yamlValue(doc.Content[0], resolve)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// yamlResolver resolves a plain scalar to a JSON value and its YAML tag
+type yamlResolver func(s string) (interface{}, string)
+
+// yamlValue converts the YAML node n to a JSON value.
+func yamlValue(n *yaml.Node, resolve yamlResolver) (interface{}, error) {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return yamlValue(n.Alias, resolve)
+	case yaml.SequenceNode:
+		arr := make([]interface{}, len(n.Content))
+		for i, c := range n.Content {
+			v, err := yamlValue(c, resolve)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = v
+		}
+		return arr, nil
+	case yaml.MappingNode:
+		obj := make(map[string]interface{})
+		if err := yamlMerge(obj, n, resolve, false); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	}
+	return yamlScalar(n, resolve)
+}
+
+// yamlMerge adds the entries of the mapping n to obj, including those of
+// `<<` merge keys which never override explicit entries. Entries already in
+// obj are kept when merged is set.
+func yamlMerge(obj map[string]interface{}, n *yaml.Node, resolve yamlResolver, merged bool) error {
+	var merges []*yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, val := n.Content[i], n.Content[i+1]
+		if key.Tag == "!!merge" {
+			merges = append(merges, val)
+			continue
+		}
+		if key.Kind == yaml.AliasNode {
+			key = key.Alias
+		}
+		if key.Kind != yaml.ScalarNode {
+			return fmt.Errorf("yaml: line %d: unsupported non-scalar mapping key", key.Line)
+		}
+		k, err := yamlScalar(key, resolve)
+		if err != nil {
+			return err
+		}
+		name := fmt.Sprint(k)
+		if k == nil {
+			name = "null"
+		}
+		if _, ok := obj[name]; ok && merged {
+			continue
+		}
+		v, err := yamlValue(val, resolve)
+		if err != nil {
+			return err
+		}
+		obj[name] = v
+	}
+	for _, m := range merges {
+		for _, src := range yamlMergeSources(m) {
+			if src.Kind != yaml.MappingNode {
+				return fmt.Errorf("yaml: line %d: map merge requires map or sequence of maps as the value", src.Line)
+			}
+			if err := yamlMerge(obj, src, resolve, true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// yamlMergeSources returns the nodes merged by the value of a `<<` key, a
+// mapping or sequence of mappings, either of which may be aliases.
+func yamlMergeSources(m *yaml.Node) []*yaml.Node {
+	if m.Kind == yaml.AliasNode {
+		m = m.Alias
+	}
+	srcs := []*yaml.Node{m}
+	if m.Kind == yaml.SequenceNode {
+		srcs = append([]*yaml.Node(nil), m.Content...)
+	}
+	for i, src := range srcs {
+		if src.Kind == yaml.AliasNode {
+			srcs[i] = src.Alias
+		}
+	}
+	return srcs
+}
+
+// yamlAliases returns an error for aliases that can't be expanded, those
+// within their own anchor or expanding to far more nodes than the document
+// declares, e.g. a billion laughs.
+func yamlAliases(doc *yaml.Node) error {
+	if err := yamlAliasCycles(doc, make(map[*yaml.Node]bool), make(map[*yaml.Node]bool)); err != nil {
+		return err
+	}
+	expanded := make(map[*yaml.Node]int64)
+	total := yamlExpandedNodes(doc, expanded)
+	aliased := total - int64(len(expanded))
+	// Like yaml.v3 when decoding into values rather than nodes
+	if aliased > 100 && total > 1000 && float64(aliased)/float64(total) > yamlAliasRatio(total) {
+		return fmt.Errorf("yaml: document contains excessive aliasing")
+	}
+	return nil
+}
+
+// yamlMaxNodes caps the count of expanded nodes, well past the point of
+// excessive aliasing, so it can't overflow.
+const yamlMaxNodes = 1 << 40
+
+// yamlExpandedNodes returns the number of nodes n expands to with every
+// alias replaced by its anchored value, memoized in expanded which ends up
+// with an entry for each distinct node. Cycles must be rejected first.
+func yamlExpandedNodes(n *yaml.Node, expanded map[*yaml.Node]int64) int64 {
+	if n.Kind == yaml.AliasNode {
+		return yamlExpandedNodes(n.Alias, expanded)
+	}
+	if count, ok := expanded[n]; ok {
+		return count
+	}
+	count := int64(1)
+	for _, c := range n.Content {
+		if count += yamlExpandedNodes(c, expanded); count > yamlMaxNodes {
+			count = yamlMaxNodes
+		}
+	}
+	expanded[n] = count
+	return count
+}
+
+// yamlAliasRatio is the fraction of the nodes of a document that may come
+// from expanding aliases, from 99% for small documents down to 10% for
+// those of millions of nodes, matching yaml.v3.
+func yamlAliasRatio(nodes int64) float64 {
+	const low, high = 400000, 4000000
+	switch {
+	case nodes <= low:
+		return 0.99
+	case nodes >= high:
+		return 0.10
+	}
+	return 0.99 - 0.89*float64(nodes-low)/float64(high-low)
+}
+
+// yamlAliasCycles returns an error for an alias within the value of its own
+// anchor, which would expand forever. Nodes in done are already checked, so
+// each is only walked once however many aliases refer to it.
+func yamlAliasCycles(n *yaml.Node, active, done map[*yaml.Node]bool) error {
+	if n.Kind == yaml.AliasNode {
+		if active[n.Alias] {
+			return fmt.Errorf("yaml: line %d: anchor '%s' value contains itself", n.Line, n.Value)
+		}
+		n = n.Alias
+	}
+	if done[n] {
+		return nil
+	}
+	active[n] = true
+	for _, c := range n.Content {
+		if err := yamlAliasCycles(c, active, done); err != nil {
+			return err
+		}
+	}
+	delete(active, n)
+	done[n] = true
+	return nil
+}
+
+// yamlScalar converts the scalar node n to a JSON value. Quoted and block
+// scalars are strings, plain scalars are resolved and checked against any
+// explicit tag. Unknown tags, e.g. `!!binary`, are kept as strings.
+func yamlScalar(n *yaml.Node, resolve yamlResolver) (interface{}, error) {
+	if n.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return n.Value, nil
+	}
+	tag := ""
+	if n.Style&yaml.TaggedStyle != 0 {
+		tag = n.ShortTag()
+		switch tag {
+		case "!!null", "!!bool", "!!int", "!!float", "!!timestamp":
+		default:
+			return n.Value, nil
+		}
+	}
+
+	v, rtag := resolve(n.Value)
+	switch {
+	case tag == "", tag == rtag, tag == "!!float" && rtag == "!!int":
+	default:
+		return nil, fmt.Errorf("yaml: line %d: cannot decode %s `%s` as a %s", n.Line, rtag, n.Value, tag)
+	}
+	if _, ok := v.(yamlNonFinite); ok {
+		return nil, fmt.Errorf("yaml: line %d: %s can't be represented in JSON", n.Line, n.Value)
+	}
+	return v, nil
+}
+
+// yaml11Resolve resolves plain scalars like yaml.v2, other than keeping the
+// exact values of numbers.
+func yaml11Resolve(s string) (interface{}, string) {
+	if v, ok := yaml11Values[s]; ok {
+		switch v.(type) {
+		case nil:
+			return nil, "!!null"
+		case bool:
+			return v, "!!bool"
+		}
+		return v, "!!float"
+	}
+	if s == "" || !strings.ContainsAny(s[:1], "+-.0123456789") {
+		return s, "!!str"
+	}
+	if isYAMLTimestamp(s) {
+		return s, "!!timestamp"
+	}
+	// Base 0 handles the sign, 0b, 0o, 0x and octal 0 prefixes and underscores
+	var i big.Int
+	if _, ok := i.SetString(s, 0); ok {
+		return json.Number(i.String()), "!!int"
+	}
+	if plain := strings.Replace(s, "_", "", -1); yamlFloat.MatchString(plain) {
+		return yamlNumber(plain), "!!float"
+	}
+	return s, "!!str"
+}
+
+// yamlCoreResolve resolves plain scalars per the YAML 1.2 core schema.
+func yamlCoreResolve(s string) (interface{}, string) {
+	switch {
+	case yamlCoreNull.MatchString(s):
+		return nil, "!!null"
+	case yamlCoreBool.MatchString(s):
+		return strings.ToLower(s) == "true", "!!bool"
+	case yamlCoreInt.MatchString(s):
+		digits, base := strings.TrimLeft(s, "+-"), 10
+		switch {
+		case strings.HasPrefix(digits, "0o"):
+			digits, base = digits[2:], 8
+		case strings.HasPrefix(digits, "0x"):
+			digits, base = digits[2:], 16
+		}
+		var i big.Int
+		i.SetString(digits, base)
+		if strings.HasPrefix(s, "-") {
+			i.Neg(&i)
+		}
+		return json.Number(i.String()), "!!int"
+	case yamlFloat.MatchString(s):
+		return yamlNumber(s), "!!float"
+	case yamlCoreInf.MatchString(s):
+		return yamlNonFinite{}, "!!float"
+	}
+	return s, "!!str"
+}
+
+// yamlNumber converts a float matching yamlFloat to a valid JSON number,
+// which requires a single leading zero and digits after the point.
+func yamlNumber(s string) json.Number {
+	sign, num := "", strings.TrimPrefix(s, "+")
+	if strings.HasPrefix(num, "-") {
+		sign, num = "-", num[1:]
+	}
+	num = strings.TrimLeft(num, "0")
+	if num == "" || strings.IndexAny(num[:1], ".eE") == 0 {
+		num = "0" + num
+	}
+	num = strings.Replace(num, ".e", ".0e", 1)
+	num = strings.Replace(num, ".E", ".0E", 1)
+	if strings.HasSuffix(num, ".") {
+		num += "0"
+	}
+	return json.Number(sign + num)
+}
+
+// isYAMLTimestamp reports if s is a YAML 1.1 timestamp, e.g. `2001-12-14`,
+// which are kept as strings.
+func isYAMLTimestamp(s string) bool {
+	if len(s) < 5 || s[4] != '-' || strings.Trim(s[:4], "0123456789") != "" {
+		return false
+	}
+	for _, layout := range []string{
+		"2006-1-2T15:4:5.999999999Z07:00",
+		"2006-1-2t15:4:5.999999999Z07:00",
+		"2006-1-2 15:4:5.999999999",
+		"2006-1-2",
+	} {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}